@@ -0,0 +1,116 @@
+package bote
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+)
+
+// callbackAnswerTimeout is the time Telegram waits for an answer to a callback query,
+// later answers are rejected with "query is too old".
+const callbackAnswerTimeout = 15 * time.Second
+
+var (
+	// ErrNotCallback is returned by [Context.Answer] and [Context.AnswerURL] if the update is not a tap
+	// on a callback button.
+	ErrNotCallback = errors.New("update is not a callback query")
+	// ErrCallbackAlreadyAnswered is returned by [Context.Answer] and [Context.AnswerURL] if the callback
+	// query is already answered. Telegram accepts only one answer.
+	ErrCallbackAlreadyAnswered = errors.New("callback query is already answered")
+	// ErrCallbackAnswerTimeout is returned by [Context.Answer] and [Context.AnswerURL] if the handler
+	// runs for too long and Telegram does not accept an answer anymore.
+	ErrCallbackAnswerTimeout = errors.New("callback query is too old to be answered")
+)
+
+// callbackAnswer tracks the answer to a callback query. It is shared between the context of the update
+// and contexts created to dispatch the tapped button, so the query is answered once whatever handler does it.
+type callbackAnswer struct {
+	respond    func(*tele.CallbackResponse) error
+	receivedAt time.Time
+	// key identifies the tapped button with its payload in the message, see [callbackKey].
+	key string
+
+	mu       sync.Mutex
+	answered bool
+}
+
+// newCallbackAnswer returns nil if the update is not a callback query.
+func newCallbackAnswer(c tele.Context) *callbackAnswer {
+	if c.Callback() == nil {
+		return nil
+	}
+	return &callbackAnswer{
+		respond:    c.Respond,
+		receivedAt: time.Now(),
+		key:        callbackKey(c.Callback()),
+	}
+}
+
+// answer answers the callback query. The query is marked as answered even if the answer is late or fails:
+// Telegram does not accept a second answer anyway.
+func (a *callbackAnswer) answer(resp *tele.CallbackResponse) error {
+	if a == nil {
+		return ErrNotCallback
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.answered {
+		return ErrCallbackAlreadyAnswered
+	}
+	a.answered = true
+
+	if time.Since(a.receivedAt) > callbackAnswerTimeout {
+		return ErrCallbackAnswerTimeout
+	}
+	return a.respond(resp)
+}
+
+// answerDefault answers the callback query with an empty response if the handler has not answered it,
+// so Telegram client stops showing a loading spinner on the button.
+func (b *Bot) answerDefault(ctx *contextImpl) {
+	if ctx.cbAnswer == nil {
+		return
+	}
+	switch err := ctx.cbAnswer.answer(&tele.CallbackResponse{}); {
+	case err == nil, errors.Is(err, ErrCallbackAlreadyAnswered):
+	case errors.Is(err, ErrCallbackAnswerTimeout):
+		b.bot.log.Debug("callback query is too old to be answered", "elapsed", time.Since(ctx.cbAnswer.receivedAt).String())
+	default:
+		b.bot.log.Debug("failed to respond to callback", "error", err.Error())
+		b.bot.metr.incError(MetricsErrorTelegramAPI, MetricsErrorSeverityLow)
+	}
+}
+
+func (c *contextImpl) Answer(text string, showAlert bool) error {
+	return c.answer(&tele.CallbackResponse{Text: text, ShowAlert: showAlert})
+}
+
+func (c *contextImpl) AnswerURL(url string) error {
+	return c.answer(&tele.CallbackResponse{URL: url})
+}
+
+func (c *contextImpl) answer(resp *tele.CallbackResponse) error {
+	err := c.cbAnswer.answer(resp)
+	switch {
+	case err == nil:
+		return nil
+
+	case errors.Is(err, ErrNotCallback), errors.Is(err, ErrCallbackAlreadyAnswered):
+		c.bt.bot.log.Error("cannot answer callback", c.bt.userFields(c.user, "error", err.Error())...)
+		c.bt.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+
+	case errors.Is(err, ErrCallbackAnswerTimeout):
+		c.bt.bot.log.Warn("callback is answered too late", c.bt.userFields(c.user,
+			"elapsed", time.Since(c.cbAnswer.receivedAt).String())...)
+		c.bt.bot.metr.incError(MetricsErrorHandler, MetricsErrorSeverityLow)
+
+	default:
+		c.bt.bot.log.Error("failed to answer callback", c.bt.userFields(c.user, "error", err.Error())...)
+		c.bt.bot.metr.incError(MetricsErrorTelegramAPI, MetricsErrorSeverityLow)
+	}
+	return err
+}
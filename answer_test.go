@@ -0,0 +1,82 @@
+package bote
+
+import (
+	"testing"
+	"time"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recordingAnswer(responses *[]tele.CallbackResponse) *callbackAnswer {
+	return &callbackAnswer{
+		respond: func(resp *tele.CallbackResponse) error {
+			*responses = append(*responses, *resp)
+			return nil
+		},
+		receivedAt: time.Now(),
+	}
+}
+
+// TestContextAnswer verifies a handler answer replaces the default one and a second answer is rejected.
+func TestContextAnswer(t *testing.T) {
+	bot := setupTestBot(t)
+
+	var responses []tele.CallbackResponse
+	bot.RegisterButton("approve", func(ctx Context) error {
+		require.NoError(t, ctx.Answer("Already approved by @admin", true))
+		assert.ErrorIs(t, ctx.AnswerURL("https://t.me/bot?start=x"), ErrCallbackAlreadyAnswered)
+		return nil
+	})
+
+	btn := bot.NewButton("approve", "42")
+	upd := tele.Update{Callback: &tele.Callback{
+		Sender:  &tele.User{ID: 555},
+		Message: &tele.Message{ID: 1, Chat: &tele.Chat{ID: -100123, Type: tele.ChatChannel}},
+		Data:    "\f" + btn.Unique + "|" + btn.Data,
+	}}
+	ctx := &contextImpl{
+		bt:       bot,
+		ct:       bot.bot.tbot.NewContext(upd),
+		user:     newPublicUserContext(&tele.User{ID: 555}),
+		cbAnswer: recordingAnswer(&responses),
+	}
+
+	require.NoError(t, bot.callbackFallbackHandler(ctx))
+	bot.answerDefault(ctx)
+
+	require.Len(t, responses, 1, "default answer is skipped")
+	assert.Equal(t, "Already approved by @admin", responses[0].Text)
+	assert.True(t, responses[0].ShowAlert)
+}
+
+// TestContextAnswerDefault verifies the callback is answered with an empty response if the handler does not answer.
+func TestContextAnswerDefault(t *testing.T) {
+	bot := setupTestBot(t)
+
+	var responses []tele.CallbackResponse
+	ctx := &contextImpl{bt: bot, cbAnswer: recordingAnswer(&responses)}
+	bot.answerDefault(ctx)
+	bot.answerDefault(ctx)
+
+	require.Len(t, responses, 1)
+	assert.Equal(t, tele.CallbackResponse{}, responses[0])
+}
+
+// TestContextAnswerErrors verifies late answers and answers to non-callback updates are detected.
+func TestContextAnswerErrors(t *testing.T) {
+	bot := setupTestBot(t)
+
+	var responses []tele.CallbackResponse
+	late := recordingAnswer(&responses)
+	late.receivedAt = time.Now().Add(-callbackAnswerTimeout - time.Second)
+
+	ctx := NewContext(bot, 9201, 1).(*contextImpl)
+	ctx.cbAnswer = late
+	assert.ErrorIs(t, ctx.Answer("done", false), ErrCallbackAnswerTimeout)
+	assert.ErrorIs(t, ctx.Answer("done", false), ErrCallbackAlreadyAnswered)
+	assert.Empty(t, responses)
+
+	assert.ErrorIs(t, NewContext(bot, 9201, 1).Answer("done", false), ErrNotCallback)
+}
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
@@ -43,11 +44,33 @@ type Bot struct {
 	middlewares        *abstract.SafeSlice[MiddlewareFunc]
 	stateMap           *abstract.SafeMap[string, InitBundle]
 	callbackRouter     *abstract.SafeMap[string, HandlerFunc]
+	states             *abstract.SafeMap[string, StateNode]
 	startHandler       HandlerFunc
+	textHandler        HandlerFunc
 	deleteMessages     bool
 	logUpdates         bool
 	hideUserDataInLogs bool
 
+	sched   *scheduler
+	expirer *messageExpirer
+
+	cbStore   CallbackDataStore
+	cbTTL     time.Duration
+	cbSign    bool
+	cbSignKey *EncryptionKey
+
+	// ctx is the context passed to Start (or New before Start), handler contexts are derived from it.
+	// It is stored atomically: Start may replace it while handlers are running.
+	ctx            atomic.Pointer[context.Context]
+	handlerTimeout time.Duration
+
+	chatActionDelay time.Duration
+	streamInterval  time.Duration
+
+	debounce  *debouncer
+	idemStore IdempotencyStore
+	idemTTL   time.Duration
+
 	wp          *webhookPoller
 	webhookInit chan struct{}
 }
@@ -95,12 +118,31 @@ func NewWithOptions(ctx context.Context, token string, opts Options) (*Bot, erro
 		middlewares:        abstract.NewSafeSlice[MiddlewareFunc](),
 		stateMap:           abstract.NewSafeMap[string, InitBundle](),
 		callbackRouter:     abstract.NewSafeMap[string, HandlerFunc](),
+		states:             abstract.NewSafeMap[string, StateNode](),
 		deleteMessages:     lang.Deref(opts.Config.Bot.DeleteMessages),
 		logUpdates:         lang.Deref(opts.Config.Log.LogUpdates),
 		webhookInit:        make(chan struct{}),
 		hideUserDataInLogs: opts.Config.Log.HideUserData,
+		cbStore:            opts.CallbackDataStore,
+		cbTTL:              opts.Config.Bot.CallbackDataTTL,
+		cbSign:             opts.Config.Bot.SignCallbackData,
+		cbSignKey:          opts.callbackSigningKey,
+		handlerTimeout:     opts.Config.Bot.HandlerTimeout,
+		chatActionDelay:    opts.Config.Bot.ChatActionDelay,
+		streamInterval:     opts.Config.Bot.StreamInterval,
+		debounce:           newDebouncer(opts.Config.Bot.DebounceWindow),
+		idemStore:          lang.If[IdempotencyStore](opts.IdempotencyStore != nil, opts.IdempotencyStore, NewMemoryIdempotencyStore()),
+		idemTTL:            opts.Config.Bot.IdempotencyTTL,
+	}
+
+	if b.pool != nil {
+		b.pool.busyText = bote.busyText
 	}
 
+	bote.ctx.Store(&ctx)
+	bote.sched = newScheduler(bote, opts.JobStore)
+	bote.expirer = newMessageExpirer(bote, opts.Config.Bot.NotificationTTL, opts.Config.Bot.ErrorTTL)
+
 	b.addMiddleware(bote.masterMiddleware, tele.ChatPrivate)
 	bote.AddUserMiddleware(bote.cleanMiddleware)
 	bote.AddUserMiddleware(bote.startMiddleware)
@@ -117,6 +159,11 @@ func NewWithOptions(ctx context.Context, token string, opts Options) (*Bot, erro
 	return bote, nil
 }
 
+// baseContext returns the context passed to [Bot.Start], handler contexts are derived from it.
+func (b *Bot) baseContext() context.Context {
+	return *b.ctx.Load()
+}
+
 // isStartCommand reports whether the message text is a /start command, including deep-link starts
 // of the form "/start <payload>" (e.g. t.me/Bot?start=foo). A plain prefix check would also match
 // "/startxyz", so a payload must be separated by a space.
@@ -132,6 +179,7 @@ func isStartCommand(text string) bool {
 // You can pass nil map if you don't need to reinit messages.
 func (b *Bot) Start(ctx context.Context, startHandler HandlerFunc, stateMap map[State]InitBundle) chan struct{} {
 	b.startHandler = startHandler
+	b.ctx.Store(&ctx)
 	for k, v := range stateMap {
 		b.stateMap.Set(k.String(), v)
 	}
@@ -162,6 +210,16 @@ func (b *Bot) Start(ctx context.Context, startHandler HandlerFunc, stateMap map[
 		// Start bot
 		lang.Go(b.bot.log, b.bot.tbot.Start)
 
+		// Start scheduled jobs
+		lang.Go(b.bot.log, func() {
+			b.sched.run(ctx)
+		})
+
+		// Rearm deletion of expiring messages sent before restart
+		lang.Go(b.bot.log, func() {
+			b.expirer.restore(ctx)
+		})
+
 		// Wait for
 		//  1. Outer context stopping
 		//  2. Webhook init error
@@ -176,6 +234,14 @@ func (b *Bot) Start(ctx context.Context, startHandler HandlerFunc, stateMap map[
 
 		// Stop bot poller and updates processing
 		b.bot.tbot.Stop()
+		b.expirer.stop()
+
+		// Stop recording updates
+		if b.bot.rec != nil {
+			if err := b.bot.rec.close(); err != nil {
+				b.bot.log.Error("failed to close recorder", "error", err.Error())
+			}
+		}
 
 		// Shutdown webhook server
 		if b.wp != nil {
@@ -204,6 +270,19 @@ func (b *Bot) Start(ctx context.Context, startHandler HandlerFunc, stateMap map[
 	return stopChannel
 }
 
+// WebhookHandler returns a handler of webhook requests to mount under your own HTTP server,
+// usually with [WithWebhookHandler]. It accepts POST requests from allowed IPs with the secret token
+// of the webhook and counts them in metrics. The bot sets the webhook on start and deletes it on stop.
+// It returns a handler that responds 404 if the bot is not in webhook mode.
+func (b *Bot) WebhookHandler() http.Handler {
+	if b.wp == nil {
+		b.bot.log.Error("webhook handler is available only in webhook mode")
+		b.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return http.NotFoundHandler()
+	}
+	return b.wp
+}
+
 // Bot returns the underlying *tele.Bot.
 func (b *Bot) Bot() *tele.Bot {
 	return b.bot.tbot
@@ -308,7 +387,14 @@ func (b *Bot) Handle(endpoint any, f HandlerFunc) {
 		b.bot.metr.recordHandlerStart()
 
 		ctx := b.newContext(c)
+		ctx.deadline = newHandlerDeadline(b.baseContext(), b.handlerTimeout, func(timeout time.Duration) {
+			b.handlerTimedOut(ctx, timeout)
+		})
+		stopChatAction := b.startChatAction(ctx)
 		defer func() {
+			stopChatAction()
+			ctx.deadline.finish()
+
 			if b.logUpdates {
 				upd := c.Update()
 				b.logUpdate(&upd, ctx.user)
@@ -321,6 +407,19 @@ func (b *Bot) Handle(endpoint any, f HandlerFunc) {
 
 		defer lang.RecoverWithErrAndStack(b.bot.log, &err)
 
+		// Check the signature before any dispatch, including restart recovery in initUserHandler.
+		if !b.verifyCallback(ctx) {
+			b.answerDefault(ctx)
+			return nil
+		}
+
+		handle, done := b.debounceTap(ctx)
+		defer done()
+		if !handle {
+			b.answerDefault(ctx)
+			return nil
+		}
+
 		// If chat is private run user flow
 		if ctx.user != nil {
 			msgID := ctx.MessageID()
@@ -349,12 +448,8 @@ func (b *Bot) Handle(endpoint any, f HandlerFunc) {
 			}
 		}
 
-		if c.Callback() != nil {
-			if err = c.Respond(&tele.CallbackResponse{}); err != nil {
-				b.bot.log.Debug("failed to respond to callback", "error", err.Error())
-				b.bot.metr.incError(MetricsErrorTelegramAPI, MetricsErrorSeverityLow)
-			}
-		}
+		// Handlers may answer the callback themselves with Context.Answer.
+		b.answerDefault(ctx)
 
 		return nil
 	})
@@ -362,8 +457,10 @@ func (b *Bot) Handle(endpoint any, f HandlerFunc) {
 
 // SetTextHandler sets handler for text messages.
 // You should provide a single handler for all text messages, that will call another handlers based on the state.
+// States registered with [StateNode.OnText] are dispatched to their own hooks, the handler gets the rest.
 func (b *Bot) SetTextHandler(handler HandlerFunc) {
-	b.Handle(tele.OnText, handler)
+	b.textHandler = handler
+	b.Handle(tele.OnText, b.textRouter)
 }
 
 // SetMessageProvider sets message provider.
@@ -427,9 +524,12 @@ func (b *Bot) initUserHandler(ctx *contextImpl, msgID int) error {
 	ctx.callbackHandled = true
 
 	return targetHandler.Handler(&contextImpl{
-		bt:   b,
-		ct:   b.bot.tbot.NewContext(upd),
-		user: ctx.user,
+		bt:         b,
+		ct:         b.bot.tbot.NewContext(upd),
+		user:       ctx.user,
+		cbAnswer:   ctx.cbAnswer,
+		deadline:   ctx.deadline,
+		chatAction: ctx.chatAction,
 	})
 }
 
@@ -453,6 +553,9 @@ func (b *Bot) callbackFallbackHandler(ctx Context) error {
 	if btnID == "" {
 		return nil
 	}
+	if !b.verifyCallback(ctxImpl) {
+		return nil
+	}
 
 	// 1. Per-user buttonMap (only for tracked, non-public users with an initialized map).
 	if u := ctxImpl.user; u != nil && !u.isPublic && u.buttonMap != nil {
@@ -468,9 +571,12 @@ func (b *Bot) callbackFallbackHandler(ctx Context) error {
 				},
 			}
 			return targetHandler.Handler(&contextImpl{
-				bt:   b,
-				ct:   b.bot.tbot.NewContext(upd),
-				user: u,
+				bt:         b,
+				ct:         b.bot.tbot.NewContext(upd),
+				user:       u,
+				cbAnswer:   ctxImpl.cbAnswer,
+				deadline:   ctxImpl.deadline,
+				chatAction: ctxImpl.chatAction,
 			})
 		}
 	}
@@ -546,6 +652,8 @@ func (b *Bot) cleanMiddleware(upd *tele.Update, userRaw User) bool {
 		return false
 	}
 
+	b.expirer.expireOverdue(user)
+
 	msgIDs := user.Messages()
 	if msgIDs.ErrorID > 0 {
 		err := b.bot.delete(user.ID(), msgIDs.ErrorID)
@@ -854,6 +962,7 @@ func (b *Bot) sendError(userID int64, msg string, opts ...any) {
 		b.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
 		return
 	}
+	ttl, opts := b.expirer.ttl(expiringError, opts)
 	msgs := user.Messages()
 	if msgID := msgs.ErrorID; msgID != 0 {
 		err := b.bot.delete(user.ID(), msgID)
@@ -863,14 +972,15 @@ func (b *Bot) sendError(userID int64, msg string, opts ...any) {
 	}
 	closeBtn := b.msgs.Messages(user.Language()).CloseBtn()
 	if closeBtn != "" {
-		btnID, unique := getBtnIDAndUnique(closeBtn)
+		btnID, unique := getBtnIDAndUnique(closeErrorButtonID)
 		btn := tele.Btn{
 			Unique: unique,
 			Text:   closeBtn,
+			Data:   b.buttonData(btnID, MaxDataLengthBytes-len(unique)-2),
 		}
 		opts = append(opts, SingleRow(btn))
 		// Register in the stateless callback router (thread-safe and idempotent per
-		// button text) instead of telebot's handler map: writing that map at runtime
+		// button id) instead of telebot's handler map: writing that map at runtime
 		// races with dispatch reads (fatal concurrent map access) and every
 		// registration would leak a new entry because of the random unique suffix.
 		b.callbackRouter.Set(btnID, b.closeErrorMessage)
@@ -880,7 +990,7 @@ func (b *Bot) sendError(userID int64, msg string, opts ...any) {
 		b.bot.log.Error("failed to send error message", "user_id", prepareUserID(userID, b.um.priv), "error", err.Error())
 		return
 	}
-	user.setErrorMessage(msgID)
+	b.expirer.track(user, expiringError, msgID, ttl)
 }
 
 // liveCallbackData returns the payload of the button the user actually tapped, falling
@@ -926,12 +1036,20 @@ var (
 
 type baseBot struct {
 	tbot *tele.Bot
+	thr  *throttler
 	metr *metrics
 	log  Logger
 
 	priv           PrivacyMode
+	parseMode      tele.ParseMode
 	defaultOptions []any
 	middlewares    map[tele.ChatType][]func(upd *tele.Update) bool
+	serial         *serialDispatcher
+	pool           *workerPool
+	actions        *chatActions
+	rec            *recorder
+	inbox          *webhookInbox
+	onHandled      func(upd *tele.Update)
 }
 
 func newBaseBot(ctx context.Context, token string, opts Options) (*baseBot, error) {
@@ -939,18 +1057,58 @@ func newBaseBot(ctx context.Context, token string, opts Options) (*baseBot, erro
 		metr:           opts.metrics,
 		log:            opts.Logger,
 		priv:           opts.Config.Bot.Privacy.Mode,
+		parseMode:      opts.Config.Bot.ParseMode,
 		defaultOptions: []any{opts.Config.Bot.ParseMode},
 		middlewares:    make(map[tele.ChatType][]func(upd *tele.Update) bool),
+		onHandled:      opts.OnUpdateHandled,
 	}
+	b.actions = newChatActions(b)
 
 	if opts.Config.Bot.NoPreview {
 		b.defaultOptions = append(b.defaultOptions, tele.NoPreview)
 	}
 
+	thr, err := newThrottler(opts.Config.Throttle, opts.OutgoingLimiter, opts.metrics, opts.Logger, b.priv)
+	if err != nil {
+		return nil, erro.Wrap(err, "new throttler")
+	}
+	b.thr = thr
+
+	if wp, ok := opts.Poller.(*webhookPoller); ok {
+		b.inbox = wp.inbox
+	}
+
+	filter := b.asyncMiddleware
+	if opts.Synchronous {
+		filter = b.syncMiddleware
+	}
+	if opts.Config.Pool.Enabled {
+		b.pool = newWorkerPool(ctx, b, opts.Config.Pool)
+		filter = b.pool.middleware
+	}
+	if opts.Config.Serial.Enabled {
+		b.serial = newSerialDispatcher(b, opts.Config.Serial)
+		filter = b.serial.middleware
+	}
+
+	if opts.Config.Record.Path != "" {
+		b.rec, err = newRecorder(b, opts.Config.Record, opts.KeysProvider)
+		if err != nil {
+			return nil, erro.Wrap(err, "new recorder")
+		}
+		filter = b.rec.middleware(filter)
+	}
+
+	client := &http.Client{Timeout: 2 * opts.Config.LongPolling.Timeout}
+	if opts.Config.Mode == PollingModeReplay && opts.APIURL == "" {
+		client.Transport = newOfflineTransport(b.log)
+	}
+
 	bot, err := tele.NewBot(ctx, tele.Settings{
+		URL:    opts.APIURL,
 		Token:  token,
-		Poller: tele.NewMiddlewarePoller(opts.Poller, b.middleware),
-		Client: &http.Client{Timeout: 2 * opts.Config.LongPolling.Timeout},
+		Poller: tele.NewMiddlewarePoller(opts.Poller, filter),
+		Client: client,
 		OnError: func(err error, ctx tele.Context) {
 			var userID int64
 			if ctx != nil && ctx.Chat() != nil {
@@ -960,10 +1118,16 @@ func newBaseBot(ctx context.Context, token string, opts Options) (*baseBot, erro
 			b.metr.incError(MetricsErrorHandler, MetricsErrorSeverityHigh)
 		},
 		Updates: defaultUpdatesChannelCapacity,
-		Verbose: opts.Config.Log.DebugIncomingUpdates,
-		Offline: opts.Offline,
+		// Updates are handled by the filter of the poller: in goroutines of per-user queues,
+		// workers of the pool or goroutines per update.
+		Synchronous: true,
+		Verbose:     opts.Config.Log.DebugIncomingUpdates,
+		Offline:     opts.Offline,
 	})
 	if err != nil {
+		if b.rec != nil {
+			_ = b.rec.close()
+		}
 		return nil, erro.Wrap(err, "new telebot")
 	}
 	b.tbot = bot
@@ -971,6 +1135,51 @@ func newBaseBot(ctx context.Context, token string, opts Options) (*baseBot, erro
 	return b, nil
 }
 
+// processUpdate runs handlers of the update, telebot is synchronous when it is called,
+// so the update is marked as handled after handlers return.
+func (b *baseBot) processUpdate(upd tele.Update) {
+	defer b.handled(&upd)
+	b.tbot.ProcessUpdate(upd)
+}
+
+// handled marks the update as handled in the webhook inbox and calls [Options.OnUpdateHandled].
+// It is called once for every update: after its handlers return or when it is dropped.
+func (b *baseBot) handled(upd *tele.Update) {
+	b.inbox.done(upd.ID)
+	if b.onHandled == nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			b.log.Error("panic in OnUpdateHandled callback", "panic", r, "update_id", upd.ID)
+		}
+	}()
+	b.onHandled(upd)
+}
+
+// asyncMiddleware passes the update through bot middlewares and handles it in a separate goroutine.
+// It always returns false: the update is processed by the goroutine, not by the poller.
+func (b *baseBot) asyncMiddleware(upd *tele.Update) bool {
+	if !b.middleware(upd) {
+		b.handled(upd)
+		return false
+	}
+	u := *upd
+	lang.Go(b.log, func() { b.processUpdate(u) })
+	return false
+}
+
+// syncMiddleware passes the update through bot middlewares and handles it in the goroutine of the poller.
+// It always returns false: the update is already processed when the poller gets the result.
+func (b *baseBot) syncMiddleware(upd *tele.Update) bool {
+	if !b.middleware(upd) {
+		b.handled(upd)
+		return false
+	}
+	b.processUpdate(*upd)
+	return false
+}
+
 func (b *baseBot) addMiddleware(f MiddlewareFuncTele, chatType ...tele.ChatType) {
 	if len(chatType) == 0 {
 		chatType = allChatTypes
@@ -985,11 +1194,25 @@ func (b *baseBot) handle(endpoint any, handler tele.HandlerFunc) {
 }
 
 func (b *baseBot) send(userID int64, msg string, options ...any) (int, error) {
+	return b.sendWith(b.thr.do, userID, msg, options...)
+}
+
+// sendOnce sends a message without retries of flood errors, the caller retries them itself.
+func (b *baseBot) sendOnce(userID int64, msg string, options ...any) (int, error) {
+	return b.sendWith(b.thr.doOnce, userID, msg, options...)
+}
+
+func (b *baseBot) sendWith(do func(method string, chatID int64, f func() error) error, userID int64, msg string, options ...any) (int, error) {
 	if userID == 0 {
 		return 0, errEmptyUserID
 	}
 
-	m, err := b.tbot.Send(userIDWrapper(userID), msg, append(options, b.defaultOptions...)...)
+	var m *tele.Message
+	err := do("send", userID, func() (err error) {
+		m, err = b.tbot.Send(userIDWrapper(userID), msg, append(options, b.defaultOptions...)...)
+		return err
+	})
+	b.actions.sent(userID)
 	if err != nil {
 		b.metr.incError(MetricsErrorTelegramAPI, MetricsErrorSeverityLow)
 		return 0, err
@@ -1007,7 +1230,11 @@ func (b *baseBot) sendDraft(userID int64, draftID int, text string, options ...a
 		return errEmptyUserID
 	}
 
-	if err := b.tbot.SendDraft(userIDWrapper(userID), draftID, text, options...); err != nil {
+	err := b.thr.doOnce("draft", userID, func() error {
+		return b.tbot.SendDraft(userIDWrapper(userID), draftID, text, options...)
+	})
+	b.actions.sent(userID)
+	if err != nil {
 		b.metr.incError(MetricsErrorTelegramAPI, MetricsErrorSeverityLow)
 		return err
 	}
@@ -1027,7 +1254,12 @@ func (b *baseBot) sendRich(userID int64, rich *tele.InputRichMessage, options ..
 		return 0, errEmptyRichMessage
 	}
 
-	m, err := b.tbot.Send(userIDWrapper(userID), rich, append(options, b.defaultOptions...)...)
+	var m *tele.Message
+	err := b.thr.do("send", userID, func() (err error) {
+		m, err = b.tbot.Send(userIDWrapper(userID), rich, append(options, b.defaultOptions...)...)
+		return err
+	})
+	b.actions.sent(userID)
 	if err != nil {
 		b.metr.incError(MetricsErrorTelegramAPI, MetricsErrorSeverityLow)
 		return 0, err
@@ -1052,7 +1284,11 @@ func (b *baseBot) sendRichDraft(userID int64, draftID int, rich *tele.InputRichM
 		return errEmptyRichMessage
 	}
 
-	if err := b.tbot.SendRichDraft(userIDWrapper(userID), draftID, rich, options...); err != nil {
+	err := b.thr.doOnce("draft", userID, func() error {
+		return b.tbot.SendRichDraft(userIDWrapper(userID), draftID, rich, options...)
+	})
+	b.actions.sent(userID)
+	if err != nil {
 		b.metr.incError(MetricsErrorTelegramAPI, MetricsErrorSeverityLow)
 		return err
 	}
@@ -1070,7 +1306,12 @@ func (b *baseBot) sendFile(userID int64, file []byte, name string, options ...an
 		FileName: name,
 	}
 
-	m, err := b.tbot.Send(userIDWrapper(userID), doc, append(options, b.defaultOptions...)...)
+	var m *tele.Message
+	err := b.thr.do("send", userID, func() (err error) {
+		m, err = b.tbot.Send(userIDWrapper(userID), doc, append(options, b.defaultOptions...)...)
+		return err
+	})
+	b.actions.sent(userID)
 	if err != nil {
 		b.metr.incError(MetricsErrorTelegramAPI, MetricsErrorSeverityLow)
 		return 0, err
@@ -1089,7 +1330,11 @@ func (b *baseBot) edit(userID int64, msgID int, what any, options ...any) error
 		return errEmptyMsgID
 	}
 
-	_, err := b.tbot.Edit(getEditable(userID, msgID), what, append(options, b.defaultOptions...)...)
+	err := b.thr.do("edit", userID, func() error {
+		_, err := b.tbot.Edit(getEditable(userID, msgID), what, append(options, b.defaultOptions...)...)
+		return err
+	})
+	b.actions.sent(userID)
 	if err != nil {
 		if strings.Contains(err.Error(), "message is not modified") {
 			b.log.Debug("message is not modified", "msg_id", msgID, "user_id", prepareUserID(userID, b.priv))
@@ -1104,6 +1349,16 @@ func (b *baseBot) edit(userID int64, msgID int, what any, options ...any) error
 	return nil
 }
 
+// notify sends a chat action, e.g. "typing…", that is shown for 5 seconds or until a message is sent.
+func (b *baseBot) notify(chatID int64, action tele.ChatAction) error {
+	if chatID == 0 {
+		return errEmptyUserID
+	}
+	return b.thr.do("notify", chatID, func() error {
+		return b.tbot.Notify(userIDWrapper(chatID), action)
+	})
+}
+
 func (b *baseBot) editReplyMarkup(userID int64, msgID int, markup *tele.ReplyMarkup) error {
 	if userID == 0 {
 		return errEmptyUserID
@@ -1112,7 +1367,10 @@ func (b *baseBot) editReplyMarkup(userID int64, msgID int, markup *tele.ReplyMar
 		return errEmptyMsgID
 	}
 
-	_, err := b.tbot.EditReplyMarkup(getEditable(userID, msgID), markup)
+	err := b.thr.do("edit", userID, func() error {
+		_, err := b.tbot.EditReplyMarkup(getEditable(userID, msgID), markup)
+		return err
+	})
 	if err != nil {
 		b.metr.incError(MetricsErrorTelegramAPI, MetricsErrorSeverityLow)
 		return err
@@ -1135,7 +1393,11 @@ func (b *baseBot) delete(userID int64, msgIDs ...int) error {
 			errSet.Add(errEmptyMsgID)
 			continue
 		}
-		if err := b.tbot.Delete(getEditable(userID, msgID)); err != nil {
+		// Deletions are limited only globally: deleteHistory removes hundreds of messages in a chat
+		err := b.thr.do("delete", 0, func() error {
+			return b.tbot.Delete(getEditable(userID, msgID))
+		})
+		if err != nil {
 			b.metr.incError(MetricsErrorTelegramAPI, MetricsErrorSeverityLow)
 			errSet.Add(err)
 			continue
@@ -0,0 +1,409 @@
+package botetest
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maxbolgarin/bote"
+	"github.com/maxbolgarin/lang"
+	tele "github.com/maxbolgarin/telebot/v4"
+)
+
+// TestToken is a token of the emulated bot.
+const TestToken = "1000:test-token"
+
+const (
+	stopTimeout   = 5 * time.Second
+	handleTimeout = 10 * time.Second
+)
+
+// Option is an option of [New].
+type Option func(cfg *harnessConfig)
+
+type harnessConfig struct {
+	setup    []func(b *bote.Bot)
+	stateMap map[bote.State]bote.InitBundle
+	botOpts  []func(opts *bote.Options)
+}
+
+// WithSetup returns an option that calls f after the bot is created and before it is started.
+// Use it to register handlers, middlewares and jobs.
+func WithSetup(f func(b *bote.Bot)) Option {
+	return func(cfg *harnessConfig) {
+		cfg.setup = append(cfg.setup, f)
+	}
+}
+
+// WithStateMap returns an option that sets a state map passed to [bote.Bot.Start].
+func WithStateMap(stateMap map[bote.State]bote.InitBundle) Option {
+	return func(cfg *harnessConfig) {
+		cfg.stateMap = stateMap
+	}
+}
+
+// WithBotOptions returns an option that applies bote options to the bot.
+// API URL, poller and synchronous mode are always set by the harness.
+func WithBotOptions(optsFuncs ...func(opts *bote.Options)) Option {
+	return func(cfg *harnessConfig) {
+		cfg.botOpts = append(cfg.botOpts, optsFuncs...)
+	}
+}
+
+// Harness is a started bot connected to an emulator of Telegram Bot API.
+type Harness struct {
+	t      testing.TB
+	bot    *bote.Bot
+	srv    *Server
+	poller *chanPoller
+
+	updateID atomic.Int64
+	callID   atomic.Int64
+
+	mu    sync.Mutex
+	users map[int64]*User
+}
+
+// New creates a bot with the start handler, connects it to a new [Server] and starts it.
+// The bot is stopped and the server is closed when the test finishes.
+func New(t testing.TB, start bote.HandlerFunc, optsFuncs ...Option) *Harness {
+	t.Helper()
+
+	var cfg harnessConfig
+	for _, f := range optsFuncs {
+		f(&cfg)
+	}
+
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+
+	var opts bote.Options
+	opts.Config.Log.Enable = lang.Ptr(false)
+	opts.Config.Log.LogUpdates = lang.Ptr(false)
+	bote.WithoutThrottle()(&opts)
+	for _, f := range cfg.botOpts {
+		f(&opts)
+	}
+	bote.WithAPIURL(srv.URL())(&opts)
+	bote.WithSynchronous()(&opts)
+
+	poller := newChanPoller()
+	bote.WithCustomPoller(poller)(&opts)
+	onHandled := opts.OnUpdateHandled
+	opts.OnUpdateHandled = func(upd *tele.Update) {
+		if onHandled != nil {
+			onHandled(upd)
+		}
+		poller.handled(upd)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b, err := bote.NewWithOptions(ctx, TestToken, opts)
+	if err != nil {
+		cancel()
+		t.Fatalf("botetest: create bot: %v", err)
+	}
+	for _, f := range cfg.setup {
+		f(b)
+	}
+
+	stopCh := b.Start(ctx, start, cfg.stateMap)
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case <-stopCh:
+		case <-time.After(stopTimeout):
+			t.Errorf("botetest: bot is not stopped in %s", stopTimeout)
+		}
+	})
+
+	return &Harness{
+		t:      t,
+		bot:    b,
+		srv:    srv,
+		poller: poller,
+		users:  make(map[int64]*User),
+	}
+}
+
+// Bot returns the bot under test.
+func (h *Harness) Bot() *bote.Bot {
+	return h.bot
+}
+
+// Server returns the emulator of Telegram Bot API.
+func (h *Harness) Server() *Server {
+	return h.srv
+}
+
+// Replay handles updates from a recording made with [bote.WithRecorder] one by one.
+// If userID is provided, only updates of this user are replayed, see [bote.ReadRecording].
+func (h *Harness) Replay(path string, userID ...int64) {
+	h.t.Helper()
+
+	recs, err := bote.ReadRecording(path, userID...)
+	if err != nil {
+		h.t.Fatalf("botetest: read recording: %v", err)
+		return
+	}
+	for _, rec := range recs {
+		h.send(rec.Update)
+	}
+}
+
+// send passes the update to the bot through the poller and waits until the bot handles it.
+func (h *Harness) send(upd tele.Update) {
+	h.t.Helper()
+
+	if !h.poller.send(upd, handleTimeout) {
+		h.t.Fatalf("botetest: update %d is not handled in %s", upd.ID, handleTimeout)
+	}
+}
+
+// User returns a user with the ID that chats with the bot in a private chat.
+func (h *Harness) User(id int64) *User {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	u, ok := h.users[id]
+	if !ok {
+		u = &User{h: h, id: id, tuser: &tele.User{
+			ID:           id,
+			FirstName:    "User" + strconv.FormatInt(id, 10),
+			Username:     "user" + strconv.FormatInt(id, 10),
+			LanguageCode: "en",
+		}}
+		h.users[id] = u
+	}
+	return u
+}
+
+// User is a Telegram user that sends messages and taps buttons in a private chat with the bot.
+type User struct {
+	h     *Harness
+	id    int64
+	tuser *tele.User
+}
+
+// ID returns Telegram ID of the user, it is also an ID of the private chat.
+func (u *User) ID() int64 {
+	return u.id
+}
+
+// Send sends a text message from the user and returns after the bot handles it.
+func (u *User) Send(text string) {
+	u.h.t.Helper()
+
+	msgID := u.h.srv.addUserMessage(u.id, text)
+	u.h.send(tele.Update{
+		ID: int(u.h.updateID.Add(1)),
+		Message: &tele.Message{
+			ID:       msgID,
+			Sender:   u.tuser,
+			Chat:     u.chat(),
+			Text:     text,
+			Unixtime: time.Now().Unix(),
+		},
+	})
+}
+
+// Tap taps a button with the text in the newest message of the bot that has it and returns after the bot handles it.
+// Tapping an inline button sends a callback, tapping a reply button sends its text.
+// It fails the test if there is no such button or it is a URL button.
+func (u *User) Tap(text string) {
+	u.h.t.Helper()
+
+	msg, btn, ok := u.findButton(text)
+	if !ok {
+		u.h.t.Fatalf("botetest: button %q is not found in messages of user %d", text, u.id)
+		return
+	}
+	if !btn.Inline {
+		u.Send(btn.Text)
+		return
+	}
+	if btn.Data == "" {
+		u.h.t.Fatalf("botetest: button %q has no callback data", text)
+		return
+	}
+
+	u.h.send(tele.Update{
+		ID: int(u.h.updateID.Add(1)),
+		Callback: &tele.Callback{
+			ID:     strconv.FormatInt(u.h.callID.Add(1), 10),
+			Sender: u.tuser,
+			Message: &tele.Message{
+				ID:       msg.ID,
+				Sender:   &tele.User{ID: BotID, IsBot: true, FirstName: "Test Bot", Username: "test_bot"},
+				Chat:     u.chat(),
+				Text:     msg.Text,
+				Unixtime: time.Now().Unix(),
+			},
+			Data: btn.Data,
+		},
+	})
+}
+
+// Messages returns visible messages of the chat with the bot, both of the bot and the user.
+func (u *User) Messages() []Message {
+	return u.h.srv.Messages(u.id)
+}
+
+// LastMessage returns the newest visible message of the bot.
+func (u *User) LastMessage() (Message, bool) {
+	msgs := u.Messages()
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].FromBot {
+			return msgs[i], true
+		}
+	}
+	return Message{}, false
+}
+
+// Main returns the main message of the user, see [bote.UserMessages].
+func (u *User) Main() (Message, bool) {
+	bu := u.BoteUser()
+	if bu == nil {
+		return Message{}, false
+	}
+	mainID := bu.Messages().MainID
+	if mainID == 0 {
+		return Message{}, false
+	}
+	return u.h.srv.Message(u.id, mainID)
+}
+
+// MainText returns a text of the main message or an empty string if there is no main message.
+func (u *User) MainText() string {
+	msg, _ := u.Main()
+	return msg.Text
+}
+
+// MainKeyboard returns a keyboard of the main message.
+func (u *User) MainKeyboard() [][]Button {
+	msg, _ := u.Main()
+	return msg.Keyboard
+}
+
+// StateMain returns a state of the main message or an empty state if the user has not been created yet.
+func (u *User) StateMain() bote.State {
+	bu := u.BoteUser()
+	if bu == nil {
+		return bote.NoChange
+	}
+	return bu.StateMain()
+}
+
+// LastAnswer returns the newest answer of the bot to a tap on an inline button.
+func (u *User) LastAnswer() (CallbackAnswer, bool) {
+	answers := u.h.srv.Answers()
+	if len(answers) == 0 {
+		return CallbackAnswer{}, false
+	}
+	return answers[len(answers)-1], true
+}
+
+// BoteUser returns the user from the cache of the bot or nil if the bot has not seen the user yet.
+func (u *User) BoteUser() bote.User {
+	for _, bu := range u.h.bot.GetAllUsersFromCache() {
+		if bu.ID() == u.id {
+			return bu
+		}
+	}
+	return nil
+}
+
+func (u *User) chat() *tele.Chat {
+	return &tele.Chat{ID: u.id, Type: tele.ChatPrivate, FirstName: u.tuser.FirstName, Username: u.tuser.Username}
+}
+
+func (u *User) findButton(text string) (Message, Button, bool) {
+	msgs := u.Messages()
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if !msgs[i].FromBot {
+			continue
+		}
+		for _, row := range msgs[i].Keyboard {
+			for _, btn := range row {
+				if btn.Text == text {
+					return msgs[i], btn, true
+				}
+			}
+		}
+	}
+	return Message{}, Button{}, false
+}
+
+// chanPoller passes updates sent by the harness to the bot, so they go through the same filters
+// as updates from Telegram: recorder, per-user queues, worker pool and middlewares.
+type chanPoller struct {
+	updates chan tele.Update
+
+	mu      sync.Mutex
+	waiters map[int]chan struct{}
+}
+
+func newChanPoller() *chanPoller {
+	return &chanPoller{
+		updates: make(chan tele.Update),
+		waiters: make(map[int]chan struct{}),
+	}
+}
+
+func (p *chanPoller) Poll(_ *tele.Bot, dest chan tele.Update, stop chan struct{}) {
+	for {
+		select {
+		case upd := <-p.updates:
+			select {
+			case dest <- upd:
+			case <-stop:
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// send passes the update to the bot and returns true after the bot handles it,
+// it returns false if the update is not handled in time.
+func (p *chanPoller) send(upd tele.Update, timeout time.Duration) bool {
+	done := make(chan struct{})
+	p.mu.Lock()
+	p.waiters[upd.ID] = done
+	p.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case p.updates <- upd:
+		select {
+		case <-done:
+			return true
+		case <-timer.C:
+		}
+	case <-timer.C:
+	}
+
+	p.mu.Lock()
+	delete(p.waiters, upd.ID)
+	p.mu.Unlock()
+	return false
+}
+
+// handled is called by the bot when handlers of the update return or the update is dropped.
+func (p *chanPoller) handled(upd *tele.Update) {
+	p.mu.Lock()
+	done, ok := p.waiters[upd.ID]
+	delete(p.waiters, upd.ID)
+	p.mu.Unlock()
+
+	if ok {
+		close(done)
+	}
+}
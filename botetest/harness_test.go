@@ -0,0 +1,143 @@
+package botetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxbolgarin/bote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type taskState string
+
+const (
+	stateMenu         taskState = "menu"
+	stateAwaitingTask taskState = "awaiting_task"
+)
+
+func (s taskState) String() string   { return string(s) }
+func (s taskState) IsText() bool     { return s == stateAwaitingTask }
+func (s taskState) NotChanged() bool { return s == "" }
+
+func menuKeyboard(ctx bote.Context) *bote.Keyboard {
+	kb := bote.NewKeyboard(1)
+	kb.Add(ctx.Btn("Add Task", func(ctx bote.Context) error {
+		return ctx.EditMain(stateAwaitingTask, "Enter your task:", nil)
+	}))
+	kb.Add(ctx.Btn("Ping", func(ctx bote.Context) error {
+		return ctx.Answer("pong", false)
+	}))
+	return kb
+}
+
+func startTasks(ctx bote.Context) error {
+	return ctx.SendMain(stateMenu, "Tasks", menuKeyboard(ctx).CreateInlineMarkup())
+}
+
+func newTasksHarness(t *testing.T, optsFuncs ...Option) *Harness {
+	return New(t, startTasks, append(optsFuncs, WithSetup(func(b *bote.Bot) {
+		b.SetTextHandler(func(ctx bote.Context) error {
+			if ctx.User().StateMain() != stateAwaitingTask {
+				return nil
+			}
+			return ctx.EditMain(stateMenu, "Added: "+ctx.Text(), menuKeyboard(ctx).CreateInlineMarkup())
+		})
+	}))...)
+}
+
+func TestHarness(t *testing.T) {
+	h := newTasksHarness(t)
+	u := h.User(42)
+
+	u.Send("/start")
+	assert.Equal(t, "Tasks", u.MainText())
+	assert.Equal(t, stateMenu.String(), u.StateMain().String())
+	require.Len(t, u.MainKeyboard(), 2)
+	assert.Equal(t, "Add Task", u.MainKeyboard()[0][0].Text)
+
+	u.Tap("Add Task")
+	assert.Equal(t, "Enter your task:", u.MainText())
+	assert.Empty(t, u.MainKeyboard())
+	assert.Equal(t, stateAwaitingTask.String(), u.StateMain().String())
+
+	main, ok := u.Main()
+	require.True(t, ok)
+	assert.Equal(t, 1, main.Edits)
+
+	u.Send("buy milk")
+	assert.Equal(t, "Added: buy milk", u.MainText())
+	assert.Equal(t, stateMenu.String(), u.StateMain().String())
+
+	u.Tap("Ping")
+	answer, ok := u.LastAnswer()
+	require.True(t, ok)
+	assert.Equal(t, "pong", answer.Text)
+}
+
+// TestHarnessQueues verifies updates of the harness go through per-user queues and the worker pool.
+func TestHarnessQueues(t *testing.T) {
+	h := newTasksHarness(t, WithBotOptions(bote.WithSerialUpdates(10), bote.WithWorkerPool(2, 10)))
+	u := h.User(42)
+
+	u.Send("/start")
+	assert.Equal(t, "Tasks", u.MainText())
+
+	u.Tap("Add Task")
+	u.Send("buy milk")
+	assert.Equal(t, "Added: buy milk", u.MainText())
+}
+
+// TestHarnessStreamTimeout verifies a stream interrupted by the handler deadline commits the generated text.
+func TestHarnessStreamTimeout(t *testing.T) {
+	chunks := make(chan string, 1)
+	chunks <- "partial answer"
+	h := New(t, func(ctx bote.Context) error {
+		return ctx.Stream(stateMenu, chunks, nil)
+	}, WithBotOptions(bote.WithHandlerTimeout(50*time.Millisecond)))
+	u := h.User(42)
+
+	u.Send("/start")
+	assert.Equal(t, "partial answer", u.MainText())
+	assert.Equal(t, stateMenu.String(), u.StateMain().String())
+}
+
+func TestHarnessUsers(t *testing.T) {
+	h := newTasksHarness(t)
+	alice, bob := h.User(1), h.User(2)
+
+	alice.Send("/start")
+	alice.Tap("Add Task")
+	bob.Send("/start")
+
+	assert.Equal(t, "Enter your task:", alice.MainText())
+	assert.Equal(t, "Tasks", bob.MainText())
+	assert.Same(t, alice, h.User(1))
+	assert.Len(t, h.Server().Calls("sendMessage"), 2)
+}
+
+func TestServerMessages(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	id := srv.addUserMessage(7, "hi")
+	msgs := srv.Messages(7)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, id, msgs[0].ID)
+	assert.False(t, msgs[0].FromBot)
+	assert.Empty(t, srv.Messages(8))
+
+	kb, ok := parseKeyboard(`{"inline_keyboard":[[{"text":"A","callback_data":"a"},{"text":"Site","url":"https://example.com"}]]}`)
+	require.True(t, ok)
+	assert.Equal(t, [][]Button{{
+		{Text: "A", Data: "a", Inline: true},
+		{Text: "Site", URL: "https://example.com", Inline: true},
+	}}, kb)
+
+	kb, ok = parseKeyboard(`{"keyboard":[[{"text":"Yes"},{"text":"No"}]],"resize_keyboard":true}`)
+	require.True(t, ok)
+	assert.Equal(t, [][]Button{{{Text: "Yes"}, {Text: "No"}}}, kb)
+
+	_, ok = parseKeyboard("")
+	assert.False(t, ok)
+}
@@ -0,0 +1,346 @@
+package botetest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maxbolgarin/erro"
+	"gopkg.in/yaml.v3"
+)
+
+// UpdateGoldenEnv is an environment variable that makes [Harness.Golden] and [RunScripts] write transcripts
+// to golden files instead of comparing them: BOTETEST_UPDATE=1 go test ./...
+const UpdateGoldenEnv = "BOTETEST_UPDATE"
+
+const (
+	scriptExt = ".yaml"
+	goldenExt = ".golden"
+)
+
+// Script is a scripted conversation of a user with the bot. It can be written in Go or loaded from YAML:
+//
+//	name: add task
+//	user: 42
+//	steps:
+//	  - send: /start
+//	    expect:
+//	      text: Tasks
+//	      state: menu
+//	      keyboard:
+//	        - [Add Task]
+//	        - [Ping]
+//	  - tap: Add Task
+//	    expect:
+//	      text: "Enter your task:"
+//	      keyboard: []
+type Script struct {
+	// Name is a name of the script used in failure messages.
+	Name string `yaml:"name" json:"name"`
+	// User is an ID of the user, 1 is used if it is empty.
+	User int64 `yaml:"user" json:"user"`
+	// Steps are actions of the user in order.
+	Steps []Step `yaml:"steps" json:"steps"`
+}
+
+// Step is an action of the user and an optional expectation of the screen after it.
+// Exactly one of Send and Tap should be set.
+type Step struct {
+	// Send is a text the user sends.
+	Send string `yaml:"send,omitempty" json:"send,omitempty"`
+	// Tap is a text of a button the user taps.
+	Tap string `yaml:"tap,omitempty" json:"tap,omitempty"`
+	// Expect is checked after the bot handles the action, nil means no checks.
+	Expect *Expect `yaml:"expect,omitempty" json:"expect,omitempty"`
+}
+
+// Expect describes the main message of the user. Empty fields are not checked.
+type Expect struct {
+	// Text is a text of the main message.
+	Text string `yaml:"text,omitempty" json:"text,omitempty"`
+	// State is a state of the main message from [bote.User.StateMain].
+	State string `yaml:"state,omitempty" json:"state,omitempty"`
+	// Keyboard is texts of buttons of the main message by rows.
+	// Nil is not checked, an empty non-nil slice (keyboard: [] in YAML) expects no keyboard.
+	Keyboard [][]string `yaml:"keyboard,omitempty" json:"keyboard,omitempty"`
+}
+
+// Send returns a step that sends the text.
+func Send(text string, expect ...Expect) Step {
+	return Step{Send: text, Expect: firstExpect(expect)}
+}
+
+// Tap returns a step that taps a button with the text.
+func Tap(text string, expect ...Expect) Step {
+	return Step{Tap: text, Expect: firstExpect(expect)}
+}
+
+// LoadScript reads a script from a YAML file.
+func LoadScript(path string) (Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Script{}, erro.Wrap(err, "read script")
+	}
+	return ParseScript(data)
+}
+
+// ParseScript parses a script from YAML.
+func ParseScript(data []byte) (Script, error) {
+	var s Script
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return Script{}, erro.Wrap(err, "parse script")
+	}
+	if err := s.validate(); err != nil {
+		return Script{}, err
+	}
+	return s, nil
+}
+
+// Run runs the script and returns its transcript: every action with the main message after it,
+// other messages sent by the bot and answers to taps. Failed expectations fail the test.
+func (h *Harness) Run(s Script) string {
+	h.t.Helper()
+
+	if err := s.validate(); err != nil {
+		h.t.Fatalf("botetest: script %q: %v", s.Name, err)
+		return ""
+	}
+
+	u := h.User(s.userID())
+	var tr strings.Builder
+
+	for i, step := range s.Steps {
+		lastID := lastMessageID(u.Messages())
+		answers := len(h.srv.Answers())
+
+		if step.Send != "" {
+			tr.WriteString(">> send: " + step.Send + "\n")
+			u.Send(step.Send)
+		} else {
+			tr.WriteString(">> tap: " + step.Tap + "\n")
+			u.Tap(step.Tap)
+		}
+
+		main, hasMain := u.Main()
+		if hasMain {
+			tr.WriteString("main [" + u.StateMain().String() + "]\n")
+			writeScreen(&tr, main)
+		} else {
+			tr.WriteString("no main message\n")
+		}
+		for _, msg := range u.Messages() {
+			if msg.FromBot && msg.ID > lastID && msg.ID != main.ID {
+				tr.WriteString("message\n")
+				writeScreen(&tr, msg)
+			}
+		}
+		for _, a := range h.srv.Answers()[answers:] {
+			if a.Text != "" {
+				tr.WriteString("answer: " + a.Text + "\n")
+			}
+		}
+		tr.WriteString("\n")
+
+		if step.Expect != nil {
+			h.check(s.Name, i+1, step, u, main)
+		}
+	}
+
+	return tr.String()
+}
+
+// Golden runs the script and compares its transcript with the golden file.
+// A difference fails the test, the file is written instead if [UpdateGoldenEnv] is set.
+func (h *Harness) Golden(s Script, path string) {
+	h.t.Helper()
+
+	got := h.Run(s)
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			h.t.Fatalf("botetest: create golden dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			h.t.Fatalf("botetest: write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		h.t.Fatalf("botetest: read golden file (run with %s=1 to create it): %v", UpdateGoldenEnv, err)
+		return
+	}
+	if string(want) != got {
+		h.t.Errorf("botetest: transcript of %q differs from %s (run with %s=1 to update):\n%s",
+			s.Name, path, UpdateGoldenEnv, diffLines(string(want), got))
+	}
+}
+
+// RunScripts runs every *.yaml script in the directory as a subtest with a new harness
+// and compares transcripts with golden files next to them (add_task.yaml -> add_task.golden).
+func RunScripts(t *testing.T, dir string, newHarness func(t *testing.T) *Harness) {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+scriptExt))
+	if err != nil {
+		t.Fatalf("botetest: find scripts: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("botetest: no scripts in %s", dir)
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), scriptExt)
+		t.Run(name, func(t *testing.T) {
+			s, err := LoadScript(path)
+			if err != nil {
+				t.Fatalf("botetest: %s: %v", path, err)
+			}
+			if s.Name == "" {
+				s.Name = name
+			}
+			newHarness(t).Golden(s, strings.TrimSuffix(path, scriptExt)+goldenExt)
+		})
+	}
+}
+
+func (h *Harness) check(name string, n int, step Step, u *User, main Message) {
+	h.t.Helper()
+
+	exp := step.Expect
+	action := "send " + step.Send
+	if step.Tap != "" {
+		action = "tap " + step.Tap
+	}
+
+	if exp.Text != "" && exp.Text != main.Text {
+		h.t.Errorf("botetest: script %q, step %d (%s): text is %q, want %q", name, n, action, main.Text, exp.Text)
+	}
+	if exp.State != "" {
+		if state := u.StateMain().String(); state != exp.State {
+			h.t.Errorf("botetest: script %q, step %d (%s): state is %q, want %q", name, n, action, state, exp.State)
+		}
+	}
+	if exp.Keyboard != nil {
+		if got, want := formatKeyboard(keyboardTexts(main.Keyboard)), formatKeyboard(exp.Keyboard); got != want {
+			h.t.Errorf("botetest: script %q, step %d (%s): keyboard is\n%s\nwant\n%s", name, n, action, got, want)
+		}
+	}
+}
+
+func (s Script) validate() error {
+	if len(s.Steps) == 0 {
+		return erro.New("script has no steps")
+	}
+	for i, step := range s.Steps {
+		if (step.Send == "") == (step.Tap == "") {
+			return erro.New("exactly one of send and tap should be set", "step", i+1)
+		}
+	}
+	return nil
+}
+
+func (s Script) userID() int64 {
+	if s.User == 0 {
+		return 1
+	}
+	return s.User
+}
+
+func firstExpect(expect []Expect) *Expect {
+	if len(expect) == 0 {
+		return nil
+	}
+	return &expect[0]
+}
+
+func lastMessageID(msgs []Message) int {
+	if len(msgs) == 0 {
+		return 0
+	}
+	return msgs[len(msgs)-1].ID
+}
+
+// writeScreen writes a text and a keyboard of the message, inline buttons are [text], reply buttons are (text).
+func writeScreen(tr *strings.Builder, msg Message) {
+	for _, line := range strings.Split(msg.Text, "\n") {
+		tr.WriteString("| " + line + "\n")
+	}
+	for _, row := range msg.Keyboard {
+		tr.WriteString("|")
+		for _, btn := range row {
+			switch {
+			case btn.URL != "":
+				tr.WriteString(" [" + btn.Text + " -> " + btn.URL + "]")
+			case btn.Inline:
+				tr.WriteString(" [" + btn.Text + "]")
+			default:
+				tr.WriteString(" (" + btn.Text + ")")
+			}
+		}
+		tr.WriteString("\n")
+	}
+}
+
+func keyboardTexts(kb [][]Button) [][]string {
+	out := make([][]string, 0, len(kb))
+	for _, row := range kb {
+		texts := make([]string, 0, len(row))
+		for _, btn := range row {
+			texts = append(texts, btn.Text)
+		}
+		out = append(out, texts)
+	}
+	return out
+}
+
+func formatKeyboard(kb [][]string) string {
+	if len(kb) == 0 {
+		return "<no keyboard>"
+	}
+	rows := make([]string, 0, len(kb))
+	for _, row := range kb {
+		rows = append(rows, "["+strings.Join(row, "] [")+"]")
+	}
+	return strings.Join(rows, "\n")
+}
+
+// diffLines returns a line diff of want and got, removed lines start with "-", added lines start with "+".
+func diffLines(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+	// lcs[i][j] is a length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	return out.String()
+}
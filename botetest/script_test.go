@@ -0,0 +1,66 @@
+package botetest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunScripts(t *testing.T) {
+	RunScripts(t, filepath.Join("testdata", "scripts"), func(t *testing.T) *Harness {
+		return newTasksHarness(t)
+	})
+}
+
+func TestRunGoScript(t *testing.T) {
+	h := newTasksHarness(t)
+
+	tr := h.Run(Script{
+		Name: "go script",
+		User: 7,
+		Steps: []Step{
+			Send("/start", Expect{Keyboard: [][]string{{"Add Task"}, {"Ping"}}}),
+			Tap("Add Task", Expect{State: "awaiting_task", Keyboard: [][]string{}}),
+		},
+	})
+
+	assert.Equal(t, ">> send: /start\nmain [menu]\n| Tasks\n| [Add Task]\n| [Ping]\n\n"+
+		">> tap: Add Task\nmain [awaiting_task]\n| Enter your task:\n\n", tr)
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	t.Setenv(UpdateGoldenEnv, "1")
+	path := filepath.Join(t.TempDir(), "new", "start.golden")
+
+	newTasksHarness(t).Golden(Script{Steps: []Step{Send("/start")}}, path)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, ">> send: /start\nmain [menu]\n| Tasks\n| [Add Task]\n| [Ping]\n\n", string(data))
+}
+
+func TestParseScript(t *testing.T) {
+	s, err := ParseScript([]byte("user: 5\nsteps:\n  - send: hi\n  - tap: Go\n    expect:\n      keyboard: []\n"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), s.User)
+	require.Len(t, s.Steps, 2)
+	assert.Equal(t, "hi", s.Steps[0].Send)
+	assert.Nil(t, s.Steps[0].Expect)
+	require.NotNil(t, s.Steps[1].Expect)
+	assert.NotNil(t, s.Steps[1].Expect.Keyboard)
+	assert.Empty(t, s.Steps[1].Expect.Keyboard)
+
+	_, err = ParseScript([]byte("steps:\n  - send: hi\n    tap: Go\n"))
+	assert.Error(t, err)
+
+	_, err = ParseScript([]byte("name: empty\n"))
+	assert.Error(t, err)
+}
+
+func TestDiffLines(t *testing.T) {
+	assert.Equal(t, "  a\n- b\n+ c\n  d\n", diffLines("a\nb\nd", "a\nc\nd"))
+	assert.Equal(t, "  a\n+ b\n", diffLines("a", "a\nb"))
+}
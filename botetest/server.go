@@ -0,0 +1,441 @@
+// Package botetest runs a [bote.Bot] against an in-process emulator of Telegram Bot API, so tests can
+// check what the user actually sees: texts, keyboards, edits, deleted messages and answers to taps.
+//
+//	h := botetest.New(t, startHandler, botetest.WithSetup(registerHandlers))
+//	u := h.User(42)
+//	u.Send("/start")
+//	u.Tap("Add Task")
+//	assert.Equal(t, "Enter your task:", u.MainText())
+//
+// Updates are handled synchronously: Send and Tap return after handlers of the update return.
+package botetest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxbolgarin/lang"
+)
+
+// BotID is a Telegram ID of the emulated bot.
+const BotID = 1000
+
+// Message is a message in a chat of the emulator.
+type Message struct {
+	// ID is an ID of the message in the chat.
+	ID int
+	// ChatID is an ID of the chat.
+	ChatID int64
+	// FromBot is true if the message is sent by the bot, false if it is sent by the user.
+	FromBot bool
+	// Text is a text or a caption of the message.
+	Text string
+	// Keyboard is an inline or reply keyboard attached to the message.
+	Keyboard [][]Button
+	// Edits is a number of edits of the message.
+	Edits int
+	// Deleted is true if the message is deleted.
+	Deleted bool
+}
+
+// Button is a button of a keyboard.
+type Button struct {
+	// Text is a text on the button.
+	Text string
+	// Data is callback data of an inline button, it is empty for reply and URL buttons.
+	Data string
+	// URL is a URL of an inline URL button.
+	URL string
+	// Inline is true for buttons of an inline keyboard.
+	Inline bool
+}
+
+// CallbackAnswer is an answer of the bot to a tap on an inline button.
+type CallbackAnswer struct {
+	// CallbackID is an ID of the answered callback.
+	CallbackID string
+	// Text is a text of a toast or an alert, empty for a silent answer.
+	Text string
+	// ShowAlert is true if the text is shown as an alert.
+	ShowAlert bool
+	// URL is a URL opened by the answer.
+	URL string
+}
+
+// Call is a request to the emulator.
+type Call struct {
+	// Method is a name of the Bot API method.
+	Method string
+	// Params are parameters of the request, values are strings.
+	Params map[string]string
+}
+
+// Server is an in-process emulator of Telegram Bot API. It keeps chats with messages of the bot and users.
+// Methods it does not know are answered with true and recorded in calls.
+type Server struct {
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	chats   map[int64]*chat
+	answers []CallbackAnswer
+	calls   []Call
+}
+
+type chat struct {
+	nextID   int
+	messages map[int]*Message
+	action   string
+}
+
+// NewServer starts an emulator, you should call [Server.Close] when it is not needed.
+func NewServer() *Server {
+	s := &Server{chats: make(map[int64]*chat)}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// URL returns a URL of the emulator to use as [bote.Options.APIURL].
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close stops the emulator.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Messages returns messages of the chat that are not deleted, in the order they were sent.
+func (s *Server) Messages(chatID int64) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.chats[chatID]
+	if !ok {
+		return nil
+	}
+	out := make([]Message, 0, len(c.messages))
+	for _, m := range c.messages {
+		if !m.Deleted {
+			out = append(out, copyMessage(m))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Message returns a message of the chat by ID, including deleted ones.
+func (s *Server) Message(chatID int64, msgID int) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.chats[chatID]
+	if !ok {
+		return Message{}, false
+	}
+	m, ok := c.messages[msgID]
+	if !ok {
+		return Message{}, false
+	}
+	return copyMessage(m), true
+}
+
+// ChatAction returns the last chat action sent to the chat.
+func (s *Server) ChatAction(chatID int64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.chats[chatID]; ok {
+		return c.action
+	}
+	return ""
+}
+
+// Answers returns all answers to taps on inline buttons.
+func (s *Server) Answers() []CallbackAnswer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]CallbackAnswer(nil), s.answers...)
+}
+
+// Calls returns all requests with the method or all requests if the method is empty.
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Call
+	for _, c := range s.calls {
+		if method == "" || c.Method == method {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// addUserMessage adds a message of the user to the chat and returns its ID.
+func (s *Server) addUserMessage(chatID int64, text string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addMessageLocked(chatID, false, text, nil).ID
+}
+
+func (s *Server) addMessageLocked(chatID int64, fromBot bool, text string, kb [][]Button) *Message {
+	c := s.chatLocked(chatID)
+	c.nextID++
+	m := &Message{ID: c.nextID, ChatID: chatID, FromBot: fromBot, Text: text, Keyboard: kb}
+	c.messages[m.ID] = m
+	return m
+}
+
+func (s *Server) chatLocked(chatID int64) *chat {
+	c, ok := s.chats[chatID]
+	if !ok {
+		c = &chat{messages: make(map[int]*Message)}
+		s.chats[chatID] = c
+	}
+	return c
+}
+
+// handle serves requests of the form /bot<token>/<method>.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	params, err := readParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = append(s.calls, Call{Method: method, Params: params})
+
+	switch {
+	case method == "getMe":
+		writeResult(w, map[string]any{"id": BotID, "is_bot": true, "first_name": "Test Bot", "username": "test_bot"})
+
+	case method == "answerCallbackQuery":
+		s.answers = append(s.answers, CallbackAnswer{
+			CallbackID: params["callback_query_id"],
+			Text:       params["text"],
+			ShowAlert:  params["show_alert"] == "true",
+			URL:        params["url"],
+		})
+		writeResult(w, true)
+
+	case method == "sendChatAction":
+		chatID, _ := strconv.ParseInt(params["chat_id"], 10, 64)
+		s.chatLocked(chatID).action = params["action"]
+		writeResult(w, true)
+
+	case method == "deleteMessage":
+		m, ok := s.findLocked(params)
+		if !ok || m.Deleted {
+			writeError(w, http.StatusBadRequest, "Bad Request: message to delete not found")
+			return
+		}
+		m.Deleted = true
+		writeResult(w, true)
+
+	case method == "sendMessageDraft" || method == "sendRichMessageDraft":
+		writeResult(w, true)
+
+	case strings.HasPrefix(method, "send") && params["chat_id"] != "":
+		chatID, err := strconv.ParseInt(params["chat_id"], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Bad Request: chat not found")
+			return
+		}
+		kb, _ := parseKeyboard(params["reply_markup"])
+		m := s.addMessageLocked(chatID, true, messageText(params), kb)
+		s.chatLocked(chatID).action = ""
+		writeResult(w, messageJSON(m))
+
+	case strings.HasPrefix(method, "edit"):
+		m, ok := s.findLocked(params)
+		if !ok || m.Deleted {
+			writeError(w, http.StatusBadRequest, "Bad Request: message to edit not found")
+			return
+		}
+		// Like Telegram, an edit without reply_markup removes the inline keyboard.
+		text := lang.Check(messageText(params), m.Text)
+		kb, _ := parseKeyboard(params["reply_markup"])
+		if text == m.Text && reflect.DeepEqual(kb, m.Keyboard) {
+			writeError(w, http.StatusBadRequest, "Bad Request: message is not modified")
+			return
+		}
+		m.Text, m.Keyboard = text, kb
+		m.Edits++
+		writeResult(w, messageJSON(m))
+
+	default:
+		writeResult(w, true)
+	}
+}
+
+func (s *Server) findLocked(params map[string]string) (*Message, bool) {
+	chatID, err := strconv.ParseInt(params["chat_id"], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	msgID, err := strconv.Atoi(params["message_id"])
+	if err != nil {
+		return nil, false
+	}
+	c, ok := s.chats[chatID]
+	if !ok {
+		return nil, false
+	}
+	m, ok := c.messages[msgID]
+	return m, ok
+}
+
+// readParams reads parameters of a JSON, form or multipart request as strings.
+func readParams(r *http.Request) (map[string]string, error) {
+	params := make(map[string]string)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+		for k, v := range r.MultipartForm.Value {
+			params[k] = v[0]
+		}
+
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		for k, v := range r.PostForm {
+			params[k] = v[0]
+		}
+
+	default:
+		var raw map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		for k, v := range raw {
+			params[k] = paramString(v)
+		}
+	}
+
+	return params, nil
+}
+
+func paramString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+func messageText(params map[string]string) string {
+	if text := params["text"]; text != "" {
+		return text
+	}
+	return params["caption"]
+}
+
+type markupJSON struct {
+	InlineKeyboard [][]struct {
+		Text string `json:"text"`
+		Data string `json:"callback_data"`
+		URL  string `json:"url"`
+	} `json:"inline_keyboard"`
+	Keyboard [][]struct {
+		Text string `json:"text"`
+	} `json:"keyboard"`
+	RemoveKeyboard bool `json:"remove_keyboard"`
+}
+
+// parseKeyboard parses reply_markup, it returns false if there is no markup.
+func parseKeyboard(markup string) ([][]Button, bool) {
+	if markup == "" || markup == "null" {
+		return nil, false
+	}
+	var m markupJSON
+	if err := json.Unmarshal([]byte(markup), &m); err != nil {
+		return nil, false
+	}
+
+	var kb [][]Button
+	for _, row := range m.InlineKeyboard {
+		buttons := make([]Button, 0, len(row))
+		for _, b := range row {
+			buttons = append(buttons, Button{Text: b.Text, Data: b.Data, URL: b.URL, Inline: true})
+		}
+		kb = append(kb, buttons)
+	}
+	for _, row := range m.Keyboard {
+		buttons := make([]Button, 0, len(row))
+		for _, b := range row {
+			buttons = append(buttons, Button{Text: b.Text})
+		}
+		kb = append(kb, buttons)
+	}
+	return kb, true
+}
+
+func messageJSON(m *Message) map[string]any {
+	out := map[string]any{
+		"message_id": m.ID,
+		"date":       time.Now().Unix(),
+		"chat":       chatJSON(m.ChatID),
+		"text":       m.Text,
+	}
+	if m.FromBot {
+		out["from"] = map[string]any{"id": BotID, "is_bot": true, "first_name": "Test Bot", "username": "test_bot"}
+	}
+	return out
+}
+
+func chatJSON(chatID int64) map[string]any {
+	chatType := "private"
+	if chatID < 0 {
+		chatType = "supergroup"
+	}
+	return map[string]any{"id": chatID, "type": chatType}
+}
+
+func copyMessage(m *Message) Message {
+	out := *m
+	out.Keyboard = make([][]Button, len(m.Keyboard))
+	for i, row := range m.Keyboard {
+		out.Keyboard[i] = append([]Button(nil), row...)
+	}
+	return out
+}
+
+func writeResult(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func writeError(w http.ResponseWriter, code int, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": false, "error_code": code, "description": description})
+}
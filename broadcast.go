@@ -0,0 +1,439 @@
+package bote
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxbolgarin/erro"
+	"github.com/maxbolgarin/lang"
+	tele "github.com/maxbolgarin/telebot/v4"
+)
+
+const (
+	// defaultBroadcastRate stays below Telegram's global limit of ~30 messages per second
+	// and leaves room for regular handlers that send at the same time.
+	defaultBroadcastRate          = 25
+	defaultBroadcastMaxRetries    = 3
+	defaultBroadcastProgressEvery = 100
+	defaultBroadcastName          = "default"
+	// maxBroadcastRate is the rate with an interval of 1ns between messages, a higher rate has no interval.
+	maxBroadcastRate = int(time.Second)
+
+	broadcastResultSent    = "sent"
+	broadcastResultFailed  = "failed"
+	broadcastResultBlocked = "blocked"
+	broadcastResultSkipped = "skipped"
+)
+
+var (
+	errEmptyBroadcastRender = erro.New("broadcast render function cannot be nil")
+	errInvalidBroadcastRate = erro.New("broadcast rate must be from 1 to 1e9 messages per second")
+)
+
+// BroadcastFilter reports whether a user should receive a broadcast message.
+type BroadcastFilter func(user User) bool
+
+// BroadcastRender returns a message and an optional keyboard for a user.
+// Users with an empty message are skipped.
+type BroadcastRender func(user User) (string, *tele.ReplyMarkup)
+
+// BroadcastOptions contains optional settings of [Bot.Broadcast].
+type BroadcastOptions struct {
+	// Name is a campaign name used as a label in metrics and logs. Default: "default".
+	Name string
+	// Rate is a maximum number of messages per second, it cannot be negative or greater than 1e9. Default: 25.
+	Rate int
+	// MaxRetries is a maximum number of retries of a message after a flood error. Default: 3.
+	MaxRetries int
+	// OnProgress is called from the broadcast goroutine every ProgressEvery processed users and
+	// once more when the broadcast is finished.
+	OnProgress func(progress BroadcastProgress)
+	// ProgressEvery is a number of processed users between OnProgress calls. Default: 100.
+	ProgressEvery int
+}
+
+// WithBroadcastName sets a campaign name used as a label in metrics.
+func WithBroadcastName(name string) func(opts *BroadcastOptions) {
+	return func(opts *BroadcastOptions) {
+		opts.Name = name
+	}
+}
+
+// WithBroadcastRate sets a maximum number of messages per second.
+func WithBroadcastRate(perSecond int) func(opts *BroadcastOptions) {
+	return func(opts *BroadcastOptions) {
+		opts.Rate = perSecond
+	}
+}
+
+// WithBroadcastMaxRetries sets a maximum number of retries of a message after a flood error.
+func WithBroadcastMaxRetries(retries int) func(opts *BroadcastOptions) {
+	return func(opts *BroadcastOptions) {
+		opts.MaxRetries = retries
+	}
+}
+
+// WithBroadcastProgress sets a progress callback and optionally a number of users between calls.
+func WithBroadcastProgress(f func(progress BroadcastProgress), every ...int) func(opts *BroadcastOptions) {
+	return func(opts *BroadcastOptions) {
+		opts.OnProgress = f
+		opts.ProgressEvery = lang.First(every)
+	}
+}
+
+// BroadcastProgress is a snapshot of a broadcast state.
+type BroadcastProgress struct {
+	// Name is a campaign name.
+	Name string
+	// Total is a number of users in storage when the broadcast started.
+	Total int
+	// Processed is a number of users that were already handled with any result.
+	Processed int
+	// Sent is a number of delivered messages.
+	Sent int
+	// Failed is a number of messages that were not delivered because of an error.
+	Failed int
+	// Blocked is a number of users that blocked the bot. They are disabled.
+	Blocked int
+	// Skipped is a number of disabled users, users rejected by the filter and users with an empty message.
+	Skipped int
+	// Retries is a number of retries after flood errors.
+	Retries int
+	// StartedAt is a time when the broadcast started.
+	StartedAt time.Time
+	// Done is true when the broadcast is finished, canceled or failed.
+	Done bool
+}
+
+// Broadcast is a running mass-mailing campaign started by [Bot.Broadcast].
+// It is safe for concurrent use.
+type Broadcast struct {
+	bt     *Bot
+	opts   BroadcastOptions
+	filter BroadcastFilter
+	render BroadcastRender
+	send   func(userID int64, msg string, kb *tele.ReplyMarkup) (int, error)
+
+	mu       sync.Mutex
+	progress BroadcastProgress
+	paused   bool
+	resumeCh chan struct{}
+	err      error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Broadcast sends a message to every user from storage in a separate goroutine and returns
+// a handle to control it. Users that are disabled or rejected by the filter are skipped; nil filter
+// accepts every user. Storage must implement [UsersLister].
+//
+// Messages are sent one by one at the configured rate, so the global limit and the per-chat limit
+// (every user gets a single message) are respected. On a flood error the whole campaign waits for
+// retry_after and retries the message. Users who blocked the bot are disabled.
+func (b *Bot) Broadcast(ctx context.Context, filter BroadcastFilter, render BroadcastRender, optsFuncs ...func(*BroadcastOptions)) *Broadcast {
+	var opts BroadcastOptions
+	for _, f := range optsFuncs {
+		f(&opts)
+	}
+
+	c := b.newBroadcast(filter, render, opts)
+
+	var err error
+	switch {
+	case render == nil:
+		err = errEmptyBroadcastRender
+	case c.opts.Rate < 0 || c.opts.Rate > maxBroadcastRate:
+		err = errInvalidBroadcastRate
+	}
+	if err != nil {
+		b.bot.log.Error("cannot start broadcast", "campaign", c.opts.Name, "rate", c.opts.Rate, "error", err.Error())
+		b.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		c.finish(err)
+		close(c.done)
+		return c
+	}
+
+	ctx, c.cancel = context.WithCancel(ctx)
+	lang.Go(b.bot.log, func() {
+		defer c.cancel()
+		c.run(ctx)
+	})
+
+	return c
+}
+
+func (b *Bot) newBroadcast(filter BroadcastFilter, render BroadcastRender, opts BroadcastOptions) *Broadcast {
+	opts.Name = lang.Check(opts.Name, defaultBroadcastName)
+	opts.Rate = lang.Check(opts.Rate, defaultBroadcastRate)
+	opts.MaxRetries = lang.Check(opts.MaxRetries, defaultBroadcastMaxRetries)
+	opts.ProgressEvery = lang.Check(opts.ProgressEvery, defaultBroadcastProgressEvery)
+
+	return &Broadcast{
+		bt:     b,
+		opts:   opts,
+		filter: filter,
+		render: render,
+		send: func(userID int64, msg string, kb *tele.ReplyMarkup) (int, error) {
+			// Flood errors are retried by deliver, it pauses the whole campaign
+			return b.bot.sendOnce(userID, msg, kb)
+		},
+		progress: BroadcastProgress{
+			Name:      opts.Name,
+			StartedAt: time.Now(),
+		},
+		cancel: func() {},
+		done:   make(chan struct{}),
+	}
+}
+
+// Pause stops sending after the current message until [Broadcast.Resume] is called.
+func (c *Broadcast) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.paused {
+		c.paused = true
+		c.resumeCh = make(chan struct{})
+	}
+}
+
+// Resume continues a paused broadcast.
+func (c *Broadcast) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.paused {
+		c.paused = false
+		close(c.resumeCh)
+	}
+}
+
+// IsPaused returns true if the broadcast is paused.
+func (c *Broadcast) IsPaused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// Cancel stops the broadcast. Users that were not processed yet will not receive the message.
+func (c *Broadcast) Cancel() {
+	c.cancel()
+}
+
+// Progress returns the current progress of the broadcast.
+func (c *Broadcast) Progress() BroadcastProgress {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.progress
+}
+
+// Done returns a channel that is closed when the broadcast is finished.
+func (c *Broadcast) Done() <-chan struct{} {
+	return c.done
+}
+
+// Wait blocks until the broadcast is finished and returns the final progress.
+// It returns an error if users cannot be loaded or the broadcast was canceled.
+func (c *Broadcast) Wait() (BroadcastProgress, error) {
+	<-c.done
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.progress, c.err
+}
+
+func (c *Broadcast) run(ctx context.Context) {
+	defer close(c.done)
+
+	var (
+		users []UserModel
+		err   = errUsersListingNotSupported
+	)
+	if lister, ok := c.bt.um.db.(UsersLister); ok {
+		users, err = lister.FindAll(ctx)
+	}
+	if err != nil {
+		c.bt.bot.log.Error("cannot load users for broadcast", "campaign", c.opts.Name, "error", err.Error())
+		c.bt.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+		c.finish(erro.Wrap(err, "find all users"))
+		return
+	}
+
+	c.mu.Lock()
+	c.progress.Total = len(users)
+	c.mu.Unlock()
+
+	c.bt.bot.log.Info("broadcast started", "campaign", c.opts.Name, "total", len(users))
+
+	ticker := time.NewTicker(time.Second / time.Duration(c.opts.Rate))
+	defer ticker.Stop()
+
+	for _, model := range users {
+		if !c.waitResumed(ctx) {
+			c.finish(ctx.Err())
+			return
+		}
+
+		userID, msg, kb, ok := c.prepare(model)
+		if !ok {
+			c.record(broadcastResultSkipped, 0)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			c.finish(ctx.Err())
+			return
+		case <-ticker.C:
+		}
+
+		result, retries := c.deliver(ctx, userID, msg, kb)
+		c.record(result, retries)
+	}
+
+	c.finish(nil)
+}
+
+// prepare returns a user ID and a message for the user or false if the user should be skipped.
+func (c *Broadcast) prepare(model UserModel) (int64, string, *tele.ReplyMarkup, bool) {
+	if model.IsDisabled {
+		return 0, "", nil, false
+	}
+
+	userID, err := c.bt.GetUserID(model.ID)
+	if err != nil {
+		c.bt.bot.log.Error("cannot get user id for broadcast", "campaign", c.opts.Name,
+			"user_id", model.ID.String(), "error", err.Error())
+		c.bt.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityLow)
+		return 0, "", nil, false
+	}
+	if userID == 0 {
+		return 0, "", nil, false
+	}
+
+	// Prefer the cached user: it has the latest state that may not be flushed to storage yet.
+	var user User
+	if cached, ok := c.bt.um.users.get(userID); ok {
+		user = cached
+	} else {
+		user = c.bt.CreateUserFromModel(model, false)
+	}
+
+	if c.filter != nil && !c.filter(user) {
+		return 0, "", nil, false
+	}
+
+	msg, kb := c.render(user)
+	if msg == "" {
+		return 0, "", nil, false
+	}
+
+	return userID, msg, kb, true
+}
+
+// deliver sends the message, waiting for retry_after on flood errors.
+func (c *Broadcast) deliver(ctx context.Context, userID int64, msg string, kb *tele.ReplyMarkup) (string, int) {
+	var retries int
+	for {
+		_, err := c.send(userID, msg, kb)
+		if err == nil {
+			return broadcastResultSent, retries
+		}
+
+		var floodErr tele.FloodError
+		if errors.As(err, &floodErr) && retries < c.opts.MaxRetries {
+			retryAfter := time.Duration(lang.Check(floodErr.RetryAfter, 1)) * time.Second
+			c.bt.bot.log.Warn("broadcast hit flood limit, waiting", "campaign", c.opts.Name, "retry_after", retryAfter.String())
+
+			retries++
+			c.bt.bot.metr.incBroadcastRetry(c.opts.Name)
+
+			select {
+			case <-ctx.Done():
+				return broadcastResultFailed, retries
+			case <-time.After(retryAfter):
+			}
+			continue
+		}
+
+		if isBlockedError(err) {
+			c.bt.bot.log.Info("bot is blocked by user, disable", "campaign", c.opts.Name, "user_id", prepareUserID(userID, c.bt.bot.priv))
+			c.bt.um.disableUser(userID)
+			c.bt.bot.metr.incError(MetricsErrorBotBlocked, MetricsErrorSeverityLow)
+			return broadcastResultBlocked, retries
+		}
+
+		c.bt.bot.log.Warn("cannot send broadcast message", "campaign", c.opts.Name,
+			"user_id", prepareUserID(userID, c.bt.bot.priv), "error", err.Error())
+		return broadcastResultFailed, retries
+	}
+}
+
+func (c *Broadcast) record(result string, retries int) {
+	c.bt.bot.metr.incBroadcastMessage(c.opts.Name, result)
+
+	c.mu.Lock()
+	switch result {
+	case broadcastResultSent:
+		c.progress.Sent++
+	case broadcastResultFailed:
+		c.progress.Failed++
+	case broadcastResultBlocked:
+		c.progress.Blocked++
+	case broadcastResultSkipped:
+		c.progress.Skipped++
+	}
+	c.progress.Retries += retries
+	c.progress.Processed++
+	progress := c.progress
+	c.mu.Unlock()
+
+	if c.opts.OnProgress != nil && progress.Processed%c.opts.ProgressEvery == 0 {
+		c.opts.OnProgress(progress)
+	}
+}
+
+func (c *Broadcast) finish(err error) {
+	c.mu.Lock()
+	c.progress.Done = true
+	c.err = err
+	progress := c.progress
+	c.mu.Unlock()
+
+	if c.opts.OnProgress != nil {
+		c.opts.OnProgress(progress)
+	}
+
+	if err == nil {
+		c.bt.bot.log.Info("broadcast finished", "campaign", c.opts.Name,
+			"sent", progress.Sent, "failed", progress.Failed, "blocked", progress.Blocked, "skipped", progress.Skipped)
+	}
+}
+
+func (c *Broadcast) waitResumed(ctx context.Context) bool {
+	c.mu.Lock()
+	if !c.paused {
+		c.mu.Unlock()
+		return ctx.Err() == nil
+	}
+	resumeCh := c.resumeCh
+	c.mu.Unlock()
+
+	select {
+	case <-resumeCh:
+		return ctx.Err() == nil
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// isBlockedError reports whether Telegram refused to deliver a message because the user blocked
+// the bot or deleted the account.
+func isBlockedError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "bot was blocked by the user") || strings.Contains(msg, "user is deactivated")
+}
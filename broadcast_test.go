@@ -0,0 +1,148 @@
+package bote
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func insertBroadcastUsers(t *testing.T, bot *Bot, ids ...int64) {
+	t.Helper()
+	for _, id := range ids {
+		require.NoError(t, bot.um.db.Insert(context.Background(), UserModel{
+			ID:     NewPlainUserID(id),
+			Values: map[string]any{},
+		}))
+	}
+}
+
+func renderHello(User) (string, *tele.ReplyMarkup) {
+	return "hello", nil
+}
+
+// TestBroadcastResults verifies every user ends up with exactly one result.
+func TestBroadcastResults(t *testing.T) {
+	bot := setupTestBot(t)
+	insertBroadcastUsers(t, bot, 101, 102, 103, 104)
+	require.NoError(t, bot.um.db.Insert(context.Background(), UserModel{ID: NewPlainUserID(105), IsDisabled: true}))
+
+	var (
+		mu       sync.Mutex
+		attempts = map[int64]int{}
+	)
+	c := bot.newBroadcast(
+		func(user User) bool { return user.ID() != 102 },
+		renderHello,
+		BroadcastOptions{Name: "test", Rate: 1000},
+	)
+	c.send = func(userID int64, msg string, kb *tele.ReplyMarkup) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[userID]++
+		switch {
+		case userID == 103:
+			return 0, errors.New("telegram: Forbidden: bot was blocked by the user (403)")
+		case userID == 104 && attempts[userID] == 1:
+			return 0, tele.FloodError{RetryAfter: 1}
+		}
+		return 1, nil
+	}
+
+	c.run(context.Background())
+
+	progress, err := c.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, 5, progress.Total)
+	assert.Equal(t, 5, progress.Processed)
+	assert.Equal(t, 2, progress.Sent, "101 and 104 after retry")
+	assert.Equal(t, 1, progress.Blocked)
+	assert.Equal(t, 2, progress.Skipped, "filtered 102 and disabled 105")
+	assert.Equal(t, 1, progress.Retries)
+	assert.True(t, progress.Done)
+
+	assert.Equal(t, 2, attempts[104])
+	assert.Zero(t, attempts[102])
+	assert.Zero(t, attempts[105])
+}
+
+// TestBroadcastPauseResume verifies nothing is sent while the broadcast is paused.
+func TestBroadcastPauseResume(t *testing.T) {
+	bot := setupTestBot(t)
+	insertBroadcastUsers(t, bot, 201, 202)
+
+	c := bot.newBroadcast(nil, renderHello, BroadcastOptions{Rate: 1000})
+	c.send = func(int64, string, *tele.ReplyMarkup) (int, error) { return 1, nil }
+
+	c.Pause()
+	assert.True(t, c.IsPaused())
+	go c.run(context.Background())
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, c.Progress().Processed)
+
+	c.Resume()
+	progress, err := c.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, 2, progress.Sent)
+}
+
+// TestBroadcastCancel verifies a canceled broadcast stops and reports the context error.
+func TestBroadcastCancel(t *testing.T) {
+	bot := setupTestBot(t)
+	insertBroadcastUsers(t, bot, 301)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	progress, err := bot.Broadcast(ctx, nil, renderHello).Wait()
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.True(t, progress.Done)
+	assert.Zero(t, progress.Sent)
+}
+
+// TestBroadcastProgressCallback verifies progress is reported periodically and at the end.
+func TestBroadcastProgressCallback(t *testing.T) {
+	bot := setupTestBot(t)
+	insertBroadcastUsers(t, bot, 401, 402, 403)
+
+	var calls []BroadcastProgress
+	c := bot.newBroadcast(nil, renderHello, BroadcastOptions{
+		Rate:          1000,
+		ProgressEvery: 2,
+		OnProgress:    func(p BroadcastProgress) { calls = append(calls, p) },
+	})
+	c.send = func(int64, string, *tele.ReplyMarkup) (int, error) { return 1, nil }
+	c.run(context.Background())
+
+	require.Len(t, calls, 2)
+	assert.Equal(t, 2, calls[0].Processed)
+	assert.False(t, calls[0].Done)
+	assert.Equal(t, 3, calls[1].Processed)
+	assert.True(t, calls[1].Done)
+}
+
+// TestBroadcastNilRender verifies misuse is reported without starting a broadcast.
+func TestBroadcastNilRender(t *testing.T) {
+	bot := setupTestBot(t)
+
+	_, err := bot.Broadcast(context.Background(), nil, nil).Wait()
+	assert.ErrorIs(t, err, errEmptyBroadcastRender)
+
+	render := func(User) (string, *tele.ReplyMarkup) { return "hi", nil }
+	for _, rate := range []int{-1, maxBroadcastRate + 1} {
+		_, err = bot.Broadcast(context.Background(), nil, render, WithBroadcastRate(rate)).Wait()
+		assert.ErrorIs(t, err, errInvalidBroadcastRate, "rate %d", rate)
+	}
+}
+
+func TestIsBlockedError(t *testing.T) {
+	assert.True(t, isBlockedError(errors.New("telegram: Forbidden: bot was blocked by the user (403)")))
+	assert.True(t, isBlockedError(errors.New("telegram: Forbidden: user is deactivated (403)")))
+	assert.False(t, isBlockedError(errors.New("telegram: Bad Request: chat not found (400)")))
+}
@@ -0,0 +1,233 @@
+package bote
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// callbackDataMarker starts button data that is a token of a payload kept in [CallbackDataStore].
+	// It is a control character, so it does not clash with data that fits into a button.
+	callbackDataMarker = "\x1f"
+	// callbackDataTokenLength is a length of a token, 96 bits of a payload hash.
+	callbackDataTokenLength = 16
+
+	callbackDataTimeout = 5 * time.Second
+
+	// callbackSignatureMarker separates button data from its signature.
+	callbackSignatureMarker = "\x1e"
+	// callbackSignatureBytes is a length of a truncated HMAC-SHA256 of button data, 64 bits are enough
+	// to make guessing a signature for a forged payload impractical through the Bot API.
+	callbackSignatureBytes = 8
+	// callbackSignatureLength is a length of a signature with its marker in button data.
+	callbackSignatureLength = len(callbackSignatureMarker) + (callbackSignatureBytes*8+5)/6
+
+	memoryCallbackDataPurgeEvery = 1000
+)
+
+// CallbackDataStore keeps button payloads that do not fit into Telegram callback data.
+// Set it with [WithCallbackDataStore]: [Context.Btn] and [Bot.NewButton] store an oversized payload under
+// a short token and [Context.Data] expands it back. Without a store oversized payloads are truncated.
+//
+// Use a persistent implementation (e.g. filestorage.CallbackDataStore) to keep buttons working after a restart.
+type CallbackDataStore interface {
+	// Save stores data under the token until expiresAt. Saving an existing token extends its lifetime.
+	Save(ctx context.Context, token, data string, expiresAt time.Time) error
+	// Load returns data by token and false if there is no such token or it has expired.
+	Load(ctx context.Context, token string) (string, bool, error)
+}
+
+// buttonData returns data of the button with btnID that fits into maxLength bytes,
+// signed if callback signing is enabled with [WithCallbackSigning].
+func (b *Bot) buttonData(btnID string, maxLength int, dataList ...string) string {
+	if !b.cbSign {
+		return b.fitCallbackData(CreateBtnData(dataList...), maxLength)
+	}
+	data := b.fitCallbackData(CreateBtnData(dataList...), maxLength-callbackSignatureLength)
+	return data + callbackSignatureMarker + b.callbackSignature(btnID, data)
+}
+
+// verifyCallback reports whether the tapped button carries a valid signature. It is always true
+// if callback signing is disabled. Telegram clients can send any callback data, so a button with
+// a missing or wrong signature is forged or was created by another key and must not be dispatched.
+func (b *Bot) verifyCallback(ctx *contextImpl) bool {
+	cb := ctx.ct.Callback()
+	if !b.cbSign || cb == nil || ctx.callbackVerified {
+		return true
+	}
+
+	data, signature, ok := cutCallbackSignature(parseCallbackPayload(cb.Data))
+	if ok && hmac.Equal([]byte(signature), []byte(b.callbackSignature(ctx.ButtonID(), data))) {
+		ctx.callbackVerified = true
+		return true
+	}
+
+	userID := int64(0)
+	if cb.Sender != nil {
+		userID = cb.Sender.ID
+	}
+	b.bot.log.Warn("callback data signature mismatch, the button may be forged",
+		"user_id", prepareUserID(userID, b.um.priv),
+		"button_id", ctx.ButtonID(),
+	)
+	b.bot.metr.incError(MetricsErrorForgedCallback, MetricsErrorSeverityHigh)
+	return false
+}
+
+// callbackSignature returns a truncated HMAC of the button id and its data. The id is signed too,
+// so a payload of one button cannot be replayed with another one (e.g. "reject" data to "approve").
+func (b *Bot) callbackSignature(btnID, data string) string {
+	key := b.cbSignKey
+	if key == nil || key.key == nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, key.key[:])
+	mac.Write([]byte("bote-callback\x00" + btnID + "\x00" + data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:callbackSignatureBytes])
+}
+
+// unsignedCallbackData returns button data without its signature.
+func (b *Bot) unsignedCallbackData(data string) string {
+	if !b.cbSign {
+		return data
+	}
+	data, _, _ = cutCallbackSignature(data)
+	return data
+}
+
+func cutCallbackSignature(data string) (string, string, bool) {
+	i := strings.LastIndex(data, callbackSignatureMarker)
+	if i < 0 {
+		return data, "", false
+	}
+	return data[:i], data[i+len(callbackSignatureMarker):], true
+}
+
+// fitCallbackData returns button data that fits into maxLength bytes. An oversized payload is replaced
+// with a token if a [CallbackDataStore] is set, and truncated at a rune boundary otherwise.
+func (b *Bot) fitCallbackData(data string, maxLength int) string {
+	if len(data) <= maxLength {
+		return data
+	}
+
+	if b.cbStore != nil && maxLength >= len(callbackDataMarker)+callbackDataTokenLength {
+		token := callbackDataToken(data)
+		ctx, cancel := context.WithTimeout(context.Background(), callbackDataTimeout)
+		defer cancel()
+
+		err := b.cbStore.Save(ctx, token, data, time.Now().Add(b.cbTTL))
+		if err == nil {
+			return callbackDataMarker + token
+		}
+		b.bot.log.Error("cannot save button data, it will be truncated", "length", len(data), "error", err.Error())
+		b.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+	} else {
+		b.bot.log.Warn("button data length is greater than "+strconv.Itoa(maxLength)+" bytes, it will be truncated",
+			"length", len(data),
+		)
+	}
+
+	// Truncate at rune boundary to avoid splitting multi-byte UTF-8 characters
+	runes := []rune(data)
+	for i := len(runes); i > 0; i-- {
+		if truncated := string(runes[:i]); len(truncated) <= maxLength {
+			return truncated
+		}
+	}
+	return ""
+}
+
+// expandCallbackData returns the stored payload if data is a token and data itself otherwise.
+// An unknown or expired token gives empty data: the button is too old to be handled with its payload.
+func (b *Bot) expandCallbackData(data string) string {
+	token, ok := strings.CutPrefix(data, callbackDataMarker)
+	if !ok || b.cbStore == nil {
+		return data
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callbackDataTimeout)
+	defer cancel()
+
+	payload, found, err := b.cbStore.Load(ctx, token)
+	switch {
+	case err != nil:
+		b.bot.log.Error("cannot load button data", "token", token, "error", err.Error())
+		b.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+		return ""
+	case !found:
+		b.bot.log.Warn("button data is not found, it may be expired", "token", token)
+		b.bot.metr.incError(MetricsErrorInvalidUserState, MetricsErrorSeverityLow)
+		return ""
+	}
+	return payload
+}
+
+// callbackDataToken returns a token derived from the payload, so buttons with the same payload
+// share one record and rendering the same keyboard again does not grow the store.
+func callbackDataToken(data string) string {
+	hash := sha256.Sum256([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(hash[:])[:callbackDataTokenLength]
+}
+
+// MemoryCallbackDataStore is an in-memory [CallbackDataStore]. Payloads are lost on restart,
+// so buttons with oversized data created before a restart get empty data.
+type MemoryCallbackDataStore struct {
+	mu      sync.Mutex
+	items   map[string]memoryCallbackData
+	inserts int
+}
+
+type memoryCallbackData struct {
+	data      string
+	expiresAt time.Time
+}
+
+var _ CallbackDataStore = (*MemoryCallbackDataStore)(nil)
+
+// NewMemoryCallbackDataStore creates an in-memory store of button payloads.
+func NewMemoryCallbackDataStore() *MemoryCallbackDataStore {
+	return &MemoryCallbackDataStore{
+		items: make(map[string]memoryCallbackData),
+	}
+}
+
+// Save stores data under the token until expiresAt.
+func (s *MemoryCallbackDataStore) Save(_ context.Context, token, data string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[token] = memoryCallbackData{data: data, expiresAt: expiresAt}
+
+	s.inserts++
+	if s.inserts%memoryCallbackDataPurgeEvery == 0 {
+		now := time.Now()
+		for k, v := range s.items {
+			if now.After(v.expiresAt) {
+				delete(s.items, k)
+			}
+		}
+	}
+	return nil
+}
+
+// Load returns data by token and false if there is no such token or it has expired.
+func (s *MemoryCallbackDataStore) Load(_ context.Context, token string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[token]
+	if !ok {
+		return "", false, nil
+	}
+	if time.Now().After(item.expiresAt) {
+		delete(s.items, token)
+		return "", false, nil
+	}
+	return item.data, true, nil
+}
@@ -0,0 +1,105 @@
+package bote
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCallbackDataOverflow verifies an oversized payload is replaced with a token and expanded back in Data.
+func TestCallbackDataOverflow(t *testing.T) {
+	bot := setupTestBot(t)
+	bot.cbStore = NewMemoryCallbackDataStore()
+	bot.cbTTL = time.Hour
+
+	long := []string{"order", strings.Repeat("a", 40), "d4f1c0de-8c8e-4b8a-9a63-3c1f5b2f7e10"}
+	ctx := NewContext(bot, 9001, 1)
+	btn := ctx.Btn("Open", func(Context) error { return nil }, long...)
+
+	assert.True(t, strings.HasPrefix(btn.Data, callbackDataMarker))
+	assert.LessOrEqual(t, len(btn.Unique)+len(btn.Data)+2, MaxDataLengthBytes)
+	assert.Equal(t, btn.Data, bot.NewButton("Open", long...).Data, "same payload gives the same token")
+
+	tapped := NewContext(bot, 9001, 1, btn.Data)
+	assert.Equal(t, CreateBtnData(long...), tapped.Data())
+	assert.Equal(t, long, tapped.DataParsed())
+
+	short := ctx.Btn("Short", nil, "1", "2")
+	assert.Equal(t, "1|2", short.Data, "data that fits is kept in the button")
+}
+
+// TestCallbackDataWithoutStore verifies oversized payloads are truncated when the store is not set.
+func TestCallbackDataWithoutStore(t *testing.T) {
+	bot := setupTestBot(t)
+
+	btn := bot.NewButton("Open", strings.Repeat("я", 40))
+	assert.LessOrEqual(t, len(btn.Unique)+len(btn.Data)+2, MaxDataLengthBytes)
+	assert.True(t, strings.HasPrefix(strings.Repeat("я", 40), btn.Data), "truncated at a rune boundary")
+
+	assert.Equal(t, callbackDataMarker+"token", bot.expandCallbackData(callbackDataMarker+"token"),
+		"data is not expanded without a store")
+}
+
+// TestCallbackDataExpired verifies a button with an expired payload gets empty data.
+func TestCallbackDataExpired(t *testing.T) {
+	bot := setupTestBot(t)
+	store := NewMemoryCallbackDataStore()
+	bot.cbStore = store
+
+	require.NoError(t, store.Save(context.Background(), "expired", "payload", time.Now().Add(-time.Second)))
+	assert.Empty(t, bot.expandCallbackData(callbackDataMarker+"expired"))
+	assert.Empty(t, bot.expandCallbackData(callbackDataMarker+"missing"))
+	assert.Equal(t, "plain", bot.expandCallbackData("plain"))
+}
+
+// TestCallbackSigning verifies signed buttons are dispatched and forged ones are dropped.
+func TestCallbackSigning(t *testing.T) {
+	bot := setupTestBot(t)
+	key, err := NewEncryptionKeyFromString(strings.Repeat("ab", 32), nil)
+	require.NoError(t, err)
+	bot.cbSign, bot.cbSignKey = true, key
+
+	approved := []string{}
+	bot.RegisterButton("approve", func(ctx Context) error {
+		approved = append(approved, ctx.Data())
+		return nil
+	})
+
+	btn := bot.NewButton("approve", "draft", "42")
+	assert.LessOrEqual(t, len(btn.Unique)+len(btn.Data)+2, MaxDataLengthBytes)
+	require.NoError(t, dispatchCallback(bot, btn, tele.ChatChannel))
+	assert.Equal(t, []string{"draft|42"}, approved, "signature is not visible in Data")
+
+	forged := btn
+	forged.Data = strings.Replace(btn.Data, "42", "43", 1)
+	require.NoError(t, dispatchCallback(bot, forged, tele.ChatChannel))
+
+	unsigned := btn
+	unsigned.Data = "draft|43"
+	require.NoError(t, dispatchCallback(bot, unsigned, tele.ChatChannel))
+
+	bot.RegisterButton("reject", func(Context) error { return nil })
+	replayed := bot.NewButton("reject", "draft", "43")
+	replayed.Unique = btn.Unique
+	require.NoError(t, dispatchCallback(bot, replayed, tele.ChatChannel))
+
+	assert.Equal(t, []string{"draft|42"}, approved, "forged taps are not dispatched")
+}
+
+// TestCallbackSigningOptions verifies a signing key is required to enable signing.
+func TestCallbackSigningOptions(t *testing.T) {
+	opts := Options{Offline: true, Poller: &mockPoller{}, Config: Config{Mode: PollingModeCustom}}
+	WithCallbackSigning("")(&opts)
+	_, err := prepareOpts(opts)
+	require.Error(t, err)
+
+	WithCallbackSigning(strings.Repeat("ab", 32))(&opts)
+	prepared, err := prepareOpts(opts)
+	require.NoError(t, err)
+	assert.NotNil(t, prepared.callbackSigningKey)
+}
@@ -0,0 +1,141 @@
+package bote
+
+import (
+	"sync"
+	"time"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+)
+
+// chatActionInterval is the period of repeating a chat action, Telegram shows it for 5 seconds.
+const chatActionInterval = 4 * time.Second
+
+// chatAction shows a chat action (e.g. "typing…") in a chat while a handler runs. It starts after a delay,
+// so fast handlers do not send it at all, and pauses when the bot sends or edits a message in the chat.
+type chatAction struct {
+	owner  *chatActions
+	chatID int64
+
+	mu     sync.Mutex
+	action tele.ChatAction
+	timer  *time.Timer
+	paused bool
+	done   bool
+}
+
+// chatActions keeps chat actions of running handlers by chat.
+type chatActions struct {
+	notify func(chatID int64, action tele.ChatAction) error
+	log    Logger
+	priv   PrivacyMode
+
+	mu     sync.Mutex
+	active map[int64]map[*chatAction]struct{}
+}
+
+func newChatActions(bot *baseBot) *chatActions {
+	return &chatActions{
+		notify: bot.notify,
+		log:    bot.log,
+		priv:   bot.priv,
+		active: make(map[int64]map[*chatAction]struct{}),
+	}
+}
+
+// start shows the action in the chat after the delay until stop is called.
+func (c *chatActions) start(chatID int64, delay time.Duration) *chatAction {
+	a := &chatAction{owner: c, chatID: chatID, action: tele.Typing}
+	a.mu.Lock()
+	a.timer = time.AfterFunc(delay, a.tick)
+	a.mu.Unlock()
+
+	c.mu.Lock()
+	if c.active[chatID] == nil {
+		c.active[chatID] = make(map[*chatAction]struct{})
+	}
+	c.active[chatID][a] = struct{}{}
+	c.mu.Unlock()
+	return a
+}
+
+// stop stops the action after the handler returns.
+func (c *chatActions) stop(a *chatAction) {
+	c.mu.Lock()
+	delete(c.active[a.chatID], a)
+	if len(c.active[a.chatID]) == 0 {
+		delete(c.active, a.chatID)
+	}
+	c.mu.Unlock()
+
+	a.mu.Lock()
+	a.done = true
+	a.timer.Stop()
+	a.mu.Unlock()
+}
+
+// sent pauses actions in the chat: the user sees the response and Telegram hides the action anyway.
+func (c *chatActions) sent(chatID int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for a := range c.active[chatID] {
+		a.pause()
+	}
+}
+
+// set changes the action and shows it at once, e.g. before sending a file after a text response.
+func (a *chatAction) set(action tele.ChatAction) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.done {
+		return
+	}
+	a.action = action
+	a.paused = false
+	a.timer.Reset(0)
+}
+
+func (a *chatAction) pause() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.paused = true
+	a.timer.Stop()
+}
+
+func (a *chatAction) tick() {
+	a.mu.Lock()
+	if a.done || a.paused {
+		a.mu.Unlock()
+		return
+	}
+	action := a.action
+	a.timer.Reset(chatActionInterval)
+	a.mu.Unlock()
+
+	if err := a.owner.notify(a.chatID, action); err != nil {
+		a.owner.log.Debug("failed to send chat action", "user_id", prepareUserID(a.chatID, a.owner.priv), "error", err.Error())
+	}
+}
+
+func (c *contextImpl) SetChatAction(action tele.ChatAction) {
+	if c.chatAction == nil {
+		if c.bt.chatActionDelay == 0 {
+			c.bt.bot.log.Warn("chat actions are disabled, use WithChatAction to enable them")
+			c.bt.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityLow)
+		}
+		return
+	}
+	c.chatAction.set(action)
+}
+
+// startChatAction starts a chat action for the handler if they are enabled, it returns a function to stop it.
+func (b *Bot) startChatAction(ctx *contextImpl) func() {
+	chat := ctx.ct.Chat()
+	if b.chatActionDelay == 0 || chat == nil {
+		return func() {}
+	}
+	ctx.chatAction = b.bot.actions.start(chat.ID, b.chatActionDelay)
+	return func() { b.bot.actions.stop(ctx.chatAction) }
+}
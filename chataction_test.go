@@ -0,0 +1,72 @@
+package bote
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type notifyRecorder struct {
+	mu      sync.Mutex
+	actions []tele.ChatAction
+}
+
+func (r *notifyRecorder) notify(_ int64, action tele.ChatAction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.actions = append(r.actions, action)
+	return nil
+}
+
+func (r *notifyRecorder) get() []tele.ChatAction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]tele.ChatAction(nil), r.actions...)
+}
+
+func newChatActionsForTest(t *testing.T) (*chatActions, *notifyRecorder) {
+	rec := &notifyRecorder{}
+	actions := newChatActions(setupTestBot(t).bot)
+	actions.notify = rec.notify
+	return actions, rec
+}
+
+// TestChatActionDelay verifies fast handlers do not show the action and slow ones do.
+func TestChatActionDelay(t *testing.T) {
+	actions, rec := newChatActionsForTest(t)
+
+	fast := actions.start(1, 50*time.Millisecond)
+	actions.stop(fast)
+	time.Sleep(80 * time.Millisecond)
+	assert.Empty(t, rec.get(), "fast handler does not show the action")
+
+	slow := actions.start(1, 10*time.Millisecond)
+	defer actions.stop(slow)
+	require.Eventually(t, func() bool { return len(rec.get()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []tele.ChatAction{tele.Typing}, rec.get())
+}
+
+// TestChatActionPausedBySend verifies sending a message pauses the action and SetChatAction shows a new one at once.
+func TestChatActionPausedBySend(t *testing.T) {
+	actions, rec := newChatActionsForTest(t)
+
+	a := actions.start(1, 20*time.Millisecond)
+	defer actions.stop(a)
+	actions.sent(2)
+	actions.sent(1)
+	time.Sleep(40 * time.Millisecond)
+	assert.Empty(t, rec.get(), "action is paused by a message in the chat")
+
+	a.set(tele.UploadingDocument)
+	require.Eventually(t, func() bool { return len(rec.get()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []tele.ChatAction{tele.UploadingDocument}, rec.get())
+
+	actions.stop(a)
+	a.set(tele.Typing)
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, rec.get(), 1, "stopped action is not shown")
+}
@@ -1,6 +1,8 @@
 package bote
 
 import (
+	"context"
+	"errors"
 	"strconv"
 	"strings"
 	"unicode/utf16"
@@ -12,6 +14,11 @@ import (
 
 // Context is an interface that provides to every handler.
 type Context interface {
+	// Ctx returns a context of the handler. It is canceled when the bot stops (the context passed to
+	// [Bot.Start] is done), when the handler returns or when the deadline of the handler passes,
+	// see [BotConfig.HandlerTimeout] and [Timeout]. Pass it to DB and HTTP calls.
+	Ctx() context.Context
+
 	// Tele returns underlying telebot context.
 	Tele() tele.Context
 
@@ -50,6 +57,18 @@ type Context interface {
 	// DataParsed returns all items of button data.
 	DataParsed() []string
 
+	// Answer answers the tapped callback button with a text shown as a toast on top of the chat
+	// or as an alert with OK button if showAlert is true.
+	// The callback is answered with an empty response after the handler if it does not call Answer.
+	// Telegram accepts one answer in a limited time: [ErrCallbackAlreadyAnswered] and
+	// [ErrCallbackAnswerTimeout] are returned for the second and the late answer.
+	// [ErrNotCallback] is returned if the update is not a callback, e.g. for [NewContext].
+	Answer(text string, showAlert bool) error
+
+	// AnswerURL answers the tapped callback button by opening the URL.
+	// It is allowed only for game URLs and t.me links that open the bot with a parameter, see [Context.Answer].
+	AnswerURL(url string) error
+
 	// Text returns a text sended by the user.
 	Text() string
 
@@ -67,6 +86,26 @@ type Context interface {
 	// Button unique value is generated from hexing button name with 10 random bytes at the end.
 	Btn(name string, callback HandlerFunc, dataList ...string) tele.Btn
 
+	// BtnID creates button with a stable id that is separate from its text and registers handler for it.
+	// The text is resolved for the user language with [ButtonMessages], falling back to the id.
+	// Use it for localized keyboards: taps on buttons rendered in another language or with an old
+	// text are still routed to the handler, including restart recovery.
+	BtnID(id string, callback HandlerFunc, dataList ...string) tele.Btn
+
+	// SetChatAction sets a chat action shown while the handler runs, e.g. [tele.UploadingDocument]
+	// before [Context.SendFile]. The action is shown at once and repeated until the bot sends or edits
+	// a message or the handler returns. Chat actions are enabled with [WithChatAction], "typing…"
+	// is shown by default for handlers that run longer than [BotConfig.ChatActionDelay].
+	SetChatAction(action tele.ChatAction)
+
+	// Transition moves the user to the state registered with [Bot.RegisterState].
+	// It checks that the edge from the current main state is declared and that the guard of the
+	// target state allows it, then runs OnExit of the current state, OnEnter of the target state
+	// and renders the target screen. Illegal transitions are logged and [ErrIllegalTransition]
+	// is returned.
+	// WARNING: It works only in private chats.
+	Transition(to State) error
+
 	// Send sends new main and head messages to the user.
 	// Old head message will be deleted. Old main message will becomve historical.
 	// newState is a state of the user which will be set after sending message.
@@ -83,11 +122,13 @@ type Context interface {
 
 	// SendNotification sends a notification message to the user.
 	// opts are additional options for sending message.
+	// Pass [DeleteAfter] in opts to delete the notification automatically, see also [BotConfig.NotificationTTL].
 	// WARNING: It works only in private chats.
 	SendNotification(msg string, kb *tele.ReplyMarkup, opts ...any) error
 
 	// SendError sends an error message to the user.
 	// opts are additional options for sending message.
+	// Pass [DeleteAfter] in opts to delete the message automatically, see also [BotConfig.ErrorTTL].
 	// WARNING: It works only in private chats.
 	SendError(msg string, opts ...any) error
 
@@ -135,6 +176,19 @@ type Context interface {
 	// WARNING: It works only in private chats.
 	SendRichDraft(draftID int, rich *tele.InputRichMessage, opts ...any) error
 
+	// Stream streams a text that is being generated to the user and commits it as a new main message.
+	// src is a <-chan string or an io.Reader, chunks are accumulated and sent as drafts every
+	// [BotConfig.StreamInterval] with the same draft ID. A text longer than the message limit is split:
+	// full parts are sent as main messages without keyboard, the last one gets the state and keyboard.
+	// Drafts and messages use the bot parse mode. With [tele.ModeHTML] parts are split outside of tags
+	// and entities, tags open at the split are closed in the part and reopened in the rest, and drafts
+	// get closing tags for the tags that are not closed yet. With Markdown modes the text is split
+	// as is, so a part can be rejected if the split cuts an entity.
+	// It returns when src is closed or [Context.Ctx] is done, the text generated before the context
+	// is done is committed as the main message and the context error is returned.
+	// WARNING: It works only in private chats.
+	Stream(newState State, src any, kb *tele.ReplyMarkup, opts ...any) error
+
 	// SendNotificationRich sends a notification built from rich content (Bot API 10.1).
 	// It behaves exactly like SendNotification — replaces the previous notification, tracks the
 	// new message id so DeleteNotification can remove it, and re-keys the keyboard's buttons —
@@ -265,7 +319,7 @@ type Context interface {
 
 func (b *Bot) newContext(c tele.Context) *contextImpl {
 	upd := c.Update()
-	result := &contextImpl{bt: b, ct: c}
+	result := &contextImpl{bt: b, ct: c, cbAnswer: newCallbackAnswer(c)}
 
 	sender := getSender(&upd)
 	if sender == nil {
@@ -390,8 +444,16 @@ type contextImpl struct {
 	ct   tele.Context
 	user *userContextImpl
 
-	textMsgID       int
-	callbackHandled bool // set when initUserHandler already dispatched a callback
+	textMsgID        int
+	callbackHandled  bool // set when initUserHandler already dispatched a callback
+	callbackVerified bool // set when the signature of the callback data is checked
+
+	// cbAnswer is shared with contexts that dispatch the tapped button, nil if update is not a callback.
+	cbAnswer *callbackAnswer
+	// deadline is shared with contexts that dispatch the tapped button, nil outside of Handle.
+	deadline *handlerDeadline
+	// chatAction is shared with contexts that dispatch the tapped button, nil if chat actions are disabled.
+	chatAction *chatAction
 
 	// registeredBtns tracks buttons registered through this context (one keyboard
 	// build), keyed by buttonMap key with the handler's function pointer as value.
@@ -444,7 +506,7 @@ func (c *contextImpl) buttonMapKey(btnID string) string {
 
 func (c *contextImpl) Data() string {
 	if cb := c.ct.Callback(); cb != nil {
-		return parseCallbackPayload(cb.Data)
+		return c.bt.expandCallbackData(c.bt.unsignedCallbackData(parseCallbackPayload(cb.Data)))
 	}
 	return ""
 }
@@ -699,6 +761,7 @@ func (c *contextImpl) SendNotification(msg string, kb *tele.ReplyMarkup, opts ..
 	if !c.validateUserInputWithMessage(msg, "SendNotification", NoChange) {
 		return nil
 	}
+	defer c.lockNotification()()
 
 	// Buttons in kb were registered against the trigger message; capture its ID so
 	// they can be re-keyed to the sent notification (same wiring as SendFile).
@@ -710,11 +773,12 @@ func (c *contextImpl) SendNotification(msg string, kb *tele.ReplyMarkup, opts ..
 		}
 	}
 
+	ttl, opts := c.bt.expirer.ttl(expiringNotification, opts)
 	msgID, err := c.bt.bot.send(c.user.ID(), msg, append(opts, kb)...)
 	if err != nil {
 		return c.prepareError(err, msgID)
 	}
-	c.user.setNotificationMessage(msgID)
+	c.bt.expirer.track(c.user, expiringNotification, msgID, ttl)
 	if kb != nil && len(kb.InlineKeyboard) > 0 {
 		c.user.copyButtonsToNewMsgID(triggerMsgID, msgID)
 	}
@@ -726,6 +790,7 @@ func (c *contextImpl) SendNotificationRich(rich *tele.InputRichMessage, kb *tele
 	if !c.validateUserInputWithRich(rich, "SendNotificationRich", NoChange) {
 		return nil
 	}
+	defer c.lockNotification()()
 
 	triggerMsgID := c.MessageID()
 
@@ -735,11 +800,12 @@ func (c *contextImpl) SendNotificationRich(rich *tele.InputRichMessage, kb *tele
 		}
 	}
 
+	ttl, opts := c.bt.expirer.ttl(expiringNotification, opts)
 	msgID, err := c.bt.bot.sendRich(c.user.ID(), rich, append(opts, kb)...)
 	if err != nil {
 		return c.prepareError(err, msgID)
 	}
-	c.user.setNotificationMessage(msgID)
+	c.bt.expirer.track(c.user, expiringNotification, msgID, ttl)
 	if kb != nil && len(kb.InlineKeyboard) > 0 {
 		c.user.copyButtonsToNewMsgID(triggerMsgID, msgID)
 	}
@@ -751,6 +817,7 @@ func (c *contextImpl) EditNotification(msg string, kb *tele.ReplyMarkup, opts ..
 	if !c.validateUserInputWithMessage(msg, "EditNotification", NoChange) {
 		return nil
 	}
+	defer c.lockNotification()()
 
 	msgID := c.user.Messages().NotificationID
 	if msgID == 0 {
@@ -771,6 +838,7 @@ func (c *contextImpl) EditNotificationRich(rich *tele.InputRichMessage, kb *tele
 	if !c.validateUserInputWithRich(rich, "EditNotificationRich", NoChange) {
 		return nil
 	}
+	defer c.lockNotification()()
 
 	msgID := c.user.Messages().NotificationID
 	if msgID == 0 {
@@ -800,9 +868,10 @@ func (c *contextImpl) SendError(msg string, opts ...any) error {
 	// the handler can be re-keyed to the sent error message (same wiring as SendFile).
 	triggerMsgID := c.MessageID()
 
+	ttl, opts := c.bt.expirer.ttl(expiringError, opts)
 	closeBtn := c.bt.msgs.Messages(c.user.Language()).CloseBtn()
 	if closeBtn != "" {
-		opts = append(opts, SingleRow(c.Btn(closeBtn, func(c Context) error {
+		opts = append(opts, SingleRow(c.btn(closeErrorButtonID, closeBtn, func(c Context) error {
 			return c.DeleteError()
 		})))
 	}
@@ -811,7 +880,7 @@ func (c *contextImpl) SendError(msg string, opts ...any) error {
 		c.bt.bot.log.Error("failed to send error message", c.bt.userFields(c.user, "error", err.Error())...)
 		return nil // return nil to avoid bot blocking because sending error message is not critical
 	}
-	c.user.setErrorMessage(msgID)
+	c.bt.expirer.track(c.user, expiringError, msgID, ttl)
 	c.user.copyButtonsToNewMsgID(triggerMsgID, msgID)
 
 	return nil
@@ -1140,6 +1209,7 @@ func (c *contextImpl) DeleteNotification() error {
 	if !c.validateUserInput("DeleteNotification", NoChange) {
 		return nil
 	}
+	defer c.lockNotification()()
 	if c.user.Messages().NotificationID == 0 {
 		return nil
 	}
@@ -1344,6 +1414,11 @@ func (c *contextImpl) handleError(err error) error {
 		return nil
 	}
 
+	// Already logged by Transition, the user stays on the current screen
+	if errors.Is(err, ErrIllegalTransition) {
+		return nil
+	}
+
 	errorMsg := err.Error()
 
 	// Handle specific error types
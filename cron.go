@@ -0,0 +1,184 @@
+package bote
+
+import (
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxbolgarin/erro"
+)
+
+// maxCronIterations bounds the search of the next activation, so a spec that can never match
+// (e.g. "0 0 31 2 *") fails instead of looping forever.
+const maxCronIterations = 50000
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Cron is a parsed cron-like recurrence of a scheduled job.
+//
+// It supports the classic 5 fields "minute hour day-of-month month day-of-week" with "*", lists
+// ("1,15"), ranges ("1-5") and steps ("*/10", "8-18/2"), the macros @yearly, @monthly, @weekly,
+// @daily and @hourly, and fixed intervals like "@every 90m". Day of week is 0-6 starting
+// from Sunday, 7 is also Sunday. If both day fields are restricted, a day matching any of them fits.
+type Cron struct {
+	spec  string
+	every time.Duration
+
+	minute, hour, dom, month, dow uint64
+
+	domAny, dowAny bool
+}
+
+// ParseCron parses a cron-like spec. See [Cron] for the syntax.
+func ParseCron(spec string) (Cron, error) {
+	spec = strings.TrimSpace(spec)
+	c := Cron{spec: spec}
+
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return Cron{}, erro.Wrap(err, "parse interval", "spec", spec)
+		}
+		if every < time.Second {
+			return Cron{}, erro.New("interval must be at least one second", "spec", spec)
+		}
+		c.every = every
+		return c, nil
+	}
+
+	if macro, ok := cronMacros[spec]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Cron{}, erro.New("cron spec must have 5 fields", "spec", c.spec)
+	}
+
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return Cron{}, erro.Wrap(err, "parse minute", "spec", c.spec)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return Cron{}, erro.Wrap(err, "parse hour", "spec", c.spec)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return Cron{}, erro.Wrap(err, "parse day of month", "spec", c.spec)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return Cron{}, erro.Wrap(err, "parse month", "spec", c.spec)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return Cron{}, erro.Wrap(err, "parse day of week", "spec", c.spec)
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 // 7 is Sunday too
+	}
+	c.domAny = fields[2] == "*"
+	c.dowAny = fields[4] == "*"
+
+	return c, nil
+}
+
+// String returns the original spec.
+func (c Cron) String() string {
+	return c.spec
+}
+
+// Next returns the first activation time strictly after the given time in its location.
+// It returns zero time if there is no activation in the next years.
+func (c Cron) Next(after time.Time) time.Time {
+	if c.every > 0 {
+		return after.Add(c.every)
+	}
+
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	for range maxCronIterations {
+		switch {
+		case !cronHas(c.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !cronHas(c.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !cronHas(c.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (c Cron) dayMatches(t time.Time) bool {
+	domOK := cronHas(c.dom, t.Day())
+	dowOK := cronHas(c.dow, int(t.Weekday()))
+	if c.domAny || c.dowAny {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+func cronHas(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, erro.New("invalid step", "part", part)
+			}
+		}
+
+		start, end := lo, hi
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err1, err2 error
+			start, err1 = strconv.Atoi(from)
+			end, err2 = strconv.Atoi(to)
+			if err1 != nil || err2 != nil {
+				return 0, erro.New("invalid range", "part", part)
+			}
+		default:
+			v, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, erro.New("invalid value", "part", part)
+			}
+			start = v
+			if !hasStep {
+				end = v
+			}
+		}
+
+		if start < lo || end > hi || start > end {
+			return 0, erro.New("value out of range", "part", part, "min", lo, "max", hi)
+		}
+		for v := start; v <= end; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	if bits.OnesCount64(set) == 0 {
+		return 0, erro.New("empty field", "field", field)
+	}
+	return set, nil
+}
@@ -0,0 +1,61 @@
+package bote
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2025, 3, 14, 10, 30, 15, 0, time.UTC) // Friday
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2025, 3, 14, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 3, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2025, 3, 15, 9, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2025, 3, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 * *", time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", base.Add(90 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			c, err := ParseCron(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, c.Next(base))
+			assert.Equal(t, tt.spec, c.String())
+		})
+	}
+}
+
+// TestCronDayFields verifies restricted day-of-month and day-of-week are combined with OR.
+func TestCronDayFields(t *testing.T) {
+	c, err := ParseCron("0 0 20 * 1") // 20th or any Monday
+	require.NoError(t, err)
+
+	next := c.Next(time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC), next)
+	assert.Equal(t, time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC), c.Next(time.Date(2025, 3, 18, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every 10ms", "@every soon"} {
+		_, err := ParseCron(spec)
+		assert.Error(t, err, spec)
+	}
+
+	c, err := ParseCron("0 0 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, c.Next(time.Now()).IsZero(), "February 31 never comes")
+}
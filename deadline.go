@@ -0,0 +1,200 @@
+package bote
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// handlerDeadline is a deadline of a handler of a single update. It is shared between the context
+// of the update and contexts created to dispatch the tapped button, so [Timeout] of a button handler
+// changes the deadline of the whole update.
+type handlerDeadline struct {
+	base      context.Context
+	start     time.Time
+	onTimeout func(timeout time.Duration)
+
+	// notifyMu serializes notifications sent by the handler with the timeout notification
+	// that is sent from the timer goroutine while the handler is running.
+	notifyMu sync.Mutex
+
+	mu      sync.Mutex
+	timeout time.Duration
+	ctx     *deadlineContext
+	cancel  context.CancelCauseFunc
+	timer   *time.Timer
+	expired bool
+	done    bool
+}
+
+func newHandlerDeadline(base context.Context, timeout time.Duration, onTimeout func(time.Duration)) *handlerDeadline {
+	d := &handlerDeadline{
+		base:      base,
+		start:     time.Now(),
+		onTimeout: onTimeout,
+	}
+	d.setTimeout(timeout)
+	return d
+}
+
+// context returns a context that is canceled when the bot stops, the deadline passes or the handler returns.
+// The same context is returned for the whole update, its deadline follows [handlerDeadline.setTimeout].
+func (d *handlerDeadline) context() context.Context {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.ctx == nil {
+		var inner context.Context
+		inner, d.cancel = context.WithCancelCause(d.base)
+		d.ctx = &deadlineContext{Context: inner, d: d}
+		switch {
+		case d.expired:
+			d.cancel(context.DeadlineExceeded)
+		case d.done:
+			d.cancel(context.Canceled)
+		}
+	}
+	return d.ctx
+}
+
+// setTimeout sets the deadline counting from the start of the update, zero timeout removes it.
+// The context that handlers already hold is not canceled: it gets the new deadline.
+// A deadline that has already passed cannot be extended.
+func (d *handlerDeadline) setTimeout(timeout time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.done || d.expired {
+		return
+	}
+	d.timeout = timeout
+
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if timeout > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(time.Until(d.start.Add(timeout)), func() { d.expire(timer, timeout) })
+		d.timer = timer
+	}
+}
+
+// expire cancels the context when the timer of the current deadline fires.
+func (d *handlerDeadline) expire(timer *time.Timer, timeout time.Duration) {
+	d.mu.Lock()
+	if d.timer != timer || d.done {
+		d.mu.Unlock()
+		return
+	}
+	d.expired = true
+	if d.cancel != nil {
+		d.cancel(context.DeadlineExceeded)
+	}
+	d.mu.Unlock()
+
+	d.onTimeout(timeout)
+}
+
+// deadline returns the deadline of the handler or of the base context if it is earlier.
+func (d *handlerDeadline) deadline() (time.Time, bool) {
+	d.mu.Lock()
+	timeout := d.timeout
+	d.mu.Unlock()
+
+	base, ok := d.base.Deadline()
+	if timeout <= 0 {
+		return base, ok
+	}
+	if deadline := d.start.Add(timeout); !ok || deadline.Before(base) {
+		return deadline, true
+	}
+	return base, true
+}
+
+// finish stops the timer and cancels the context after the handler returns.
+func (d *handlerDeadline) finish() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.done = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	if d.cancel != nil {
+		d.cancel(context.Canceled)
+	}
+}
+
+// deadlineContext is a context of a handler with a deadline that can be changed by [Timeout]
+// after the handler has got the context.
+type deadlineContext struct {
+	context.Context
+	d *handlerDeadline
+}
+
+func (c *deadlineContext) Deadline() (time.Time, bool) {
+	return c.d.deadline()
+}
+
+func (c *deadlineContext) Err() error {
+	err := c.Context.Err()
+	if err != nil && errors.Is(context.Cause(c.Context), context.DeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// Timeout sets the deadline of the handler, overriding [BotConfig.HandlerTimeout].
+// The deadline counts from the time the update is received, zero timeout removes it.
+//
+//	kb.Add(ctx.Btn("Build report", bote.Timeout(2*time.Minute, reportHandler)))
+func Timeout(timeout time.Duration, handler HandlerFunc) HandlerFunc {
+	return func(ctx Context) error {
+		if c, ok := ctx.(*contextImpl); ok && c.deadline != nil {
+			c.deadline.setTimeout(timeout)
+		}
+		return handler(ctx)
+	}
+}
+
+func (c *contextImpl) Ctx() context.Context {
+	if c.deadline != nil {
+		return c.deadline.context()
+	}
+	return c.bt.baseContext()
+}
+
+// lockNotification serializes notifications of the handler with the timeout notification.
+func (c *contextImpl) lockNotification() func() {
+	if c.deadline == nil {
+		return func() {}
+	}
+	c.deadline.notifyMu.Lock()
+	return c.deadline.notifyMu.Unlock
+}
+
+// handlerTimedOut is called when the handler runs longer than its deadline.
+// The handler keeps running: it gets the canceled [Context.Ctx] and should return.
+func (b *Bot) handlerTimedOut(ctx *contextImpl, timeout time.Duration) {
+	b.bot.metr.incError(MetricsErrorTimeout, MetricsErrorSeverityHigh)
+	if ctx.user == nil || ctx.user.isPublic {
+		b.bot.log.Warn("handler deadline exceeded", "timeout", timeout.String())
+		return
+	}
+	b.bot.log.Warn("handler deadline exceeded", b.userFields(ctx.user, "timeout", timeout.String())...)
+
+	msgs, ok := b.msgs.Messages(ctx.user.Language()).(TimeoutMessages)
+	if !ok {
+		msgs = enMessages{}
+	}
+	if text := msgs.HandlerTimeout(); text != "" {
+		// The handler is still running and uses its own context, so the notification is sent with
+		// a separate one; they share the deadline, which serializes their notifications.
+		notifier := &contextImpl{bt: b, ct: ctx.ct, user: ctx.user, deadline: ctx.deadline}
+		if err := notifier.SendNotification(text, nil); err != nil {
+			b.bot.log.Error("failed to send timeout notification", b.userFields(ctx.user, "error", err.Error())...)
+		}
+	}
+}
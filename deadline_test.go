@@ -0,0 +1,107 @@
+package bote
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHandlerDeadline verifies the context is canceled by the deadline and the timeout callback is called.
+func TestHandlerDeadline(t *testing.T) {
+	fired := make(chan time.Duration, 1)
+	d := newHandlerDeadline(context.Background(), 20*time.Millisecond, func(timeout time.Duration) { fired <- timeout })
+	defer d.finish()
+
+	ctx := d.context()
+	_, ok := ctx.Deadline()
+	require.True(t, ok)
+
+	select {
+	case timeout := <-fired:
+		assert.Equal(t, 20*time.Millisecond, timeout)
+	case <-time.After(time.Second):
+		t.Fatal("timeout callback is not called")
+	}
+	<-ctx.Done()
+	assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+}
+
+// TestHandlerDeadlineFinish verifies the context is canceled when the handler returns and the timer is stopped.
+func TestHandlerDeadlineFinish(t *testing.T) {
+	base, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := newHandlerDeadline(base, 0, func(time.Duration) { t.Error("no deadline") })
+	ctx := d.context()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok, "no deadline by default")
+
+	d.finish()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+
+	d = newHandlerDeadline(base, time.Hour, func(time.Duration) { t.Error("finished handler") })
+	ctx = d.context()
+	cancel()
+	<-ctx.Done()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled, "bot stop cancels handler context")
+	d.finish()
+}
+
+// TestHandlerDeadlineChangedInFlight verifies a new timeout does not cancel the context the handler holds.
+func TestHandlerDeadlineChangedInFlight(t *testing.T) {
+	fired := make(chan time.Duration, 1)
+	d := newHandlerDeadline(context.Background(), 50*time.Millisecond, func(timeout time.Duration) { fired <- timeout })
+	defer d.finish()
+
+	ctx := d.context()
+	d.setTimeout(time.Hour)
+
+	assert.NoError(t, ctx.Err(), "context of in-flight requests is not canceled")
+	assert.Same(t, ctx, d.context())
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Second, "held context gets the new deadline")
+
+	select {
+	case <-ctx.Done():
+		t.Fatal("context is canceled by the old deadline")
+	case timeout := <-fired:
+		t.Fatalf("old deadline %s fired", timeout)
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	d.setTimeout(10 * time.Millisecond)
+	<-ctx.Done()
+	assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+}
+
+// TestTimeoutOverridesDeadline verifies a handler marked with Timeout gets its own deadline.
+func TestTimeoutOverridesDeadline(t *testing.T) {
+	bot := setupTestBot(t)
+
+	fired := make(chan time.Duration, 1)
+	ctx := NewContext(bot, 9301, 1).(*contextImpl)
+	assert.Equal(t, bot.baseContext(), ctx.Ctx(), "context without Handle is the bot context")
+
+	ctx.deadline = newHandlerDeadline(context.Background(), time.Hour, func(timeout time.Duration) { fired <- timeout })
+	defer ctx.deadline.finish()
+
+	var deadline time.Time
+	err := Timeout(10*time.Millisecond, func(c Context) error {
+		deadline, _ = c.Ctx().Deadline()
+		<-c.Ctx().Done()
+		return nil
+	})(ctx)
+	require.NoError(t, err)
+
+	assert.WithinDuration(t, time.Now(), deadline, time.Second)
+	select {
+	case timeout := <-fired:
+		assert.Equal(t, 10*time.Millisecond, timeout)
+	case <-time.After(time.Second):
+		t.Fatal("timeout callback is not called")
+	}
+}
@@ -0,0 +1,204 @@
+package bote
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/maxbolgarin/erro"
+	tele "github.com/maxbolgarin/telebot/v4"
+)
+
+const (
+	debouncePurgeEvery         = 1000
+	memoryIdempotentPurgeEvery = 1000
+)
+
+// debouncer drops repeated taps on the same button: taps while the handler of the first one is running
+// and taps within the window after it has finished.
+type debouncer struct {
+	window time.Duration
+
+	mu    sync.Mutex
+	taps  map[string]debounceTap
+	begun int
+}
+
+type debounceTap struct {
+	inFlight   bool
+	finishedAt time.Time
+}
+
+func newDebouncer(window time.Duration) *debouncer {
+	if window <= 0 {
+		return nil
+	}
+	return &debouncer{
+		window: window,
+		taps:   make(map[string]debounceTap),
+	}
+}
+
+// begin returns false if the tap is a duplicate. Call end after handling a tap that is not a duplicate.
+func (d *debouncer) begin(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if tap, ok := d.taps[key]; ok && (tap.inFlight || now.Sub(tap.finishedAt) < d.window) {
+		return false
+	}
+	d.taps[key] = debounceTap{inFlight: true}
+
+	d.begun++
+	if d.begun%debouncePurgeEvery == 0 {
+		for k, tap := range d.taps {
+			if !tap.inFlight && now.Sub(tap.finishedAt) >= d.window {
+				delete(d.taps, k)
+			}
+		}
+	}
+	return true
+}
+
+func (d *debouncer) end(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.taps[key] = debounceTap{finishedAt: time.Now()}
+}
+
+// debounceTap reports whether the callback should be handled and returns a function to call after handling.
+// Taps of different users are debounced separately, e.g. two admins tapping a button in a group.
+func (b *Bot) debounceTap(ctx *contextImpl) (bool, func()) {
+	if b.debounce == nil || ctx.cbAnswer == nil {
+		return true, func() {}
+	}
+	key := ctx.cbAnswer.key
+	if sender := ctx.ct.Callback().Sender; sender != nil {
+		key = strconv.FormatInt(sender.ID, 10) + ":" + key
+	}
+	if !b.debounce.begin(key) {
+		b.bot.log.Debug("duplicate tap is dropped", "button_id", ctx.ButtonID())
+		return false, func() {}
+	}
+	return true, func() { b.debounce.end(key) }
+}
+
+// callbackKey returns a key of the tapped button in the message: taps on the same button
+// with the same payload in the same message give the same key.
+func callbackKey(cb *tele.Callback) string {
+	where := cb.MessageID // inline message
+	if msg := cb.Message; msg != nil {
+		where = strconv.Itoa(msg.ID)
+		if msg.Chat != nil {
+			where = strconv.FormatInt(msg.Chat.ID, 10) + ":" + where
+		}
+	}
+	btnID := getIDFromUnique(cb.Unique)
+	if cb.Unique == "" {
+		btnID = getIDFromUnparsedData(cb.Data)
+	}
+	return where + ":" + btnID + ":" + parseCallbackPayload(cb.Data)
+}
+
+// IdempotencyStore keeps records of handled taps on buttons marked with [Idempotent].
+// Set it with [WithIdempotencyStore], it is in memory by default.
+//
+// Use a persistent implementation (e.g. filestorage.IdempotencyStore) to keep records after a restart.
+type IdempotencyStore interface {
+	// Reserve records the key until expiresAt and returns false if the key is already recorded.
+	Reserve(ctx context.Context, key string, expiresAt time.Time) (bool, error)
+	// Release removes the record, so the key can be reserved again.
+	Release(ctx context.Context, key string) error
+}
+
+// Idempotent marks a button handler whose side effects must happen at most once per message:
+// it runs once for a tap on the button with the same payload in the same message, other taps are answered
+// without running it, even after a restart if [IdempotencyStore] is persistent. If the handler returns
+// an error, the record is removed and the button can be tapped again.
+//
+//	kb.Add(ctx.Btn("Pay", bote.Idempotent(payHandler), orderID))
+func Idempotent(handler HandlerFunc) HandlerFunc {
+	return func(ctx Context) error {
+		c, ok := ctx.(*contextImpl)
+		if !ok || c.cbAnswer == nil {
+			return handler(ctx)
+		}
+		return c.bt.runIdempotent(c, handler)
+	}
+}
+
+func (b *Bot) runIdempotent(ctx *contextImpl, handler HandlerFunc) error {
+	key := ctx.cbAnswer.key
+
+	storeCtx, cancel := context.WithTimeout(context.Background(), callbackDataTimeout)
+	defer cancel()
+
+	reserved, err := b.idemStore.Reserve(storeCtx, key, time.Now().Add(b.idemTTL))
+	if err != nil {
+		b.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+		return erro.Wrap(err, "reserve idempotency key")
+	}
+	if !reserved {
+		b.bot.log.Debug("idempotent button is already handled", "button_id", ctx.ButtonID())
+		return nil
+	}
+
+	if err := handler(ctx); err != nil {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), callbackDataTimeout)
+		defer cancel()
+		if relErr := b.idemStore.Release(releaseCtx, key); relErr != nil {
+			b.bot.log.Error("cannot release idempotency key", "error", relErr.Error())
+			b.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+		}
+		return err
+	}
+	return nil
+}
+
+// MemoryIdempotencyStore is an in-memory [IdempotencyStore]. Records are lost on restart.
+type MemoryIdempotencyStore struct {
+	mu       sync.Mutex
+	items    map[string]time.Time
+	reserves int
+}
+
+var _ IdempotencyStore = (*MemoryIdempotencyStore)(nil)
+
+// NewMemoryIdempotencyStore creates an in-memory store of idempotency records.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		items: make(map[string]time.Time),
+	}
+}
+
+// Reserve records the key until expiresAt and returns false if the key is already recorded.
+func (s *MemoryIdempotencyStore) Reserve(_ context.Context, key string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if exp, ok := s.items[key]; ok && now.Before(exp) {
+		return false, nil
+	}
+	s.items[key] = expiresAt
+
+	s.reserves++
+	if s.reserves%memoryIdempotentPurgeEvery == 0 {
+		for k, exp := range s.items {
+			if now.After(exp) {
+				delete(s.items, k)
+			}
+		}
+	}
+	return true, nil
+}
+
+// Release removes the record, so the key can be reserved again.
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+	return nil
+}
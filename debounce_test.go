@@ -0,0 +1,82 @@
+package bote
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDebouncer verifies taps are dropped while the first one is in flight and within the window.
+func TestDebouncer(t *testing.T) {
+	d := newDebouncer(50 * time.Millisecond)
+	require.NotNil(t, d)
+	assert.Nil(t, newDebouncer(0), "debounce is disabled by default")
+
+	require.True(t, d.begin("1:10:btn:"))
+	assert.False(t, d.begin("1:10:btn:"), "in-flight duplicate")
+	assert.True(t, d.begin("1:10:btn:other"), "another payload is another button")
+
+	d.end("1:10:btn:")
+	assert.False(t, d.begin("1:10:btn:"), "duplicate within the window")
+
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, d.begin("1:10:btn:"), "tap after the window")
+}
+
+// TestCallbackKey verifies the key depends on the message, the button and its payload, not on the random unique suffix.
+func TestCallbackKey(t *testing.T) {
+	bot := setupTestBot(t)
+	a := bot.NewButton("approve", "42")
+	b := bot.NewButton("approve", "42")
+	require.NotEqual(t, a.Unique, b.Unique)
+
+	cb := func(btn tele.Btn, msgID int) *tele.Callback {
+		return &tele.Callback{
+			Message: &tele.Message{ID: msgID, Chat: &tele.Chat{ID: -100}},
+			Data:    "\f" + btn.Unique + "|" + btn.Data,
+		}
+	}
+	assert.Equal(t, callbackKey(cb(a, 1)), callbackKey(cb(b, 1)))
+	assert.NotEqual(t, callbackKey(cb(a, 1)), callbackKey(cb(a, 2)))
+	assert.NotEqual(t, callbackKey(cb(a, 1)), callbackKey(cb(bot.NewButton("approve", "43"), 1)))
+}
+
+// TestIdempotentHandler verifies an idempotent handler runs once per message and button and can be retried after an error.
+func TestIdempotentHandler(t *testing.T) {
+	bot := setupTestBot(t)
+
+	calls := 0
+	fail := true
+	handler := Idempotent(func(Context) error {
+		calls++
+		if fail {
+			fail = false
+			return errors.New("payment failed")
+		}
+		return nil
+	})
+
+	btn := bot.NewButton("pay", "order-1")
+	tap := func() error {
+		upd := tele.Update{Callback: &tele.Callback{
+			Sender:  &tele.User{ID: 555},
+			Message: &tele.Message{ID: 7, Chat: &tele.Chat{ID: 555, Type: tele.ChatPrivate}},
+			Data:    "\f" + btn.Unique + "|" + btn.Data,
+		}}
+		return handler(bot.newContextFromUpdate(upd))
+	}
+
+	require.Error(t, tap())
+	require.NoError(t, tap(), "failed tap can be retried")
+	require.NoError(t, tap())
+	require.NoError(t, tap())
+	assert.Equal(t, 2, calls)
+
+	calls = 0
+	require.NoError(t, handler(NewContext(bot, 555, 7)))
+	assert.Equal(t, 1, calls, "handler without a callback is not idempotent")
+}
@@ -0,0 +1,227 @@
+package bote
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DeleteAfter is an option of [Context.SendNotification] and [Context.SendError] (and their rich
+// variants) that deletes the sent message automatically after the duration.
+// It overrides [BotConfig.NotificationTTL] and [BotConfig.ErrorTTL]; zero disables deletion for this message.
+//
+// Example:
+//
+//	ctx.SendNotification("Saved", nil, bote.DeleteAfter(5*time.Second))
+type DeleteAfter time.Duration
+
+type expiringMessageKind int
+
+const (
+	expiringNotification expiringMessageKind = iota
+	expiringError
+)
+
+func (k expiringMessageKind) String() string {
+	if k == expiringError {
+		return "error"
+	}
+	return "notification"
+}
+
+// splitDeleteAfter removes [DeleteAfter] from opts, which telebot does not understand,
+// and returns the TTL of the message, falling back to def.
+func splitDeleteAfter(def time.Duration, opts []any) (time.Duration, []any) {
+	ttl := def
+	out := make([]any, 0, len(opts))
+	for _, o := range opts {
+		if d, ok := o.(DeleteAfter); ok {
+			ttl = time.Duration(d)
+			continue
+		}
+		out = append(out, o)
+	}
+	return ttl, out
+}
+
+type expiryKey struct {
+	userID int64
+	kind   expiringMessageKind
+}
+
+// messageExpirer deletes notification and error messages when their TTL is over.
+//
+// The deadline is persisted in [UserMessages] together with the message ID, the timers here are only
+// an in-memory index of it: they are rebuilt from storage on start, and a fired timer does nothing
+// unless the user still has the same message with the same deadline. So a message that was closed,
+// replaced or removed by cleanMiddleware before the TTL is never deleted twice or by mistake.
+type messageExpirer struct {
+	bt *Bot
+
+	notificationTTL time.Duration
+	errorTTL        time.Duration
+
+	mu      sync.Mutex
+	timers  map[expiryKey]*time.Timer
+	stopped bool
+}
+
+func newMessageExpirer(bt *Bot, notificationTTL, errorTTL time.Duration) *messageExpirer {
+	return &messageExpirer{
+		bt:              bt,
+		notificationTTL: notificationTTL,
+		errorTTL:        errorTTL,
+		timers:          make(map[expiryKey]*time.Timer),
+	}
+}
+
+// ttl returns the TTL of the message of the kind and opts without [DeleteAfter].
+func (e *messageExpirer) ttl(kind expiringMessageKind, opts []any) (time.Duration, []any) {
+	if kind == expiringError {
+		return splitDeleteAfter(e.errorTTL, opts)
+	}
+	return splitDeleteAfter(e.notificationTTL, opts)
+}
+
+// track saves the sent message of the kind to the user and arms its deletion if ttl is positive.
+func (e *messageExpirer) track(user *userContextImpl, kind expiringMessageKind, msgID int, ttl time.Duration) {
+	var expiresAt time.Time
+	if ttl > 0 {
+		// Rounded to make the deadline survive storages with microsecond precision:
+		// forgetExpiredMessage compares it with the restored one.
+		expiresAt = time.Now().Add(ttl).Round(time.Millisecond)
+	}
+
+	switch kind {
+	case expiringNotification:
+		user.setExpiringNotificationMessage(msgID, expiresAt)
+	case expiringError:
+		user.setExpiringErrorMessage(msgID, expiresAt)
+	}
+
+	if !expiresAt.IsZero() {
+		e.arm(user.ID(), kind, msgID, expiresAt)
+	}
+}
+
+// arm schedules deletion of the message. A timer of the same user and kind is replaced:
+// the user has only one notification and one error message at a time.
+func (e *messageExpirer) arm(userID int64, kind expiringMessageKind, msgID int, expiresAt time.Time) {
+	if userID == 0 || msgID == 0 {
+		return
+	}
+	key := expiryKey{userID: userID, kind: kind}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.stopped {
+		return
+	}
+	if prev, ok := e.timers[key]; ok {
+		prev.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(expiresAt), func() {
+		e.mu.Lock()
+		if e.timers[key] == timer {
+			delete(e.timers, key)
+		}
+		e.mu.Unlock()
+
+		e.expire(userID, kind, msgID, expiresAt)
+	})
+	e.timers[key] = timer
+}
+
+// expire deletes the message if the user still has it with the same deadline.
+// It does not create a missing user or enable a disabled one: the bot cannot delete messages in
+// a chat the user blocked, and overdue messages are deleted on the next action of the user.
+func (e *messageExpirer) expire(userID int64, kind expiringMessageKind, msgID int, expiresAt time.Time) {
+	user, found, err := e.bt.um.findUser(userID)
+	if err != nil {
+		e.bt.bot.log.Error("cannot find user to delete expired message",
+			"user_id", prepareUserID(userID, e.bt.um.priv),
+			"error", err.Error(),
+		)
+		e.bt.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityLow)
+		return
+	}
+	if !found || user.IsDisabled() {
+		return
+	}
+	if !user.forgetExpiredMessage(kind, msgID, expiresAt) {
+		return
+	}
+	if err := e.bt.bot.delete(userID, msgID); err != nil {
+		e.bt.bot.log.Debug("failed to delete expired message",
+			"user_id", prepareUserID(userID, e.bt.um.priv),
+			"msg_id", msgID,
+			"type", kind.String(),
+			"error", err.Error(),
+		)
+	}
+}
+
+// expireOverdue deletes messages of the user whose TTL is over but whose timer is absent,
+// e.g. the bot was down at that moment and the storage cannot list users for restore.
+func (e *messageExpirer) expireOverdue(user *userContextImpl) {
+	msgs := user.Messages()
+	now := time.Now()
+	if msgs.NotificationID != 0 && !msgs.NotificationExpiresAt.IsZero() && !msgs.NotificationExpiresAt.After(now) {
+		e.expire(user.ID(), expiringNotification, msgs.NotificationID, msgs.NotificationExpiresAt)
+	}
+	if msgs.ErrorID != 0 && !msgs.ErrorExpiresAt.IsZero() && !msgs.ErrorExpiresAt.After(now) {
+		e.expire(user.ID(), expiringError, msgs.ErrorID, msgs.ErrorExpiresAt)
+	}
+}
+
+// restore arms timers for the deadlines persisted in storage. It needs the storage to implement
+// [UsersLister]; otherwise overdue messages are deleted on the next action of the user.
+func (e *messageExpirer) restore(ctx context.Context) {
+	lister, ok := e.bt.um.db.(UsersLister)
+	if !ok {
+		return
+	}
+	users, err := lister.FindAll(ctx)
+	if err != nil {
+		if !errors.Is(err, errUsersListingNotSupported) {
+			e.bt.bot.log.Error("cannot list users to restore message expiry", "error", err.Error())
+			e.bt.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityLow)
+		}
+		return
+	}
+
+	for _, u := range users {
+		msgs := u.Messages
+		if msgs.NotificationExpiresAt.IsZero() && msgs.ErrorExpiresAt.IsZero() {
+			continue
+		}
+		userID, err := e.bt.GetUserID(u.ID)
+		if err != nil {
+			e.bt.bot.log.Error("cannot get user ID to restore message expiry", "user_id", u.ID.String(), "error", err.Error())
+			e.bt.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityLow)
+			continue
+		}
+		if msgs.NotificationID != 0 && !msgs.NotificationExpiresAt.IsZero() {
+			e.arm(userID, expiringNotification, msgs.NotificationID, msgs.NotificationExpiresAt)
+		}
+		if msgs.ErrorID != 0 && !msgs.ErrorExpiresAt.IsZero() {
+			e.arm(userID, expiringError, msgs.ErrorID, msgs.ErrorExpiresAt)
+		}
+	}
+}
+
+// stop cancels all timers, messages will be deleted by the restored timers after restart.
+func (e *messageExpirer) stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.stopped = true
+	for key, timer := range e.timers {
+		timer.Stop()
+		delete(e.timers, key)
+	}
+}
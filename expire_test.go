@@ -0,0 +1,138 @@
+package bote
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitDeleteAfter(t *testing.T) {
+	ttl, opts := splitDeleteAfter(time.Minute, []any{tele.Silent, DeleteAfter(5 * time.Second), tele.NoPreview})
+	assert.Equal(t, 5*time.Second, ttl)
+	assert.Equal(t, []any{tele.Silent, tele.NoPreview}, opts, "DeleteAfter is not passed to telebot")
+
+	ttl, _ = splitDeleteAfter(time.Minute, nil)
+	assert.Equal(t, time.Minute, ttl, "config default is used")
+
+	ttl, _ = splitDeleteAfter(time.Minute, []any{DeleteAfter(0)})
+	assert.Zero(t, ttl, "zero disables deletion of a single message")
+}
+
+// TestMessageExpires verifies the message is forgotten when its TTL is over.
+func TestMessageExpires(t *testing.T) {
+	bot := setupTestBot(t)
+	user := NewContext(bot, 7001, 1).(*contextImpl).user
+
+	bot.expirer.track(user, expiringNotification, 50, 50*time.Millisecond)
+	bot.expirer.track(user, expiringError, 51, 50*time.Millisecond)
+
+	msgs := user.Messages()
+	assert.Equal(t, 50, msgs.NotificationID)
+	assert.False(t, msgs.NotificationExpiresAt.IsZero())
+
+	require.Eventually(t, func() bool {
+		msgs := user.Messages()
+		return msgs.NotificationID == 0 && msgs.ErrorID == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.True(t, user.Messages().NotificationExpiresAt.IsZero())
+	assert.True(t, user.Messages().ErrorExpiresAt.IsZero())
+}
+
+// TestMessageExpiryReconciliation verifies a stale timer does not touch a message that was
+// closed or replaced before the TTL fired.
+func TestMessageExpiryReconciliation(t *testing.T) {
+	bot := setupTestBot(t)
+	user := NewContext(bot, 7002, 1).(*contextImpl).user
+
+	bot.expirer.track(user, expiringNotification, 60, 50*time.Millisecond)
+	bot.expirer.track(user, expiringError, 61, 50*time.Millisecond)
+
+	// The user acted: the error was removed by cleanMiddleware and a new one without TTL was sent,
+	// the notification was replaced by a longer-living one.
+	user.setErrorMessage(0)
+	user.setErrorMessage(71)
+	bot.expirer.track(user, expiringNotification, 70, time.Hour)
+
+	time.Sleep(150 * time.Millisecond)
+
+	msgs := user.Messages()
+	assert.Equal(t, 70, msgs.NotificationID)
+	assert.Equal(t, 71, msgs.ErrorID)
+	assert.True(t, msgs.ErrorExpiresAt.IsZero(), "new error message does not inherit the deadline")
+
+	assert.False(t, user.forgetExpiredMessage(expiringNotification, 60, time.Now()))
+	assert.True(t, user.forgetExpiredMessage(expiringNotification, 70, msgs.NotificationExpiresAt))
+}
+
+// TestMessageExpiryRestore verifies deadlines persisted in storage are rearmed after restart.
+func TestMessageExpiryRestore(t *testing.T) {
+	bot := setupTestBot(t)
+	user := NewContext(bot, 7003, 1).(*contextImpl).user
+
+	expiresAt := time.Now().Add(100 * time.Millisecond).Round(time.Millisecond)
+	user.setExpiringNotificationMessage(80, expiresAt)
+
+	require.Eventually(t, func() bool {
+		stored, found, err := bot.um.db.Find(context.Background(), user.user.ID)
+		return err == nil && found && stored.Messages.NotificationExpiresAt.Equal(expiresAt)
+	}, time.Second, 10*time.Millisecond, "expiry is persisted next to the message ID")
+
+	// A new expirer has no timers, like the one of a restarted bot.
+	expirer := newMessageExpirer(bot, 0, 0)
+	expirer.restore(context.Background())
+
+	expirer.mu.Lock()
+	assert.Len(t, expirer.timers, 1)
+	expirer.mu.Unlock()
+
+	require.Eventually(t, func() bool {
+		return user.Messages().NotificationID == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+// TestMessageExpiryOverdue verifies an overdue message without a timer is deleted on the next action.
+func TestMessageExpiryOverdue(t *testing.T) {
+	bot := setupTestBot(t)
+	user := NewContext(bot, 7004, 1).(*contextImpl).user
+
+	user.setExpiringNotificationMessage(90, time.Now().Add(-time.Minute).Round(time.Millisecond))
+	user.setExpiringErrorMessage(91, time.Now().Add(time.Hour).Round(time.Millisecond))
+
+	bot.expirer.expireOverdue(user)
+
+	msgs := user.Messages()
+	assert.Zero(t, msgs.NotificationID)
+	assert.Equal(t, 91, msgs.ErrorID, "error is not due yet")
+}
+
+// TestMessageExpiryUnknownUser verifies expiry does not create a missing user or enable a disabled one.
+func TestMessageExpiryUnknownUser(t *testing.T) {
+	bot := setupTestBot(t)
+
+	bot.expirer.expire(7005, expiringNotification, 100, time.Now())
+	_, found, err := bot.um.findUser(7005)
+	require.NoError(t, err)
+	assert.False(t, found, "missing user is not created")
+
+	user := NewContext(bot, 7006, 1).(*contextImpl).user
+	expiresAt := time.Now().Round(time.Millisecond)
+	user.setExpiringNotificationMessage(101, expiresAt)
+	bot.um.disableUser(7006)
+
+	require.Eventually(t, func() bool {
+		stored, found, err := bot.um.db.Find(context.Background(), user.user.ID)
+		return err == nil && found && stored.IsDisabled && stored.Messages.NotificationID == 101
+	}, time.Second, 10*time.Millisecond)
+
+	bot.expirer.expire(7006, expiringNotification, 101, expiresAt)
+
+	disabled, found, err := bot.um.findUser(7006)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.True(t, disabled.IsDisabled(), "disabled user is not enabled")
+	assert.Equal(t, 101, disabled.Messages().NotificationID)
+}
@@ -0,0 +1,115 @@
+package filestorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/maxbolgarin/bote"
+	"github.com/maxbolgarin/erro"
+)
+
+// CallbackDataStore is a [bote.CallbackDataStore] that keeps button payloads in a single JSON file,
+// so buttons with oversized data keep working after a restart. The file is rewritten atomically
+// when a payload is added; expired payloads are dropped on every rewrite.
+type CallbackDataStore struct {
+	path string
+
+	mu    sync.Mutex
+	items map[string]callbackData
+}
+
+type callbackData struct {
+	Data      string    `json:"data"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var _ bote.CallbackDataStore = (*CallbackDataStore)(nil)
+
+// NewCallbackDataStore opens a store of button payloads in the file, creating the directory if needed.
+func NewCallbackDataStore(path string) (*CallbackDataStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, erro.Wrap(err, "create directory", "path", path)
+	}
+
+	s := &CallbackDataStore{
+		path:  path,
+		items: make(map[string]callbackData),
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, erro.Wrap(err, "read callback data", "path", path)
+	default:
+		if err := json.Unmarshal(data, &s.items); err != nil {
+			return nil, erro.Wrap(err, "decode callback data", "path", path)
+		}
+	}
+
+	return s, nil
+}
+
+// Save stores data under the token until expiresAt.
+// The same keyboard is usually rendered many times, so a payload that is already stored and lives
+// for at least a half of the requested time is not rewritten.
+func (s *CallbackDataStore) Save(_ context.Context, token, data string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	prev, existed := s.items[token]
+	if existed && prev.Data == data && prev.ExpiresAt.Sub(now) >= expiresAt.Sub(now)/2 {
+		return nil
+	}
+
+	s.items[token] = callbackData{Data: data, ExpiresAt: expiresAt}
+	if err := s.flushLocked(now); err != nil {
+		if existed {
+			s.items[token] = prev
+		} else {
+			delete(s.items, token)
+		}
+		return err
+	}
+	return nil
+}
+
+// Load returns data by token and false if there is no such token or it has expired.
+func (s *CallbackDataStore) Load(_ context.Context, token string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[token]
+	if !ok || time.Now().After(item.ExpiresAt) {
+		return "", false, nil
+	}
+	return item.Data, true, nil
+}
+
+func (s *CallbackDataStore) flushLocked(now time.Time) error {
+	for token, item := range s.items {
+		if now.After(item.ExpiresAt) {
+			delete(s.items, token)
+		}
+	}
+
+	data, err := json.Marshal(s.items)
+	if err != nil {
+		return erro.Wrap(err, "marshal callback data")
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := writeFileSync(tmpPath, data); err != nil {
+		return erro.Wrap(err, "write callback data")
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return erro.Wrap(err, "rename callback data")
+	}
+	return syncDir(filepath.Dir(s.path))
+}
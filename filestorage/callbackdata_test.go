@@ -0,0 +1,39 @@
+package filestorage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCallbackDataStoreSurvivesReopen verifies payloads are read back after the store is reopened
+// and expired payloads are not returned.
+func TestCallbackDataStoreSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "callback", "data.json")
+
+	s, err := NewCallbackDataStore(path)
+	require.NoError(t, err)
+	require.NoError(t, s.Save(ctx, "live", "order|123|very long payload", time.Now().Add(time.Hour)))
+	require.NoError(t, s.Save(ctx, "expired", "old", time.Now().Add(-time.Second)))
+
+	s, err = NewCallbackDataStore(path)
+	require.NoError(t, err)
+
+	data, found, err := s.Load(ctx, "live")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "order|123|very long payload", data)
+
+	_, found, err = s.Load(ctx, "expired")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	_, found, err = s.Load(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
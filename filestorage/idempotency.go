@@ -0,0 +1,114 @@
+package filestorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/maxbolgarin/bote"
+	"github.com/maxbolgarin/erro"
+)
+
+// IdempotencyStore is a [bote.IdempotencyStore] that keeps records of handled taps in a single JSON file,
+// so buttons marked with [bote.Idempotent] are not handled twice after a restart. The file is rewritten
+// atomically on every change; expired records are dropped on every rewrite.
+type IdempotencyStore struct {
+	path string
+
+	mu    sync.Mutex
+	items map[string]time.Time
+}
+
+var _ bote.IdempotencyStore = (*IdempotencyStore)(nil)
+
+// NewIdempotencyStore opens a store of idempotency records in the file, creating the directory if needed.
+func NewIdempotencyStore(path string) (*IdempotencyStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, erro.Wrap(err, "create directory", "path", path)
+	}
+
+	s := &IdempotencyStore{
+		path:  path,
+		items: make(map[string]time.Time),
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, erro.Wrap(err, "read idempotency records", "path", path)
+	default:
+		if err := json.Unmarshal(data, &s.items); err != nil {
+			return nil, erro.Wrap(err, "decode idempotency records", "path", path)
+		}
+	}
+
+	return s, nil
+}
+
+// Reserve records the key until expiresAt and returns false if the key is already recorded.
+func (s *IdempotencyStore) Reserve(_ context.Context, key string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	prev, existed := s.items[key]
+	if existed && now.Before(prev) {
+		return false, nil
+	}
+
+	s.items[key] = expiresAt
+	if err := s.flushLocked(now); err != nil {
+		if existed {
+			s.items[key] = prev
+		} else {
+			delete(s.items, key)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Release removes the record, so the key can be reserved again.
+func (s *IdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, existed := s.items[key]
+	if !existed {
+		return nil
+	}
+
+	delete(s.items, key)
+	if err := s.flushLocked(time.Now()); err != nil {
+		s.items[key] = prev
+		return err
+	}
+	return nil
+}
+
+func (s *IdempotencyStore) flushLocked(now time.Time) error {
+	for key, expiresAt := range s.items {
+		if now.After(expiresAt) {
+			delete(s.items, key)
+		}
+	}
+
+	data, err := json.Marshal(s.items)
+	if err != nil {
+		return erro.Wrap(err, "marshal idempotency records")
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := writeFileSync(tmpPath, data); err != nil {
+		return erro.Wrap(err, "write idempotency records")
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return erro.Wrap(err, "rename idempotency records")
+	}
+	return syncDir(filepath.Dir(s.path))
+}
@@ -0,0 +1,45 @@
+package filestorage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIdempotencyStoreSurvivesReopen verifies records are kept after the store is reopened
+// and released or expired records can be reserved again.
+func TestIdempotencyStoreSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+
+	s, err := NewIdempotencyStore(path)
+	require.NoError(t, err)
+
+	ok, err := s.Reserve(ctx, "1:10:pay:42", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = s.Reserve(ctx, "released", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NoError(t, s.Release(ctx, "released"))
+	ok, err = s.Reserve(ctx, "expired", time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	s, err = NewIdempotencyStore(path)
+	require.NoError(t, err)
+
+	ok, err = s.Reserve(ctx, "1:10:pay:42", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, ok, "handled tap is recorded after reopen")
+
+	for _, key := range []string{"released", "expired"} {
+		ok, err = s.Reserve(ctx, key, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.True(t, ok, key)
+	}
+}
@@ -0,0 +1,115 @@
+package filestorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/maxbolgarin/bote"
+	"github.com/maxbolgarin/erro"
+)
+
+// JobStore is a [bote.JobStore] that keeps scheduled jobs in a single JSON file.
+// The file is rewritten atomically on every change, which is fine for the number of jobs
+// a single-node bot has.
+type JobStore struct {
+	path string
+
+	mu   sync.Mutex
+	jobs map[string]bote.ScheduledJob
+}
+
+var _ bote.JobStore = (*JobStore)(nil)
+
+// NewJobStore opens a job store in the file, creating the directory if needed.
+func NewJobStore(path string) (*JobStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, erro.Wrap(err, "create directory", "path", path)
+	}
+
+	s := &JobStore{
+		path: path,
+		jobs: make(map[string]bote.ScheduledJob),
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, erro.Wrap(err, "read jobs", "path", path)
+	default:
+		if err := json.Unmarshal(data, &s.jobs); err != nil {
+			return nil, erro.Wrap(err, "decode jobs", "path", path)
+		}
+	}
+
+	return s, nil
+}
+
+// Save inserts the job or replaces a job with the same key.
+func (s *JobStore) Save(_ context.Context, job bote.ScheduledJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, existed := s.jobs[job.Key]
+	s.jobs[job.Key] = job
+	if err := s.flushLocked(); err != nil {
+		if existed {
+			s.jobs[job.Key] = prev
+		} else {
+			delete(s.jobs, job.Key)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete deletes the job by key.
+func (s *JobStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, existed := s.jobs[key]
+	if !existed {
+		return nil
+	}
+	delete(s.jobs, key)
+	if err := s.flushLocked(); err != nil {
+		s.jobs[key] = prev
+		return err
+	}
+	return nil
+}
+
+// List returns all jobs ordered by time.
+func (s *JobStore) List(context.Context) ([]bote.ScheduledJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]bote.ScheduledJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		out = append(out, job)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out, nil
+}
+
+func (s *JobStore) flushLocked() error {
+	data, err := json.Marshal(s.jobs)
+	if err != nil {
+		return erro.Wrap(err, "marshal jobs")
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := writeFileSync(tmpPath, data); err != nil {
+		return erro.Wrap(err, "write jobs")
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return erro.Wrap(err, "rename jobs")
+	}
+	return syncDir(filepath.Dir(s.path))
+}
@@ -0,0 +1,39 @@
+package filestorage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxbolgarin/bote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJobStoreSurvivesReopen verifies jobs are read back after the store is reopened.
+func TestJobStoreSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "jobs", "jobs.json")
+	at := time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	s, err := NewJobStore(path)
+	require.NoError(t, err)
+	require.NoError(t, s.Save(ctx, bote.ScheduledJob{Key: "b", UserID: bote.NewPlainUserID(1), Handler: "main.remind", At: at.Add(time.Hour)}))
+	require.NoError(t, s.Save(ctx, bote.ScheduledJob{Key: "a", UserID: bote.NewPlainUserID(2), Handler: "main.digest", At: at, Cron: "@daily", Data: []string{"x"}}))
+	require.NoError(t, s.Save(ctx, bote.ScheduledJob{Key: "c", Handler: "main.remind", At: at}))
+	require.NoError(t, s.Delete(ctx, "c"))
+	require.NoError(t, s.Delete(ctx, "missing"))
+
+	s, err = NewJobStore(path)
+	require.NoError(t, err)
+
+	jobs, err := s.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "a", jobs[0].Key, "ordered by time")
+	assert.Equal(t, "@daily", jobs[0].Cron)
+	assert.Equal(t, []string{"x"}, jobs[0].Data)
+	assert.True(t, at.Equal(jobs[0].At))
+	assert.Equal(t, int64(1), *jobs[1].UserID.IDPlain)
+}
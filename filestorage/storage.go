@@ -0,0 +1,537 @@
+// Package filestorage implements an embedded file-based [bote.UsersStorage], [bote.JobStore],
+// [bote.CallbackDataStore] and [bote.IdempotencyStore] for single-node bots.
+//
+// Every change is appended to a JSON log (one record per line) and applied to an in-memory copy of
+// all users, so reads never touch the disk. The log is periodically compacted into a snapshot.
+// On start the snapshot is loaded and the log is replayed over it, which makes restart recovery
+// work without a database server:
+//
+//	storage, err := filestorage.New("data/users")
+//	defer storage.Close()
+//	b, err := bote.New(ctx, token, bote.WithUserDB(storage))
+package filestorage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxbolgarin/bote"
+	"github.com/maxbolgarin/erro"
+	"github.com/maxbolgarin/lang"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	logFile      = "log.jsonl"
+
+	defaultSyncInterval = time.Second
+	defaultCompactEvery = 10000
+)
+
+// SyncPolicy defines when appended records are flushed to the disk with fsync.
+type SyncPolicy string
+
+const (
+	// SyncAlways calls fsync after every record. Nothing is lost on crash, but every action of a user waits for the disk.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval calls fsync every SyncInterval. A crash may lose changes made during the last interval. It is the default.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system. A crash of the OS may lose recent changes.
+	SyncNever SyncPolicy = "never"
+)
+
+// Options contains optional settings of [Storage].
+type Options struct {
+	// SyncPolicy defines when records are flushed to the disk. Default: SyncInterval.
+	SyncPolicy SyncPolicy
+	// SyncInterval is a period of fsync for SyncInterval policy. Default: 1s.
+	SyncInterval time.Duration
+	// CompactEvery is a number of log records after which the log is compacted into a snapshot.
+	// Default: 10000.
+	CompactEvery int
+	// Logger logs errors of UpdateAsync and background work, which cannot return them. Default: no logging.
+	Logger bote.Logger
+}
+
+// WithSyncPolicy sets when records are flushed to the disk.
+func WithSyncPolicy(policy SyncPolicy, interval ...time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.SyncPolicy = policy
+		opts.SyncInterval = lang.First(interval)
+	}
+}
+
+// WithCompactEvery sets a number of log records after which the log is compacted.
+func WithCompactEvery(records int) func(opts *Options) {
+	return func(opts *Options) {
+		opts.CompactEvery = records
+	}
+}
+
+// WithLogger sets a logger for errors that cannot be returned.
+func WithLogger(logger bote.Logger) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Logger = logger
+	}
+}
+
+type recordOp string
+
+const (
+	opInsert recordOp = "insert"
+	opUpdate recordOp = "update"
+	opDelete recordOp = "delete"
+)
+
+// record is a single line of the log. Records are absolute (a diff sets values, it never increments),
+// so replaying a record that is already included in the snapshot does not change the result.
+type record struct {
+	Op   recordOp            `json:"op"`
+	Key  string              `json:"key"`
+	User *bote.UserModel     `json:"user,omitempty"`
+	Diff *bote.UserModelDiff `json:"diff,omitempty"`
+}
+
+// Storage is a [bote.UsersStorage] that keeps users in memory and persists them in a directory.
+// You should call [Storage.Close] on shutdown to flush the log.
+type Storage struct {
+	dir  string
+	opts Options
+	log  bote.Logger
+
+	mu      sync.Mutex
+	users   map[string]bote.UserModel
+	file    *os.File
+	records int
+	dirty   bool
+	closed  bool
+	// namespaces are storages of other bots in subdirectories, they are closed with this one.
+	namespaces map[string]*Storage
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var (
+	_ bote.UsersStorage           = (*Storage)(nil)
+	_ bote.NamespacedUsersStorage = (*Storage)(nil)
+)
+
+// New opens a storage in the directory, creating it if needed, and restores users from the
+// snapshot and the log.
+func New(dir string, optsFuncs ...func(*Options)) (*Storage, error) {
+	var opts Options
+	for _, f := range optsFuncs {
+		f(&opts)
+	}
+	opts.SyncPolicy = lang.Check(opts.SyncPolicy, SyncInterval)
+	opts.SyncInterval = lang.Check(opts.SyncInterval, defaultSyncInterval)
+	opts.CompactEvery = lang.Check(opts.CompactEvery, defaultCompactEvery)
+
+	switch opts.SyncPolicy {
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, erro.New("invalid sync policy", "policy", opts.SyncPolicy)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, erro.Wrap(err, "create directory", "dir", dir)
+	}
+
+	s := &Storage{
+		dir:   dir,
+		opts:  opts,
+		log:   lang.If[bote.Logger](opts.Logger != nil, opts.Logger, noopLogger{}),
+		users: make(map[string]bote.UserModel),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, erro.Wrap(err, "load snapshot")
+	}
+	if err := s.replayLog(); err != nil {
+		return nil, erro.Wrap(err, "replay log")
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, logFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, erro.Wrap(err, "open log")
+	}
+	s.file = file
+
+	go s.background()
+
+	return s, nil
+}
+
+// Insert inserts user in storage.
+func (s *Storage) Insert(_ context.Context, user bote.UserModel) error {
+	key, err := userKey(user.ID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.appendLocked(record{Op: opInsert, Key: key, User: &user}); err != nil {
+		return err
+	}
+	s.users[key] = user
+	return nil
+}
+
+// Find returns user from storage. It returns true as a second argument if user was found without error.
+func (s *Storage) Find(_ context.Context, id bote.FullUserID) (bote.UserModel, bool, error) {
+	key, err := userKey(id)
+	if err != nil {
+		return bote.UserModel{}, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[key]
+	return user, ok, nil
+}
+
+// FindAll returns all users from storage.
+func (s *Storage) FindAll(context.Context) ([]bote.UserModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]bote.UserModel, 0, len(s.users))
+	for _, user := range s.users {
+		out = append(out, user)
+	}
+	return out, nil
+}
+
+// UpdateAsync appends the diff to the log and applies it to the user.
+// It runs synchronously: bote already calls it from an ordered per-user queue.
+func (s *Storage) UpdateAsync(id bote.FullUserID, diff *bote.UserModelDiff) {
+	key, err := userKey(id)
+	if err != nil {
+		s.log.Error("cannot update user", "error", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[key]
+	if !ok {
+		return
+	}
+	if err := s.appendLocked(record{Op: opUpdate, Key: key, Diff: diff}); err != nil {
+		s.log.Error("cannot update user", "user_id", id.String(), "error", err.Error())
+		return
+	}
+	user.ApplyDiff(diff)
+	s.users[key] = user
+}
+
+// Delete deletes user from storage.
+func (s *Storage) Delete(_ context.Context, id bote.FullUserID) error {
+	key, err := userKey(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.appendLocked(record{Op: opDelete, Key: key}); err != nil {
+		return err
+	}
+	delete(s.users, key)
+	return nil
+}
+
+// Namespace returns a storage of users of another bot of [bote.Manager] in the subdirectory with the name.
+// It has the same options and is closed with this storage.
+func (s *Storage) Namespace(_ context.Context, name string) (bote.UsersStorage, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return nil, erro.New("invalid namespace", "name", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, erro.New("storage is closed")
+	}
+	if ns, ok := s.namespaces[name]; ok {
+		return ns, nil
+	}
+
+	ns, err := New(filepath.Join(s.dir, name), func(opts *Options) { *opts = s.opts })
+	if err != nil {
+		return nil, erro.Wrap(err, "open namespace", "name", name)
+	}
+	if s.namespaces == nil {
+		s.namespaces = make(map[string]*Storage)
+	}
+	s.namespaces[name] = ns
+	return ns, nil
+}
+
+// Compact writes all users into a new snapshot and truncates the log.
+// It is called automatically every CompactEvery records.
+func (s *Storage) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compactLocked()
+}
+
+// Close flushes the log and stops background work. The storage cannot be used after Close.
+func (s *Storage) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	namespaces := s.namespaces
+	s.mu.Unlock()
+
+	errList := erro.NewList()
+	for name, ns := range namespaces {
+		if err := ns.Close(); err != nil {
+			errList.Add(erro.Wrap(err, "close namespace", "name", name))
+		}
+	}
+
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.file.Sync(); err != nil {
+		errList.Add(erro.Wrap(err, "sync log"))
+	}
+	if err := s.file.Close(); err != nil {
+		errList.Add(err)
+	}
+	return errList.Err()
+}
+
+func (s *Storage) appendLocked(rec record) error {
+	if s.closed {
+		return erro.New("storage is closed")
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return erro.Wrap(err, "marshal record")
+	}
+	// A single write per record: a crash can only leave a torn last line, which replay skips.
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return erro.Wrap(err, "write record")
+	}
+
+	switch s.opts.SyncPolicy {
+	case SyncAlways:
+		if err := s.file.Sync(); err != nil {
+			return erro.Wrap(err, "sync log")
+		}
+	case SyncInterval:
+		s.dirty = true
+	}
+
+	s.records++
+	if s.records >= s.opts.CompactEvery {
+		if err := s.compactLocked(); err != nil {
+			// The record is already in the log, compaction will be retried with the next one.
+			s.log.Error("cannot compact log", "dir", s.dir, "error", err.Error())
+		}
+	}
+	return nil
+}
+
+// compactLocked replaces the snapshot atomically (write a temp file, fsync, rename) and only then
+// truncates the log. A crash between the two steps leaves records that are already in the
+// snapshot; replaying them again is harmless.
+func (s *Storage) compactLocked() error {
+	data, err := json.Marshal(s.users)
+	if err != nil {
+		return erro.Wrap(err, "marshal snapshot")
+	}
+
+	tmpPath := filepath.Join(s.dir, snapshotFile+".tmp")
+	if err := writeFileSync(tmpPath, data); err != nil {
+		return erro.Wrap(err, "write snapshot")
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, snapshotFile)); err != nil {
+		return erro.Wrap(err, "rename snapshot")
+	}
+	if err := syncDir(s.dir); err != nil {
+		return erro.Wrap(err, "sync directory")
+	}
+
+	if err := s.file.Truncate(0); err != nil {
+		return erro.Wrap(err, "truncate log")
+	}
+	if err := s.file.Sync(); err != nil {
+		return erro.Wrap(err, "sync log")
+	}
+	s.records = 0
+	s.dirty = false
+	return nil
+}
+
+func (s *Storage) background() {
+	defer close(s.done)
+
+	if s.opts.SyncPolicy != SyncInterval {
+		<-s.stop
+		return
+	}
+
+	ticker := time.NewTicker(s.opts.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.dirty {
+				if err := s.file.Sync(); err != nil {
+					s.log.Error("cannot sync log", "dir", s.dir, "error", err.Error())
+				} else {
+					s.dirty = false
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Storage) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &s.users)
+}
+
+func (s *Storage) replayLog() error {
+	path := filepath.Join(s.dir, logFile)
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var offset int64 // End of the last complete record
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(data) == 0 {
+				return nil
+			}
+			// Torn write of the last record before a crash: it was never acknowledged.
+			// Cut it off, otherwise the next record is appended to the same line and the log cannot be read.
+			if len(bytes.TrimSpace(data)) > 0 {
+				s.log.Warn("skip incomplete last log record", "dir", s.dir, "line", line)
+			}
+			if err := os.Truncate(path, offset); err != nil {
+				return erro.Wrap(err, "truncate incomplete record")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(data))
+
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return erro.Wrap(err, "decode record", "line", strconv.Itoa(line))
+		}
+		s.applyRecord(rec)
+		s.records++
+	}
+}
+
+func (s *Storage) applyRecord(rec record) {
+	switch rec.Op {
+	case opInsert:
+		if rec.User != nil {
+			s.users[rec.Key] = *rec.User
+		}
+	case opUpdate:
+		if user, ok := s.users[rec.Key]; ok {
+			user.ApplyDiff(rec.Diff)
+			s.users[rec.Key] = user
+		}
+	case opDelete:
+		delete(s.users, rec.Key)
+	}
+}
+
+// userKey returns a key of the user: plain ID or HMAC in strict privacy mode.
+func userKey(id bote.FullUserID) (string, error) {
+	switch {
+	case id.IDPlain != nil:
+		return strconv.FormatInt(*id.IDPlain, 10), nil
+	case id.IDHMAC != nil:
+		return "hmac:" + *id.IDHMAC, nil
+	default:
+		return "", erro.New("user ID has neither plain ID nor HMAC")
+	}
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Debug(string, ...any) {}
+func (noopLogger) Info(string, ...any)  {}
+func (noopLogger) Warn(string, ...any)  {}
+func (noopLogger) Error(string, ...any) {}
@@ -0,0 +1,257 @@
+package filestorage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxbolgarin/bote"
+	"github.com/maxbolgarin/lang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testUser(id int64) bote.UserModel {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	return bote.UserModel{
+		ID:           bote.NewPlainUserID(id),
+		LanguageCode: bote.LanguageEnglish,
+		Info:         bote.UserInfo{Username: "alice"},
+		Messages:     bote.UserMessages{MainID: 10, HistoryIDs: []int{5}},
+		State: bote.MessagesState{
+			Main:          "menu",
+			MessageStates: map[int]bote.UserState{10: "menu"},
+		},
+		Stats:  bote.UserStat{LastSeenTime: now, CreatedTime: now},
+		Values: map[string]any{"name": "Alice"},
+	}
+}
+
+func open(t *testing.T, dir string, optsFuncs ...func(*Options)) *Storage {
+	t.Helper()
+	s, err := New(dir, optsFuncs...)
+	require.NoError(t, err)
+	return s
+}
+
+// TestCRUD verifies basic operations are served from memory.
+func TestCRUD(t *testing.T) {
+	ctx := context.Background()
+	s := open(t, t.TempDir())
+	defer s.Close()
+
+	_, found, err := s.Find(ctx, bote.NewPlainUserID(1))
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, s.Insert(ctx, testUser(1)))
+	require.NoError(t, s.Insert(ctx, testUser(2)))
+
+	s.UpdateAsync(bote.NewPlainUserID(1), &bote.UserModelDiff{
+		State: &bote.UserStateDiff{Main: lang.Ptr(bote.UserState("settings"))},
+	})
+	user, found, err := s.Find(ctx, bote.NewPlainUserID(1))
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, bote.UserState("settings"), user.State.Main)
+
+	require.NoError(t, s.Delete(ctx, bote.NewPlainUserID(2)))
+	all, err := s.FindAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	_, _, err = s.Find(ctx, bote.FullUserID{})
+	assert.Error(t, err)
+}
+
+// TestRecoverFromLog verifies a reopened storage replays the log.
+func TestRecoverFromLog(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s := open(t, dir, WithSyncPolicy(SyncAlways))
+	require.NoError(t, s.Insert(ctx, testUser(1)))
+	require.NoError(t, s.Insert(ctx, testUser(2)))
+	s.UpdateAsync(bote.NewPlainUserID(1), &bote.UserModelDiff{
+		Messages: &bote.UserMessagesDiff{MainID: lang.Ptr(42)},
+		Values:   map[string]any{"step": "done"},
+	})
+	require.NoError(t, s.Delete(ctx, bote.NewPlainUserID(2)))
+	require.NoError(t, s.Close())
+
+	s = open(t, dir)
+	defer s.Close()
+
+	user, found, err := s.Find(ctx, bote.NewPlainUserID(1))
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, 42, user.Messages.MainID)
+	assert.Equal(t, "done", user.Values["step"])
+	assert.Equal(t, bote.UserState("menu"), user.State.MessageStates[10])
+
+	_, found, err = s.Find(ctx, bote.NewPlainUserID(2))
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+// TestCompaction verifies the log is folded into the snapshot and both are used on restart.
+func TestCompaction(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s := open(t, dir, WithCompactEvery(3))
+	for i := int64(1); i <= 4; i++ {
+		require.NoError(t, s.Insert(ctx, testUser(i)))
+	}
+	require.NoError(t, s.Close())
+
+	_, err := os.Stat(filepath.Join(dir, snapshotFile))
+	require.NoError(t, err, "snapshot must be written after 3 records")
+	assert.Equal(t, 1, countLines(t, filepath.Join(dir, logFile)), "only the record after compaction remains in the log")
+
+	s = open(t, dir)
+	defer s.Close()
+
+	all, err := s.FindAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+}
+
+// TestReplayAfterCompactionCrash verifies records already included in the snapshot can be replayed
+// again, as happens when the process dies between renaming the snapshot and truncating the log.
+func TestReplayAfterCompactionCrash(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s := open(t, dir)
+	require.NoError(t, s.Insert(ctx, testUser(1)))
+	s.UpdateAsync(bote.NewPlainUserID(1), &bote.UserModelDiff{Messages: &bote.UserMessagesDiff{MainID: lang.Ptr(7)}})
+	require.NoError(t, s.Close())
+
+	logData, err := os.ReadFile(filepath.Join(dir, logFile))
+	require.NoError(t, err)
+
+	s = open(t, dir)
+	require.NoError(t, s.Compact())
+	require.NoError(t, s.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, logFile), logData, 0o600))
+
+	s = open(t, dir)
+	defer s.Close()
+
+	user, found, err := s.Find(ctx, bote.NewPlainUserID(1))
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, 7, user.Messages.MainID)
+}
+
+// TestTornLastRecord verifies a partially written last line is skipped instead of failing the start
+// and does not break records written after it.
+func TestTornLastRecord(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s := open(t, dir)
+	require.NoError(t, s.Insert(ctx, testUser(1)))
+	require.NoError(t, s.Close())
+
+	f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"op":"insert","key":"2","us`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s = open(t, dir)
+	all, err := s.FindAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	// The torn tail is cut off, so the next record starts on its own line
+	require.NoError(t, s.Insert(ctx, testUser(3)))
+	require.NoError(t, s.Close())
+
+	s = open(t, dir)
+	defer s.Close()
+
+	all, err = s.FindAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+	_, found, err := s.Find(ctx, testUser(3).ID)
+	require.NoError(t, err)
+	assert.True(t, found)
+}
+
+// TestHMACKey verifies users are keyed by HMAC in strict privacy mode.
+func TestHMACKey(t *testing.T) {
+	ctx := context.Background()
+	s := open(t, t.TempDir())
+	defer s.Close()
+
+	user := testUser(0)
+	user.ID = bote.FullUserID{IDHMAC: lang.Ptr("abcdef"), IDEnc: lang.Ptr("0011")}
+	require.NoError(t, s.Insert(ctx, user))
+
+	got, found, err := s.Find(ctx, bote.FullUserID{IDHMAC: lang.Ptr("abcdef")})
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "0011", *got.ID.IDEnc)
+}
+
+// TestInvalidOptions verifies unknown sync policies are rejected.
+func TestInvalidOptions(t *testing.T) {
+	_, err := New(t.TempDir(), WithSyncPolicy("sometimes"))
+	assert.Error(t, err)
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	n := 0
+	for _, b := range data {
+		if b == '\n' {
+			n++
+		}
+	}
+	return n
+}
+
+// TestNamespace verifies users of different bots are kept apart and namespaces are closed with the storage.
+func TestNamespace(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := open(t, dir)
+
+	shop, err := s.Namespace(ctx, "shop")
+	require.NoError(t, err)
+	support, err := s.Namespace(ctx, "support")
+	require.NoError(t, err)
+
+	again, err := s.Namespace(ctx, "shop")
+	require.NoError(t, err)
+	assert.Same(t, shop, again)
+
+	require.NoError(t, shop.Insert(ctx, testUser(1)))
+	_, found, err := support.Find(ctx, bote.NewPlainUserID(1))
+	require.NoError(t, err)
+	assert.False(t, found)
+	_, found, err = s.Find(ctx, bote.NewPlainUserID(1))
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	_, err = s.Namespace(ctx, "../escape")
+	assert.Error(t, err)
+
+	require.NoError(t, s.Close())
+	assert.Error(t, shop.Insert(ctx, testUser(2)), "namespace is closed with the storage")
+
+	s = open(t, dir)
+	defer s.Close()
+	shop, err = s.Namespace(ctx, "shop")
+	require.NoError(t, err)
+	_, found, err = shop.Find(ctx, bote.NewPlainUserID(1))
+	require.NoError(t, err)
+	assert.True(t, found)
+}
@@ -0,0 +1,144 @@
+package bote
+
+import (
+	"errors"
+	"slices"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+)
+
+// ErrIllegalTransition is returned by [Context.Transition] when the target state is not reachable
+// from the current one: the edge is not declared, the target is not registered or its guard
+// rejected the move. The rejection is already logged, so returning it from a handler does not send
+// a general error message to the user.
+var ErrIllegalTransition = errors.New("illegal state transition")
+
+// StateNode describes a single state of the declarative state machine. See [Bot.RegisterState].
+type StateNode struct {
+	// Render draws the screen of the state. It should send or edit the main message with the
+	// state it renders, e.g. ctx.EditMain(state, msg, kb). It is required.
+	// Render is also used as [InitBundle.Handler] to restore the screen after a bot restart,
+	// unless the stateMap passed to [Bot.Start] provides its own bundle for the state.
+	Render HandlerFunc
+
+	// OnEnter is called when the machine enters the state, right before Render. It is optional.
+	// An error returned from OnEnter aborts the transition before anything is rendered.
+	OnEnter HandlerFunc
+
+	// OnExit is called when the machine leaves the state, before OnEnter of the target. It is optional.
+	// An error returned from OnExit aborts the transition and the user stays in the current state.
+	OnExit HandlerFunc
+
+	// OnText handles text messages sent by the user while the state is active. It is optional.
+	// Providing it registers the state as a text state (see [RegisterTextStates]), so there is no
+	// need to switch over [User.StateMain] in a handler passed to [Bot.SetTextHandler].
+	OnText HandlerFunc
+
+	// Transitions lists the states reachable from this one with [Context.Transition].
+	// A state without transitions is terminal: the machine can only leave it with a plain Send/Edit.
+	Transitions []State
+
+	// Guard is called before entering the state with the state the user is leaving.
+	// Returning false rejects the transition. It is optional.
+	Guard func(ctx Context, from State) bool
+
+	// Data is passed to Render when the state is restored after restart, see [InitBundle.Data].
+	Data string
+}
+
+// RegisterState adds the state to the declarative state machine of the bot.
+// Handlers move between registered states with [Context.Transition], which validates the edge,
+// runs exit and enter hooks and renders the target screen.
+// You should register all states before calling [Bot.Start].
+func (b *Bot) RegisterState(state State, node StateNode) {
+	if state == nil || node.Render == nil {
+		b.bot.log.Error("cannot register state without render handler", "state", state)
+		b.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return
+	}
+
+	b.states.Set(state.String(), node)
+
+	// Render doubles as the restart-recovery handler; an explicit stateMap entry passed to
+	// Start overrides it because Start sets its bundles after this call.
+	if _, ok := b.stateMap.Lookup(state.String()); !ok {
+		b.stateMap.Set(state.String(), InitBundle{Handler: node.Render, Data: node.Data})
+	}
+
+	if node.OnText != nil {
+		RegisterTextStates(state)
+		b.Handle(tele.OnText, b.textRouter)
+	}
+}
+
+// textRouter dispatches a text message to the OnText hook of the state the user is typing into,
+// falling back to the handler set by [Bot.SetTextHandler].
+func (b *Bot) textRouter(ctx Context) error {
+	if ctxImpl, ok := ctx.(*contextImpl); ok && ctxImpl.user != nil && !ctxImpl.user.isPublic {
+		st, ok := ctxImpl.user.State(ctxImpl.MessageID())
+		if !ok || st.NotChanged() {
+			st = ctxImpl.user.StateMain()
+		}
+		if node, ok := b.states.Lookup(st.String()); ok && node.OnText != nil {
+			return node.OnText(ctx)
+		}
+	}
+	if b.textHandler != nil {
+		return b.textHandler(ctx)
+	}
+	return nil
+}
+
+func (c *contextImpl) Transition(to State) error {
+	if !c.validateUserInput("Transition", to) {
+		return nil
+	}
+	from := c.user.StateMain()
+	if to == nil {
+		return c.rejectTransition(from, to, "target state is nil")
+	}
+
+	toNode, ok := c.bt.states.Lookup(to.String())
+	if !ok {
+		return c.rejectTransition(from, to, "target state is not registered")
+	}
+
+	// States outside of the machine (e.g. FirstRequest after /start) may enter any registered state.
+	fromNode, fromRegistered := c.bt.states.Lookup(from.String())
+	if fromRegistered && !slices.ContainsFunc(fromNode.Transitions, func(s State) bool {
+		return s.String() == to.String()
+	}) {
+		return c.rejectTransition(from, to, "transition is not declared")
+	}
+
+	if toNode.Guard != nil && !toNode.Guard(c, from) {
+		return c.rejectTransition(from, to, "rejected by guard")
+	}
+
+	if fromRegistered && fromNode.OnExit != nil {
+		if err := fromNode.OnExit(c); err != nil {
+			return err
+		}
+	}
+	if toNode.OnEnter != nil {
+		if err := toNode.OnEnter(c); err != nil {
+			return err
+		}
+	}
+	if err := toNode.Render(c); err != nil {
+		return err
+	}
+
+	if st := c.user.StateMain(); st.String() != to.String() {
+		c.bt.bot.log.Warn("render did not set target state", c.bt.userFields(c.user, "from", from, "to", to)...)
+		c.bt.bot.metr.incError(MetricsErrorInvalidUserState, MetricsErrorSeverityLow)
+	}
+
+	return nil
+}
+
+func (c *contextImpl) rejectTransition(from, to State, reason string) error {
+	c.bt.bot.log.Warn("illegal state transition", c.bt.userFields(c.user, "from", from, "to", to, "reason", reason)...)
+	c.bt.bot.metr.incError(MetricsErrorInvalidUserState, MetricsErrorSeverityLow)
+	return ErrIllegalTransition
+}
@@ -0,0 +1,187 @@
+package bote
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fsmMenu     UserState = "fsm_menu"
+	fsmSettings UserState = "fsm_settings"
+	fsmProfile  UserState = "fsm_profile"
+	fsmName     UserState = "fsm_name"
+)
+
+// renderTo returns a Render hook that sets the state without talking to Telegram (the test bot is
+// offline) and records its call into calls.
+func renderTo(state State, calls *[]string) HandlerFunc {
+	return func(ctx Context) error {
+		*calls = append(*calls, "render:"+state.String())
+		ctx.(*contextImpl).user.setState(state)
+		return nil
+	}
+}
+
+func fsmHook(name string, calls *[]string) HandlerFunc {
+	return func(Context) error {
+		*calls = append(*calls, name)
+		return nil
+	}
+}
+
+// TestTransitionRunsHooksInOrder verifies exit → enter → render on a declared edge.
+func TestTransitionRunsHooksInOrder(t *testing.T) {
+	bot := setupTestBot(t)
+
+	var calls []string
+	bot.RegisterState(fsmMenu, StateNode{
+		Render:      renderTo(fsmMenu, &calls),
+		OnExit:      fsmHook("exit:menu", &calls),
+		Transitions: []State{fsmSettings},
+	})
+	bot.RegisterState(fsmSettings, StateNode{
+		Render:  renderTo(fsmSettings, &calls),
+		OnEnter: fsmHook("enter:settings", &calls),
+	})
+
+	ctx := NewContext(bot, 7001, 1)
+	require.NoError(t, ctx.Transition(fsmMenu), "entering the machine from an unregistered state is allowed")
+	require.NoError(t, ctx.Transition(fsmSettings))
+
+	assert.Equal(t, []string{"render:fsm_menu", "exit:menu", "enter:settings", "render:fsm_settings"}, calls)
+	assert.Equal(t, fsmSettings, ctx.User().StateMain())
+}
+
+// TestTransitionRejectsUndeclaredEdge verifies an edge missing from Transitions is rejected
+// without running any hook.
+func TestTransitionRejectsUndeclaredEdge(t *testing.T) {
+	bot := setupTestBot(t)
+
+	var calls []string
+	bot.RegisterState(fsmMenu, StateNode{Render: renderTo(fsmMenu, &calls), Transitions: []State{fsmSettings}})
+	bot.RegisterState(fsmSettings, StateNode{Render: renderTo(fsmSettings, &calls)})
+	bot.RegisterState(fsmProfile, StateNode{Render: renderTo(fsmProfile, &calls), OnEnter: fsmHook("enter:profile", &calls)})
+
+	ctx := NewContext(bot, 7002, 1)
+	require.NoError(t, ctx.Transition(fsmMenu))
+	calls = nil
+
+	err := ctx.Transition(fsmProfile)
+	assert.True(t, errors.Is(err, ErrIllegalTransition))
+	assert.Empty(t, calls, "no hook should run on an illegal transition")
+	assert.Equal(t, fsmMenu, ctx.User().StateMain())
+
+	// Settings is terminal: nothing is reachable from it.
+	require.NoError(t, ctx.Transition(fsmSettings))
+	assert.ErrorIs(t, ctx.Transition(fsmMenu), ErrIllegalTransition)
+}
+
+// TestTransitionRejectsUnregisteredTarget verifies a target unknown to the machine is rejected.
+func TestTransitionRejectsUnregisteredTarget(t *testing.T) {
+	bot := setupTestBot(t)
+
+	ctx := NewContext(bot, 7003, 1)
+	assert.ErrorIs(t, ctx.Transition(UserState("fsm_unknown")), ErrIllegalTransition)
+	assert.ErrorIs(t, ctx.Transition(nil), ErrIllegalTransition)
+}
+
+// TestTransitionGuard verifies the guard receives the source state and can veto the move.
+func TestTransitionGuard(t *testing.T) {
+	bot := setupTestBot(t)
+
+	var calls []string
+	allow := false
+	var gotFrom State
+	bot.RegisterState(fsmMenu, StateNode{Render: renderTo(fsmMenu, &calls), Transitions: []State{fsmProfile}})
+	bot.RegisterState(fsmProfile, StateNode{
+		Render: renderTo(fsmProfile, &calls),
+		Guard: func(_ Context, from State) bool {
+			gotFrom = from
+			return allow
+		},
+	})
+
+	ctx := NewContext(bot, 7004, 1)
+	require.NoError(t, ctx.Transition(fsmMenu))
+
+	assert.ErrorIs(t, ctx.Transition(fsmProfile), ErrIllegalTransition)
+	assert.Equal(t, fsmMenu, gotFrom)
+	assert.Equal(t, fsmMenu, ctx.User().StateMain())
+
+	allow = true
+	require.NoError(t, ctx.Transition(fsmProfile))
+	assert.Equal(t, fsmProfile, ctx.User().StateMain())
+}
+
+// TestTransitionExitErrorAborts verifies an OnExit error keeps the user in the current state.
+func TestTransitionExitErrorAborts(t *testing.T) {
+	bot := setupTestBot(t)
+
+	var calls []string
+	exitErr := errors.New("unsaved changes")
+	bot.RegisterState(fsmMenu, StateNode{
+		Render:      renderTo(fsmMenu, &calls),
+		OnExit:      func(Context) error { return exitErr },
+		Transitions: []State{fsmSettings},
+	})
+	bot.RegisterState(fsmSettings, StateNode{Render: renderTo(fsmSettings, &calls)})
+
+	ctx := NewContext(bot, 7005, 1)
+	require.NoError(t, ctx.Transition(fsmMenu))
+	assert.ErrorIs(t, ctx.Transition(fsmSettings), exitErr)
+	assert.Equal(t, fsmMenu, ctx.User().StateMain())
+}
+
+// TestRegisterStateFillsStateMap verifies Render is used for restart recovery and OnText makes the
+// state a text state.
+func TestRegisterStateFillsStateMap(t *testing.T) {
+	bot := setupTestBot(t)
+
+	var calls []string
+	bot.RegisterState(fsmName, StateNode{
+		Render: renderTo(fsmName, &calls),
+		OnText: fsmHook("text:name", &calls),
+		Data:   "payload",
+	})
+
+	bundle, ok := bot.stateMap.Lookup(fsmName.String())
+	require.True(t, ok)
+	assert.Equal(t, "payload", bundle.Data)
+	assert.True(t, textStateManager.has(fsmName), "state with OnText must be a text state")
+
+	// Invalid registrations are ignored.
+	bot.RegisterState(fsmProfile, StateNode{})
+	_, ok = bot.states.Lookup(fsmProfile.String())
+	assert.False(t, ok)
+}
+
+// TestTextRouterDispatchesByState verifies text goes to the OnText hook of the active state and
+// to the fallback text handler otherwise.
+func TestTextRouterDispatchesByState(t *testing.T) {
+	bot := setupTestBot(t)
+
+	var calls []string
+	bot.RegisterState(fsmName, StateNode{Render: renderTo(fsmName, &calls), OnText: fsmHook("text:name", &calls)})
+	bot.SetTextHandler(fsmHook("fallback", &calls))
+
+	ctx := NewContextText(bot, 7006, 1, "Alice")
+	require.NoError(t, bot.textRouter(ctx))
+
+	ctx.(*contextImpl).user.setState(fsmName)
+	require.NoError(t, bot.textRouter(ctx))
+
+	assert.Equal(t, []string{"fallback", "text:name"}, calls)
+}
+
+// TestHandleErrorSwallowsIllegalTransition verifies a rejected transition returned from a handler
+// does not turn into a general error for the user.
+func TestHandleErrorSwallowsIllegalTransition(t *testing.T) {
+	bot := setupTestBot(t)
+
+	impl := NewContext(bot, 7007, 1).(*contextImpl)
+	assert.Nil(t, impl.handleError(ErrIllegalTransition))
+	assert.Equal(t, 0, impl.user.Messages().ErrorID)
+}
@@ -0,0 +1,404 @@
+package bote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxbolgarin/erro"
+	"github.com/maxbolgarin/lang"
+	tele "github.com/maxbolgarin/telebot/v4"
+)
+
+// InboxUpdate is an incoming webhook update saved in [InboxStore].
+type InboxUpdate struct {
+	// ID is the update_id of the update.
+	ID int
+	// Data is the update as it came from Telegram (JSON).
+	Data []byte
+	// Received is the time when the update was saved.
+	Received time.Time
+}
+
+// InboxStore persists incoming webhook updates until their handlers return, see [WebhookInboxConfig].
+// You can implement it on top of your database, [FileInboxStore] is used by default.
+// The sqlstorage package provides a store for PostgreSQL and SQLite.
+type InboxStore interface {
+	// Put saves the update. It returns false without an error if an update with the same ID is
+	// already saved: it is pending or it was handled within the deduplication window.
+	Put(ctx context.Context, upd InboxUpdate) (bool, error)
+	// Done marks the update as handled. It should be remembered for deduplication for some time.
+	Done(ctx context.Context, updateID int) error
+	// Pending returns updates that are saved but not handled, ordered by ID.
+	Pending(ctx context.Context) ([]InboxUpdate, error)
+}
+
+const (
+	inboxPendingExt = ".json"
+	inboxDoneExt    = ".done"
+	inboxTempExt    = ".tmp"
+
+	inboxSweepInterval = time.Hour
+	inboxMetricsPeriod = time.Second
+)
+
+// FileInboxStore is an [InboxStore] that keeps every update in its own file in a directory.
+// A pending update is written to a temporary file, synced and renamed, so a crash never leaves
+// a partially written update. Handled updates are kept as empty files until the deduplication window ends.
+type FileInboxStore struct {
+	dir string
+	ttl time.Duration
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewFileInboxStore creates a store in the directory, it is created if it does not exist.
+// Handled updates are remembered for deduplication for dedupTTL, 24 hours by default.
+func NewFileInboxStore(dir string, dedupTTL ...time.Duration) (*FileInboxStore, error) {
+	if dir == "" {
+		return nil, erro.New("inbox directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, erro.Wrap(err, "create inbox directory")
+	}
+	return &FileInboxStore{
+		dir: dir,
+		ttl: lang.Check(lang.First(dedupTTL), defaultWebhookInboxDedupTTL),
+	}, nil
+}
+
+// Put saves the update to a file named by its ID.
+func (s *FileInboxStore) Put(_ context.Context, upd InboxUpdate) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ext := range []string{inboxPendingExt, inboxDoneExt} {
+		if _, err := os.Stat(s.path(upd.ID, ext)); err == nil {
+			return false, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return false, erro.Wrap(err, "check update")
+		}
+	}
+
+	tmp := s.path(upd.ID, inboxTempExt)
+	if err := writeFileSync(tmp, upd.Data); err != nil {
+		_ = os.Remove(tmp)
+		return false, erro.Wrap(err, "write update")
+	}
+	if err := os.Rename(tmp, s.path(upd.ID, inboxPendingExt)); err != nil {
+		_ = os.Remove(tmp)
+		return false, erro.Wrap(err, "rename update")
+	}
+	if err := syncDir(s.dir); err != nil {
+		return false, erro.Wrap(err, "sync inbox directory")
+	}
+
+	return true, nil
+}
+
+// Done replaces the file of the update with an empty marker and removes markers older than the deduplication window.
+func (s *FileInboxStore) Done(_ context.Context, updateID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	done := s.path(updateID, inboxDoneExt)
+	if err := os.Rename(s.path(updateID, inboxPendingExt), done); err != nil {
+		return erro.Wrap(err, "rename update")
+	}
+	if err := os.Truncate(done, 0); err != nil {
+		return erro.Wrap(err, "truncate update")
+	}
+	now := time.Now()
+	if err := os.Chtimes(done, now, now); err != nil {
+		return erro.Wrap(err, "touch update")
+	}
+
+	if time.Since(s.lastSweep) > inboxSweepInterval {
+		s.lastSweep = now
+		return s.sweep(now)
+	}
+	return nil
+}
+
+// Pending returns updates that are not handled.
+func (s *FileInboxStore) Pending(_ context.Context) ([]InboxUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, erro.Wrap(err, "read inbox directory")
+	}
+
+	var out []InboxUpdate
+	for _, e := range entries {
+		id, ok := inboxFileID(e.Name(), inboxPendingExt)
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, erro.Wrap(err, "stat update", "update_id", id)
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, erro.Wrap(err, "read update", "update_id", id)
+		}
+		out = append(out, InboxUpdate{ID: id, Data: data, Received: info.ModTime()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	return out, nil
+}
+
+// sweep removes markers of handled updates older than the deduplication window and leftover temporary files.
+func (s *FileInboxStore) sweep(now time.Time) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return erro.Wrap(err, "read inbox directory")
+	}
+	for _, e := range entries {
+		_, isDone := inboxFileID(e.Name(), inboxDoneExt)
+		_, isTemp := inboxFileID(e.Name(), inboxTempExt)
+		if !isDone && !isTemp {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || now.Sub(info.ModTime()) < s.ttl {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, e.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return erro.Wrap(err, "remove handled update")
+		}
+	}
+	return nil
+}
+
+func (s *FileInboxStore) path(updateID int, ext string) string {
+	return filepath.Join(s.dir, strconv.Itoa(updateID)+ext)
+}
+
+func inboxFileID(name, ext string) (int, bool) {
+	if !strings.HasSuffix(name, ext) {
+		return 0, false
+	}
+	id, err := strconv.Atoi(strings.TrimSuffix(name, ext))
+	return id, err == nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// webhookInbox saves webhook updates before they are acknowledged and passes them to the bot in order.
+// An update stays pending until its handlers return, pending updates are passed again after restart.
+type webhookInbox struct {
+	store InboxStore
+	log   Logger
+	metr  *metrics
+
+	mu       sync.Mutex
+	pending  map[int]time.Time // Saved and not handled updates with their receive time
+	backlog  []tele.Update     // Saved updates that are not passed to the bot yet
+	restored map[int]struct{}  // Pending updates restored after restart, they are recorded before restart
+	signal   chan struct{}
+}
+
+func newWebhookInbox(store InboxStore, log Logger, metr *metrics) *webhookInbox {
+	return &webhookInbox{
+		store:    store,
+		log:      log,
+		metr:     metr,
+		pending:  make(map[int]time.Time),
+		restored: make(map[int]struct{}),
+		signal:   make(chan struct{}, 1),
+	}
+}
+
+// restore puts updates that were not handled before restart to the backlog.
+func (in *webhookInbox) restore(ctx context.Context) error {
+	saved, err := in.store.Pending(ctx)
+	if err != nil {
+		return erro.Wrap(err, "get pending updates")
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	for _, s := range saved {
+		if _, ok := in.pending[s.ID]; ok {
+			continue
+		}
+		var upd tele.Update
+		if err := json.Unmarshal(s.Data, &upd); err != nil {
+			in.log.Error("failed to parse pending update", "update_id", s.ID, "error", err.Error())
+			in.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+			continue
+		}
+		in.pending[s.ID] = s.Received
+		in.restored[s.ID] = struct{}{}
+		in.backlog = append(in.backlog, upd)
+	}
+	in.updateMetricsLocked()
+
+	if len(saved) > 0 {
+		in.log.Info("restored pending webhook updates", "count", len(saved))
+		in.notify()
+	}
+	return nil
+}
+
+// put saves the update and adds it to the backlog. It returns false if the update is a duplicate.
+func (in *webhookInbox) put(ctx context.Context, upd tele.Update, data []byte) (bool, error) {
+	now := time.Now()
+	ok, err := in.store.Put(ctx, InboxUpdate{ID: upd.ID, Data: data, Received: now})
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		in.metr.incWebhookInboxDuplicates()
+		return false, nil
+	}
+
+	in.mu.Lock()
+	in.pending[upd.ID] = now
+	in.backlog = append(in.backlog, upd)
+	in.updateMetricsLocked()
+	in.mu.Unlock()
+
+	in.notify()
+	return true, nil
+}
+
+// run passes updates from the backlog to the bot until stop is closed.
+func (in *webhookInbox) run(updates chan tele.Update, stop chan struct{}) {
+	ticker := time.NewTicker(inboxMetricsPeriod)
+	defer ticker.Stop()
+
+	for {
+		in.mu.Lock()
+		var (
+			upd  tele.Update
+			next bool
+		)
+		if len(in.backlog) > 0 {
+			upd, next = in.backlog[0], true
+		}
+		in.mu.Unlock()
+
+		if !next {
+			select {
+			case <-in.signal:
+			case <-ticker.C:
+				in.updateMetrics()
+			case <-stop:
+				return
+			}
+			continue
+		}
+
+		select {
+		case updates <- upd:
+			in.mu.Lock()
+			in.backlog = in.backlog[1:]
+			in.mu.Unlock()
+		case <-ticker.C:
+			in.updateMetrics()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// isRestored returns true if the update was restored from the store after restart and is not handled yet.
+func (in *webhookInbox) isRestored(updateID int) bool {
+	if in == nil {
+		return false
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	_, ok := in.restored[updateID]
+	return ok
+}
+
+// done marks the update as handled. It is called for every update, including updates not from the inbox.
+func (in *webhookInbox) done(updateID int) {
+	if in == nil {
+		return
+	}
+
+	in.mu.Lock()
+	if _, ok := in.pending[updateID]; !ok {
+		in.mu.Unlock()
+		return
+	}
+	delete(in.pending, updateID)
+	delete(in.restored, updateID)
+	in.updateMetricsLocked()
+	in.mu.Unlock()
+
+	if err := in.store.Done(context.Background(), updateID); err != nil {
+		in.log.Error("failed to mark webhook update as handled", "update_id", updateID, "error", err.Error())
+		in.metr.incError(MetricsErrorInternal, MetricsErrorSeverityLow)
+	}
+}
+
+func (in *webhookInbox) notify() {
+	select {
+	case in.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (in *webhookInbox) updateMetrics() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.updateMetricsLocked()
+}
+
+// updateMetricsLocked sets depth of the inbox and age of the oldest pending update.
+func (in *webhookInbox) updateMetricsLocked() {
+	var oldest time.Time
+	for _, received := range in.pending {
+		if oldest.IsZero() || received.Before(oldest) {
+			oldest = received
+		}
+	}
+	var lag time.Duration
+	if !oldest.IsZero() {
+		lag = time.Since(oldest)
+	}
+	in.metr.setWebhookInbox(len(in.pending), lag)
+}
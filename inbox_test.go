@@ -0,0 +1,237 @@
+package bote
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func inboxUpdateJSON(t *testing.T, id int, text string) []byte {
+	data, err := json.Marshal(tele.Update{
+		ID: id,
+		Message: &tele.Message{
+			ID:     id,
+			Text:   text,
+			Sender: &tele.User{ID: 42},
+			Chat:   &tele.Chat{ID: 42, Type: tele.ChatPrivate},
+		},
+	})
+	require.NoError(t, err)
+	return data
+}
+
+func TestFileInboxStore(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "inbox")
+
+	s, err := NewFileInboxStore(dir)
+	require.NoError(t, err)
+
+	ok, err := s.Put(ctx, InboxUpdate{ID: 2, Data: []byte(`{"update_id":2}`)})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = s.Put(ctx, InboxUpdate{ID: 1, Data: []byte(`{"update_id":1}`)})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Retry of a pending update
+	ok, err = s.Put(ctx, InboxUpdate{ID: 2, Data: []byte(`{"update_id":2}`)})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	pending, err := s.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, 1, pending[0].ID)
+	assert.Equal(t, `{"update_id":1}`, string(pending[0].Data))
+	assert.False(t, pending[0].Received.IsZero())
+	assert.Equal(t, 2, pending[1].ID)
+
+	require.NoError(t, s.Done(ctx, 1))
+	pending, err = s.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 2, pending[0].ID)
+
+	// Retry of a handled update
+	ok, err = s.Put(ctx, InboxUpdate{ID: 1, Data: []byte(`{"update_id":1}`)})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// A new store on the same directory sees the same updates
+	s2, err := NewFileInboxStore(dir, time.Minute)
+	require.NoError(t, err)
+	pending, err = s2.Pending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	// Markers of handled updates are removed after the deduplication window
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "7.tmp"), []byte("partial"), 0o600))
+	require.NoError(t, s2.sweep(time.Now().Add(2*time.Minute)))
+	_, err = os.Stat(filepath.Join(dir, "1.done"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "7.tmp"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "2.json"))
+	assert.NoError(t, err)
+
+	_, err = NewFileInboxStore("")
+	assert.Error(t, err)
+}
+
+func TestWebhookInbox(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileInboxStore(t.TempDir())
+	require.NoError(t, err)
+
+	registry := prometheus.NewRegistry()
+	metr := newMetrics(MetricsConfig{Registry: registry})
+
+	in := newWebhookInbox(store, noopLogger{}, metr)
+	updates := make(chan tele.Update, 10)
+	stop := make(chan struct{})
+	go in.run(updates, stop)
+
+	for _, id := range []int{10, 11} {
+		ok, err := in.put(ctx, tele.Update{ID: id}, inboxUpdateJSON(t, id, "hi"))
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+	ok, err := in.put(ctx, tele.Update{ID: 10}, inboxUpdateJSON(t, 10, "hi"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.Equal(t, 10, (<-updates).ID)
+	assert.Equal(t, 11, (<-updates).ID)
+	assert.Equal(t, float64(2), testutil.ToFloat64(metr.webhookInboxDepth))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metr.webhookInboxDuplicates))
+
+	in.done(10)
+	in.done(999) // not from the inbox
+	assert.Equal(t, float64(1), testutil.ToFloat64(metr.webhookInboxDepth))
+	close(stop)
+
+	// Update 11 is not handled before restart, it is passed to the bot again
+	restarted := newWebhookInbox(store, noopLogger{}, nil)
+	require.NoError(t, restarted.restore(ctx))
+	updates = make(chan tele.Update, 10)
+	stop = make(chan struct{})
+	defer close(stop)
+	go restarted.run(updates, stop)
+
+	upd := <-updates
+	assert.Equal(t, 11, upd.ID)
+	require.NotNil(t, upd.Message)
+	assert.Equal(t, "hi", upd.Message.Text)
+
+	restarted.done(11)
+	pending, err := store.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestWebhookInboxHandleWebhook(t *testing.T) {
+	store, err := NewFileInboxStore(t.TempDir())
+	require.NoError(t, err)
+
+	updates := make(chan tele.Update, 10)
+	wp := &webhookPoller{
+		cfg:     WebhookConfig{},
+		updates: updates,
+		log:     &testLogger{},
+		inbox:   newWebhookInbox(store, noopLogger{}, nil),
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go wp.inbox.run(updates, stop)
+
+	post := func(body []byte) int {
+		w := httptest.NewRecorder()
+		wp.handleWebhook(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(string(body))))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, post(inboxUpdateJSON(t, 5, "first")))
+	assert.Equal(t, http.StatusOK, post(inboxUpdateJSON(t, 5, "first"))) // Telegram retry
+	assert.Equal(t, http.StatusBadRequest, post([]byte("not json")))
+
+	assert.Equal(t, 5, (<-updates).ID)
+	select {
+	case upd := <-updates:
+		t.Fatalf("duplicate update %d is passed to the bot", upd.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pending, err := store.Pending(context.Background())
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 5, pending[0].ID)
+}
+
+func TestInboxMarksHandledUpdates(t *testing.T) {
+	store, err := NewFileInboxStore(t.TempDir())
+	require.NoError(t, err)
+	in := newWebhookInbox(store, noopLogger{}, nil)
+
+	ctx := context.Background()
+	for _, id := range []int{1, 2} {
+		_, err := in.put(ctx, tele.Update{ID: id}, inboxUpdateJSON(t, id, "hi"))
+		require.NoError(t, err)
+	}
+
+	b := &baseBot{log: noopLogger{}, inbox: in, middlewares: map[tele.ChatType][]func(upd *tele.Update) bool{
+		tele.ChatPrivate: {func(upd *tele.Update) bool { return upd.ID != 2 }},
+	}}
+
+	// Rejected by middlewares
+	upd := tele.Update{ID: 2, Message: &tele.Message{Chat: &tele.Chat{ID: 42, Type: tele.ChatPrivate}}}
+	assert.False(t, b.asyncMiddleware(&upd))
+
+	pending, err := store.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].ID)
+}
+
+// TestInboxRestoredNotRecorded verifies updates restored after restart are not recorded twice.
+func TestInboxRestoredNotRecorded(t *testing.T) {
+	store, err := NewFileInboxStore(t.TempDir())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = store.Put(ctx, InboxUpdate{ID: 1, Data: inboxUpdateJSON(t, 1, "before restart"), Received: time.Now()})
+	require.NoError(t, err)
+
+	in := newWebhookInbox(store, noopLogger{}, nil)
+	require.NoError(t, in.restore(ctx))
+
+	path := filepath.Join(t.TempDir(), "updates.jsonl")
+	b := &baseBot{log: noopLogger{}, priv: PrivacyModeLow, inbox: in}
+	rec, err := newRecorder(b, RecordConfig{Path: path}, nil)
+	require.NoError(t, err)
+
+	filter := rec.middleware(func(*tele.Update) bool { return true })
+	filter(recordedMessage(1, 42, "before restart"))
+	filter(recordedMessage(2, 42, "after restart"))
+	require.NoError(t, rec.close())
+
+	recs, err := ReadRecording(path)
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.Equal(t, 2, recs[0].Update.ID)
+
+	in.done(1)
+	assert.False(t, in.isRestored(1))
+}
@@ -4,7 +4,6 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"reflect"
-	"strconv"
 	"strings"
 	"unicode/utf8"
 
@@ -46,30 +45,30 @@ var (
 // Use this interface to provide [Context] to handlers without other methods.
 type ButtonBuilder interface {
 	Btn(name string, callback HandlerFunc, dataList ...string) tele.Btn
+	BtnID(id string, callback HandlerFunc, dataList ...string) tele.Btn
 }
 
 // Btn creates button and registers handler for it. You can provide data for the button.
 // Data items will be separated by '|' in a single data string.
+// Data that does not fit into callback data is kept in [CallbackDataStore] if it is set and truncated otherwise.
 // Button unique value is generated from hexing button name with 10 random bytes at the end.
 func (ctx *contextImpl) Btn(name string, callback HandlerFunc, dataList ...string) tele.Btn {
-	id, unique := getBtnIDAndUnique(name)
-	data := CreateBtnData(dataList...)
-	maxDataLength := MaxDataLengthBytes - len(unique) - 2
-	if len(data) > maxDataLength {
-		ctx.bt.bot.log.Warn("button data length is greater than "+strconv.Itoa(maxDataLength)+" bytes, it will be truncated",
-			"length", len(data),
-		)
-		// Truncate at rune boundary to avoid splitting multi-byte UTF-8 characters
-		runes := []rune(data)
-		for i := len(runes); i > 0; i-- {
-			if truncated := string(runes[:i]); len(truncated) <= maxDataLength {
-				data = truncated
-				break
-			}
-		}
-	}
+	return ctx.btn(name, name, callback, dataList...)
+}
+
+// BtnID creates button with a stable id and registers handler for it. The text of the button is
+// resolved with [ButtonMessages] for the language of the user, the id itself is used if there is no text.
+// Button is routed by id, so it keeps working when the text is translated or changed.
+func (ctx *contextImpl) BtnID(id string, callback HandlerFunc, dataList ...string) tele.Btn {
+	return ctx.btn(id, ctx.bt.buttonText(ctx.user.Language(), id), callback, dataList...)
+}
+
+// btn creates button routed by routeID with the provided text.
+func (ctx *contextImpl) btn(routeID, text string, callback HandlerFunc, dataList ...string) tele.Btn {
+	id, unique := getBtnIDAndUnique(routeID)
+	data := ctx.bt.buttonData(id, MaxDataLengthBytes-len(unique)-2, dataList...)
 	btn := tele.Btn{
-		Text:   name,
+		Text:   text,
 		Unique: unique,
 		Data:   data,
 	}
@@ -77,7 +76,7 @@ func (ctx *contextImpl) Btn(name string, callback HandlerFunc, dataList ...strin
 		if !ctx.user.isPublic {
 			key := ctx.buttonMapKey(id)
 
-			// The buttonMap key derives from the button name, so two same-named
+			// The buttonMap key derives from the button name (or ID), so two same-named
 			// buttons with different handlers in one message silently share one
 			// entry (last registration wins). Warn about it, but only within a
 			// single context (= one keyboard build): re-inits on later updates
@@ -85,7 +84,7 @@ func (ctx *contextImpl) Btn(name string, callback HandlerFunc, dataList ...strin
 			handlerPtr := reflect.ValueOf(callback).Pointer()
 			if prev, ok := ctx.registeredBtns[key]; ok && prev != handlerPtr {
 				ctx.bt.bot.log.Warn("button with this name is already registered in this message with a different handler; the previous handler will be overwritten",
-					"button", name,
+					"button", routeID,
 				)
 			}
 			if ctx.registeredBtns == nil {
@@ -394,6 +393,10 @@ const (
 	// idBytesInUnique is the maximum length of name in unique button value
 	idBytesInUnique = maxBytesInUnique - randBytesInUnique
 
+	// closeErrorButtonID is a stable id of the Close button under error messages,
+	// its text is [Messages.CloseBtn] and may differ between languages.
+	closeErrorButtonID = "bote_close_error"
+
 	// Max bytes in data - 64
 	// Telebots makes "\f<callback_name>|<data>"
 	// So unique + data == 60 bytes
@@ -754,3 +754,51 @@ func TestInlineBuilderZeroColumns(t *testing.T) {
 	}
 	assert.Equal(t, 3, total)
 }
+
+type buttonTestMessages struct {
+	testMessages
+	lang Language
+}
+
+func (m buttonTestMessages) ButtonText(id string) string {
+	if id == "add_task" && m.lang == LanguageRussian {
+		return "Добавить задачу"
+	}
+	if id == "add_task" {
+		return "Add task"
+	}
+	return ""
+}
+
+type buttonTestProvider struct{}
+
+func (buttonTestProvider) Messages(language Language) Messages {
+	return buttonTestMessages{lang: language}
+}
+
+// TestBtnIDStableAcrossLanguages verifies BtnID routes by id while its text is localized.
+func TestBtnIDStableAcrossLanguages(t *testing.T) {
+	bot := setupTestBot(t)
+	bot.SetMessageProvider(buttonTestProvider{})
+
+	called := 0
+	ctx := NewContext(bot, 9101, 1)
+	en := ctx.BtnID("add_task", func(Context) error { called++; return nil }, "7")
+	assert.Equal(t, "Add task", en.Text)
+
+	ctx.User().UpdateLanguage(LanguageRussian)
+	ru := ctx.BtnID("add_task", func(Context) error { called++; return nil }, "7")
+	assert.Equal(t, "Добавить задачу", ru.Text)
+
+	assert.Equal(t, getIDFromUnique(en.Unique), getIDFromUnique(ru.Unique), "route id does not depend on text")
+	assert.Equal(t, "add_task", getNameFromUnique(ru.Unique))
+
+	impl := ctx.(*contextImpl)
+	bundle, ok := impl.user.buttonMap.Lookup(impl.buttonMapKey(getIDFromUnique(en.Unique)))
+	require.True(t, ok, "button is registered by its id")
+	require.NoError(t, bundle.Handler(ctx))
+	assert.Equal(t, 1, called)
+
+	plain := ctx.BtnID("unknown", nil)
+	assert.Equal(t, "unknown", plain.Text, "id is used as text when there is no translation")
+}
@@ -0,0 +1,574 @@
+package bote
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxbolgarin/erro"
+	"github.com/maxbolgarin/lang"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	defaultManagerListen          = "0.0.0.0:8080"
+	defaultManagerShutdownTimeout = 10 * time.Second
+
+	// managerBotLabel is a label of metrics of a bot hosted by [Manager].
+	managerBotLabel = "bot"
+)
+
+var botNameRx = regexp.MustCompile(`^[A-Za-z0-9_]{1,32}$`)
+
+// ManagerOptions contains options of [Manager].
+type ManagerOptions struct {
+	// URL is a public HTTPS base URL of the shared webhook listener, e.g. "https://bots.example.com".
+	// Webhook URL of a bot is URL + path derived from the token of the bot, see [Manager.WebhookPath].
+	URL string
+
+	// Listen is the address to bind the shared webhook listener to.
+	// Default: "0.0.0.0:8080".
+	Listen string
+
+	// CertFile and KeyFile start the listener with HTTPS.
+	// If they are empty it is expected that there is a LB in front of the listener that terminates TLS.
+	CertFile string
+	KeyFile  string
+
+	// AllowedIPs contains allowed IP addresses/CIDR blocks, requests from other addresses get 403.
+	// Default: [] to allow all IPs.
+	AllowedIPs []string
+
+	// AllowTelegramIPs adds Telegram IPs to AllowedIPs.
+	AllowTelegramIPs bool
+
+	// Registry is a Prometheus registry shared by all bots, metrics of every bot have a "bot" label
+	// with the name of the bot. New registry is created if it is nil.
+	Registry *prometheus.Registry
+
+	// MetricsPath is a path to serve metrics of all bots on the listener. Metrics are not served if it is empty.
+	MetricsPath string
+
+	// UserDB is a storage of users shared by all bots, every bot gets its own namespace in it.
+	// Every bot uses in-memory storage if it is nil.
+	UserDB NamespacedUsersStorage
+
+	// JobStore, CallbackDataStore and IdempotencyStore are shared by all bots,
+	// keys of every bot are prefixed with the name of the bot. Every bot uses in-memory stores if they are nil.
+	JobStore          JobStore
+	CallbackDataStore CallbackDataStore
+	IdempotencyStore  IdempotencyStore
+
+	// Logger is a logger of the manager and bots that do not set their own.
+	Logger Logger
+}
+
+// NamespacedUsersStorage is a [UsersStorage] that can be shared by bots of a [Manager].
+// Storages from bote subpackages implement it.
+type NamespacedUsersStorage interface {
+	// Namespace returns a storage of users of the bot with the name, users of different bots do not mix.
+	Namespace(ctx context.Context, name string) (UsersStorage, error)
+}
+
+// BotSpec describes a bot hosted by a [Manager].
+type BotSpec struct {
+	// Name identifies the bot in metrics, logs and namespaces of shared storages.
+	// It can contain letters, digits and underscores, up to 32 characters.
+	Name string
+
+	// Token is a token of the bot from @BotFather.
+	Token string
+
+	// Options are applied to options of the bot. Polling mode, metrics registry and shared storages
+	// are set by the manager, other options (messages, privacy, throttling, etc.) are up to the bot.
+	Options []func(*Options)
+
+	// Setup registers handlers of the bot. It is called before the bot starts.
+	Setup func(b *Bot)
+
+	// StartHandler and StateMap are passed to [Bot.Start].
+	StartHandler HandlerFunc
+	StateMap     map[State]InitBundle
+}
+
+// Manager runs many bots in one process with shared infrastructure: a single webhook listener with
+// routing by path, a single Prometheus registry and shared storages. Bots can be added and removed
+// while the manager is running.
+//
+//	m, err := bote.NewManager(bote.ManagerOptions{URL: "https://bots.example.com", MetricsPath: "/metrics"})
+//	_, err = m.Add(bote.BotSpec{Name: "shop", Token: shopToken, Setup: setupShop, StartHandler: shopStart})
+//	stopCh, err := m.Start(ctx)
+type Manager struct {
+	opts       ManagerOptions
+	log        Logger
+	allowedIPs []*net.IPNet
+	metrics    http.Handler
+
+	// addMu serializes adding and removing bots, so metrics of a bot are never registered twice.
+	addMu sync.Mutex
+
+	mu     sync.RWMutex
+	bots   map[string]*managedBot
+	routes map[string]*managedBot
+	// ctx is canceled when the context passed to Start is done. Bots are created with contexts
+	// derived from it, so bots added before Start stop their workers and timers with the manager.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started bool
+	stopped bool
+	srv     *http.Server
+	wg      sync.WaitGroup
+}
+
+type managedBot struct {
+	bot  *Bot
+	wp   *webhookPoller
+	path string
+	spec BotSpec
+	// ctx is a context the bot is created and started with, cancel stops the bot.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// stopCh is nil until the bot is started.
+	stopCh chan struct{}
+}
+
+// NewManager creates a manager of bots. Bots are added with [Manager.Add].
+func NewManager(opts ManagerOptions) (*Manager, error) {
+	baseURL, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, erro.Wrap(err, "parse URL")
+	}
+	if baseURL.Scheme != "https" {
+		return nil, erro.New("webhook URL must use HTTPS")
+	}
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+	opts.Listen = lang.Check(opts.Listen, defaultManagerListen)
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, erro.New("both certificate and key files are required for HTTPS")
+	}
+	if opts.Registry == nil {
+		opts.Registry = prometheus.NewRegistry()
+	}
+	if opts.Logger == nil {
+		opts.Logger = noopLogger{}
+	}
+
+	allowed := opts.AllowedIPs
+	if opts.AllowTelegramIPs {
+		allowed = append(allowed, telegramIPRanges...)
+	}
+	allowedIPs, err := parseIPNets(allowed)
+	if err != nil {
+		return nil, erro.Wrap(err, "parse allowed IPs")
+	}
+
+	m := &Manager{
+		opts:       opts,
+		log:        opts.Logger,
+		allowedIPs: allowedIPs,
+		bots:       make(map[string]*managedBot),
+		routes:     make(map[string]*managedBot),
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	if opts.MetricsPath != "" {
+		m.metrics = promhttp.HandlerFor(opts.Registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+	}
+
+	return m, nil
+}
+
+// Add creates a bot by the spec. If the manager is running, the bot starts immediately,
+// otherwise it starts with [Manager.Start].
+func (m *Manager) Add(spec BotSpec) (*Bot, error) {
+	if !botNameRx.MatchString(spec.Name) {
+		return nil, erro.New("invalid bot name", "name", spec.Name)
+	}
+	if spec.Token == "" {
+		return nil, erro.New("token cannot be empty", "name", spec.Name)
+	}
+
+	m.addMu.Lock()
+	defer m.addMu.Unlock()
+
+	m.mu.RLock()
+	_, exists := m.bots[spec.Name]
+	stopped := m.stopped
+	m.mu.RUnlock()
+	if exists {
+		return nil, erro.New("bot already exists", "name", spec.Name)
+	}
+	if stopped {
+		return nil, erro.New("manager is stopped")
+	}
+
+	mb, err := m.newBot(spec)
+	if err != nil {
+		return nil, erro.Wrap(err, "new bot", "name", spec.Name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.routes[mb.path]; ok {
+		mb.cancel()
+		mb.bot.bot.metr.unregister()
+		return nil, erro.New("bot with the same token already exists", "name", spec.Name)
+	}
+	m.bots[spec.Name] = mb
+	m.routes[mb.path] = mb
+	if m.started {
+		m.startBot(mb)
+	}
+
+	m.log.Info("bot is added to manager", "bot", spec.Name)
+
+	return mb.bot, nil
+}
+
+// Remove stops the bot, deletes its webhook and removes it from the manager.
+// Data of the bot in shared storages is kept.
+func (m *Manager) Remove(name string) error {
+	m.addMu.Lock()
+	defer m.addMu.Unlock()
+
+	m.mu.Lock()
+	mb, ok := m.bots[name]
+	if ok {
+		delete(m.bots, name)
+		delete(m.routes, mb.path)
+	}
+	m.mu.Unlock()
+	if !ok {
+		return erro.New("bot not found", "name", name)
+	}
+
+	mb.cancel()
+	if mb.stopCh != nil {
+		<-mb.stopCh
+	}
+	mb.bot.bot.metr.unregister()
+
+	m.log.Info("bot is removed from manager", "bot", name)
+
+	return nil
+}
+
+// Bot returns a bot by name.
+func (m *Manager) Bot(name string) (*Bot, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mb, ok := m.bots[name]
+	if !ok {
+		return nil, false
+	}
+	return mb.bot, true
+}
+
+// Bots returns names of all bots.
+func (m *Manager) Bots() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.bots))
+	for name := range m.bots {
+		names = append(names, name)
+	}
+	return names
+}
+
+// WebhookPath returns a path of the webhook of the bot with the token on the shared listener.
+// It is derived from the token, so the path is stable and does not reveal the token.
+func (m *Manager) WebhookPath(token string) string {
+	sum := sha256.Sum256([]byte("bote-webhook\x00" + token))
+	return "/" + hex.EncodeToString(sum[:16])
+}
+
+// Start starts the shared webhook listener and all bots. When the context is done, bots are stopped,
+// their webhooks are deleted and the listener is shut down, then the returned channel is closed.
+func (m *Manager) Start(ctx context.Context) (chan struct{}, error) {
+	m.mu.Lock()
+	if m.started || m.stopped {
+		m.mu.Unlock()
+		return nil, erro.New("manager is already started")
+	}
+	listener, err := net.Listen("tcp", m.opts.Listen)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, erro.Wrap(err, "listen", "address", m.opts.Listen)
+	}
+	m.started = true
+	context.AfterFunc(ctx, m.cancel)
+	m.srv = &http.Server{
+		Handler:           m,
+		ReadHeaderTimeout: defaultWebhookReadTimeout,
+		IdleTimeout:       defaultWebhookIdleTimeout,
+	}
+	for _, mb := range m.bots {
+		m.startBot(mb)
+	}
+	m.mu.Unlock()
+
+	lang.Go(m.log, func() {
+		var err error
+		if m.opts.CertFile != "" {
+			err = m.srv.ServeTLS(listener, m.opts.CertFile, m.opts.KeyFile)
+		} else {
+			err = m.srv.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			m.log.Error("manager listener stopped", "error", err.Error(), "listen", m.opts.Listen)
+		}
+	})
+
+	m.log.Info("manager is started", "listen", m.opts.Listen, "url", m.opts.URL)
+
+	stopCh := make(chan struct{})
+	lang.Go(m.log, func() {
+		<-ctx.Done()
+
+		m.mu.Lock()
+		m.stopped = true
+		m.mu.Unlock()
+
+		// Bots are stopped by the context, wait for deletion of their webhooks before the listener goes away.
+		m.wg.Wait()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), defaultManagerShutdownTimeout)
+		defer cancel()
+		if err := m.srv.Shutdown(shutdownCtx); err != nil {
+			m.log.Error("failed to shutdown manager listener", "error", err.Error())
+		}
+
+		m.log.Info("manager is stopped")
+		close(stopCh)
+	})
+
+	return stopCh, nil
+}
+
+// ServeHTTP routes webhook requests to bots by path and serves metrics.
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !ipAllowed(r, m.allowedIPs) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if m.metrics != nil && r.URL.Path == m.opts.MetricsPath {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		m.metrics.ServeHTTP(w, r)
+		return
+	}
+
+	m.mu.RLock()
+	mb, ok := m.routes[r.URL.Path]
+	m.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	mb.wp.ServeHTTP(w, r)
+}
+
+// newBot creates a bot with the webhook on the shared listener, shared metrics and storages.
+// The bot is created with a context derived from the context of the manager.
+func (m *Manager) newBot(spec BotSpec) (mb *managedBot, err error) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer func() {
+		if err != nil {
+			cancel()
+		}
+	}()
+
+	var opts Options
+	for _, f := range spec.Options {
+		f(&opts)
+	}
+
+	path := m.WebhookPath(spec.Token)
+	secret := opts.Config.Webhook.Security.SecretToken
+	if secret == "" {
+		tokenBytes := make([]byte, 16)
+		if _, err := rand.Read(tokenBytes); err != nil {
+			return nil, erro.Wrap(err, "generate webhook secret token")
+		}
+		secret = hex.EncodeToString(tokenBytes)
+	}
+
+	webhookCfg := opts.Config.Webhook
+	webhookCfg.URL = m.opts.URL + path
+	webhookCfg.Listen = m.opts.Listen
+	webhookCfg.Security.SecretToken = secret
+	if webhookCfg.MaxConnections < 1 || webhookCfg.MaxConnections > 100 {
+		webhookCfg.MaxConnections = defaultWebhookMaxConnections
+	}
+
+	logger := lang.If(opts.Logger != nil, opts.Logger, m.log)
+	wp := &webhookPoller{
+		cfg:    webhookCfg,
+		log:    logger,
+		stopCh: make(chan struct{}),
+	}
+
+	opts.Config.Mode = PollingModeCustom
+	opts.Poller = wp
+	opts.Logger = logger
+
+	labels := prometheus.Labels{managerBotLabel: spec.Name}
+	for k, v := range opts.Metrics.ConstLabels {
+		labels[k] = v
+	}
+	opts.Metrics.Registry = m.opts.Registry
+	opts.Metrics.ConstLabels = labels
+
+	if m.opts.UserDB != nil {
+		users, err := m.opts.UserDB.Namespace(ctx, spec.Name)
+		if err != nil {
+			return nil, erro.Wrap(err, "namespace user storage")
+		}
+		opts.UserDB = users
+	}
+	prefix := spec.Name + ":"
+	if m.opts.JobStore != nil {
+		opts.JobStore = &prefixedJobStore{store: m.opts.JobStore, prefix: prefix}
+	}
+	if m.opts.CallbackDataStore != nil {
+		opts.CallbackDataStore = &prefixedCallbackDataStore{store: m.opts.CallbackDataStore, prefix: prefix}
+	}
+	if m.opts.IdempotencyStore != nil {
+		opts.IdempotencyStore = &prefixedIdempotencyStore{store: m.opts.IdempotencyStore, prefix: prefix}
+	}
+
+	b, err := NewWithOptions(ctx, spec.Token, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Metrics of the bot are created in NewWithOptions, the poller counts requests and errors in them.
+	wp.metrics = b.bot.metr
+
+	if spec.Setup != nil {
+		spec.Setup(b)
+	}
+
+	return &managedBot{bot: b, wp: wp, path: path, spec: spec, ctx: ctx, cancel: cancel}, nil
+}
+
+// startBot starts the bot with the context it was created with.
+// It should be called with the lock held.
+func (m *Manager) startBot(mb *managedBot) {
+	mb.stopCh = mb.bot.Start(mb.ctx, mb.spec.StartHandler, mb.spec.StateMap)
+
+	m.wg.Add(1)
+	lang.Go(m.log, func() {
+		defer m.wg.Done()
+		<-mb.stopCh
+		mb.cancel()
+	})
+}
+
+func parseIPNets(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			s += lang.If(strings.Contains(s, ":"), "/128", "/32")
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, erro.Wrap(err, "parse CIDR", "value", s)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ipAllowed reports whether the remote address of the request is in the list, an empty list allows all.
+func ipAllowed(r *http.Request, nets []*net.IPNet) bool {
+	if len(nets) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixedJobStore keeps jobs of a bot in a shared [JobStore] under keys with the prefix.
+type prefixedJobStore struct {
+	store  JobStore
+	prefix string
+}
+
+func (s *prefixedJobStore) Save(ctx context.Context, job ScheduledJob) error {
+	job.Key = s.prefix + job.Key
+	return s.store.Save(ctx, job)
+}
+
+func (s *prefixedJobStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, s.prefix+key)
+}
+
+func (s *prefixedJobStore) List(ctx context.Context) ([]ScheduledJob, error) {
+	all, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]ScheduledJob, 0, len(all))
+	for _, job := range all {
+		if key, ok := strings.CutPrefix(job.Key, s.prefix); ok {
+			job.Key = key
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// prefixedCallbackDataStore keeps payloads of a bot in a shared [CallbackDataStore] under tokens with the prefix.
+type prefixedCallbackDataStore struct {
+	store  CallbackDataStore
+	prefix string
+}
+
+func (s *prefixedCallbackDataStore) Save(ctx context.Context, token, data string, expiresAt time.Time) error {
+	return s.store.Save(ctx, s.prefix+token, data, expiresAt)
+}
+
+func (s *prefixedCallbackDataStore) Load(ctx context.Context, token string) (string, bool, error) {
+	return s.store.Load(ctx, s.prefix+token)
+}
+
+// prefixedIdempotencyStore keeps records of a bot in a shared [IdempotencyStore] under keys with the prefix.
+type prefixedIdempotencyStore struct {
+	store  IdempotencyStore
+	prefix string
+}
+
+func (s *prefixedIdempotencyStore) Reserve(ctx context.Context, key string, expiresAt time.Time) (bool, error) {
+	return s.store.Reserve(ctx, s.prefix+key, expiresAt)
+}
+
+func (s *prefixedIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.store.Release(ctx, s.prefix+key)
+}
@@ -0,0 +1,190 @@
+package bote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxbolgarin/lang"
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T, opts ManagerOptions) *Manager {
+	opts.URL = lang.Check(opts.URL, "https://bots.example.com")
+	m, err := NewManager(opts)
+	require.NoError(t, err)
+	return m
+}
+
+func addTestBot(t *testing.T, m *Manager, name, token string) *managedBot {
+	_, err := m.Add(BotSpec{Name: name, Token: token, Options: []func(*Options){
+		WithOffline(),
+		WithLogger(noopLogger{}),
+	}})
+	require.NoError(t, err)
+
+	mb := m.bots[name]
+	mb.wp.updates = make(chan tele.Update, 1)
+	return mb
+}
+
+func webhookRequest(path, secret string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"update_id": 7}`))
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+	return req
+}
+
+// TestManagerStartTwice verifies a started manager is rejected before it listens on the address again.
+func TestManagerStartTwice(t *testing.T) {
+	m := newTestManager(t, ManagerOptions{Listen: "invalid address"})
+	m.started = true
+
+	_, err := m.Start(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already started")
+}
+
+// TestManagerRouting verifies updates are routed to bots by path and checked by the secret of the bot.
+func TestManagerRouting(t *testing.T) {
+	m := newTestManager(t, ManagerOptions{})
+	shop := addTestBot(t, m, "shop", "1:shop")
+	support := addTestBot(t, m, "support", "2:support")
+
+	assert.NotEqual(t, shop.path, support.path)
+	assert.Equal(t, "https://bots.example.com"+shop.path, shop.wp.cfg.URL)
+	assert.NotContains(t, shop.path, "shop", "path does not reveal the token")
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, webhookRequest(shop.path, shop.wp.cfg.Security.SecretToken))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 7, (<-shop.wp.updates).ID)
+	assert.Empty(t, support.wp.updates)
+
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, webhookRequest(support.path, shop.wp.cfg.Security.SecretToken))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "secret of another bot is rejected")
+
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, webhookRequest("/unknown", ""))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	_, err := m.Add(BotSpec{Name: "shop", Token: "3:other", Options: []func(*Options){WithOffline()}})
+	assert.Error(t, err, "names are unique")
+	_, err = m.Add(BotSpec{Name: "copy", Token: "1:shop", Options: []func(*Options){WithOffline()}})
+	assert.Error(t, err, "tokens are unique")
+	_, err = m.Add(BotSpec{Name: "bad/name", Token: "4:bad"})
+	assert.Error(t, err)
+}
+
+// TestManagerMetrics verifies bots share the registry with a bot label and can be added again after removal.
+func TestManagerMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := newTestManager(t, ManagerOptions{Registry: registry, MetricsPath: "/metrics"})
+	shop := addTestBot(t, m, "shop", "1:shop")
+	addTestBot(t, m, "support", "2:support")
+
+	shop.bot.bot.metr.incUpdate()
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	bots := map[string]bool{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == managerBotLabel {
+					bots[label.GetValue()] = true
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]bool{"shop": true, "support": true}, bots)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `bot="shop"`)
+
+	require.NoError(t, m.Remove("shop"))
+	assert.Error(t, m.Remove("shop"))
+	_, ok := m.Bot("shop")
+	assert.False(t, ok)
+
+	assert.NotPanics(t, func() { addTestBot(t, m, "shop", "1:shop") })
+	assert.ElementsMatch(t, []string{"shop", "support"}, m.Bots())
+}
+
+// TestManagerAllowedIPs verifies requests from addresses out of the list are rejected.
+func TestManagerAllowedIPs(t *testing.T) {
+	m := newTestManager(t, ManagerOptions{AllowedIPs: []string{"10.0.0.0/8", "192.168.1.1"}})
+	shop := addTestBot(t, m, "shop", "1:shop")
+
+	req := webhookRequest(shop.path, shop.wp.cfg.Security.SecretToken)
+	req.RemoteAddr = "203.0.113.5:1234"
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	req = webhookRequest(shop.path, shop.wp.cfg.Security.SecretToken)
+	req.RemoteAddr = "10.1.2.3:1234"
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	_, err := NewManager(ManagerOptions{URL: "https://bots.example.com", AllowedIPs: []string{"not an ip"}})
+	assert.Error(t, err)
+	_, err = NewManager(ManagerOptions{URL: "http://bots.example.com"})
+	assert.Error(t, err)
+}
+
+// TestManagerSharedStores verifies keys of bots in shared stores do not mix.
+func TestManagerSharedStores(t *testing.T) {
+	ctx := context.Background()
+	jobs := newInMemoryJobStore()
+	shop := &prefixedJobStore{store: jobs, prefix: "shop:"}
+	support := &prefixedJobStore{store: jobs, prefix: "support:"}
+
+	require.NoError(t, shop.Save(ctx, ScheduledJob{Key: "digest", At: time.Now()}))
+	require.NoError(t, support.Save(ctx, ScheduledJob{Key: "digest", At: time.Now()}))
+	require.NoError(t, support.Delete(ctx, "digest"))
+
+	list, err := shop.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "digest", list[0].Key)
+	list, err = support.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	idem := NewMemoryIdempotencyStore()
+	expires := time.Now().Add(time.Hour)
+	ok, err := (&prefixedIdempotencyStore{store: idem, prefix: "shop:"}).Reserve(ctx, "tap", expires)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = (&prefixedIdempotencyStore{store: idem, prefix: "support:"}).Reserve(ctx, "tap", expires)
+	require.NoError(t, err)
+	assert.True(t, ok, "the same key of another bot is not reserved")
+}
+
+// TestManagerBotContext verifies bots added before Start are stopped with the manager or on removal.
+func TestManagerBotContext(t *testing.T) {
+	m := newTestManager(t, ManagerOptions{})
+	shop := addTestBot(t, m, "shop", "1:shop")
+	support := addTestBot(t, m, "support", "2:support")
+	require.NoError(t, shop.ctx.Err())
+	require.NoError(t, support.ctx.Err())
+
+	require.NoError(t, m.Remove("support"))
+	assert.ErrorIs(t, support.ctx.Err(), context.Canceled, "removed bot is stopped before start")
+	assert.NoError(t, shop.ctx.Err())
+
+	// Start cancels the context of the manager when its context is done
+	m.cancel()
+	assert.ErrorIs(t, shop.ctx.Err(), context.Canceled)
+	assert.ErrorIs(t, shop.bot.baseContext().Err(), context.Canceled, "handlers of the bot get the same context")
+}
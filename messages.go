@@ -31,6 +31,54 @@ type Messages interface {
 	PrepareMessage(msg string, u User, newState State, msgID int, isHistorical bool) string
 }
 
+// WizardMessages is an optional extension of [Messages] with button texts of a [Wizard].
+// Default messages implement it; if your [Messages] do not, English texts are used.
+type WizardMessages interface {
+	// WizardBackBtn is a text of the button that returns to the previous step of a wizard.
+	// Remain it empty if you don't want to show this button.
+	WizardBackBtn() string
+
+	// WizardCancelBtn is a text of the button that cancels a wizard.
+	// Remain it empty if you don't want to show this button.
+	WizardCancelBtn() string
+}
+
+// TimeoutMessages is an optional extension of [Messages] with a notification that is sent when a handler
+// runs longer than its deadline, see [BotConfig.HandlerTimeout].
+// Default messages implement it; if your [Messages] do not, English text is used.
+type TimeoutMessages interface {
+	// HandlerTimeout is a text of the notification about a handler that is taking too long.
+	// Remain it empty if you don't want to send this notification.
+	HandlerTimeout() string
+}
+
+// BusyMessages is an optional extension of [Messages] with a message that is sent when the bot
+// is overloaded and an update is shed, see [PoolConfig.Overload].
+// Default messages implement it; if your [Messages] do not, English text is used.
+type BusyMessages interface {
+	// Busy is a text of the message about the overloaded bot.
+	// Remain it empty if you don't want to send this message.
+	Busy() string
+}
+
+// ButtonMessages is an optional extension of [Messages] with texts of buttons created with
+// [Context.BtnID] and [Bot.NewButtonID]. If your [Messages] do not implement it or return
+// an empty text, the button id is used as its text.
+type ButtonMessages interface {
+	// ButtonText returns a text of the button with the id.
+	ButtonText(id string) string
+}
+
+// buttonText returns a localized text of the button with the id.
+func (b *Bot) buttonText(language Language, id string) string {
+	if msgs, ok := b.msgs.Messages(language).(ButtonMessages); ok {
+		if text := msgs.ButtonText(id); text != "" {
+			return text
+		}
+	}
+	return id
+}
+
 // Format is a type of message formatting in Telegram in HTML format.
 type Format string
 
@@ -518,6 +566,22 @@ func (ruMessages) PrepareMessage(msg string, _ User, _ State, _ int, _ bool) str
 	return msg
 }
 
+func (ruMessages) WizardBackBtn() string {
+	return "« Назад"
+}
+
+func (ruMessages) WizardCancelBtn() string {
+	return "Отмена"
+}
+
+func (ruMessages) HandlerTimeout() string {
+	return "Запрос выполняется слишком долго, попробуйте позже"
+}
+
+func (ruMessages) Busy() string {
+	return "Бот перегружен, попробуйте через минуту"
+}
+
 type enMessages struct{}
 
 func (enMessages) CloseBtn() string {
@@ -532,6 +596,22 @@ func (enMessages) PrepareMessage(msg string, _ User, _ State, _ int, _ bool) str
 	return msg
 }
 
+func (enMessages) WizardBackBtn() string {
+	return "« Back"
+}
+
+func (enMessages) WizardCancelBtn() string {
+	return "Cancel"
+}
+
+func (enMessages) HandlerTimeout() string {
+	return "The request is taking too long, please try again later"
+}
+
+func (enMessages) Busy() string {
+	return "The bot is busy, please try again in a minute"
+}
+
 // Case-insensitive regex pattern to detect and remove malicious URI schemes.
 // Matches javascript:, data:, vbscript:, blob:, file: with optional whitespace before the colon.
 var maliciousPattern = regexp.MustCompile(`(?i)(?:javascript|data|vbscript|blob|file)\s*:`)
@@ -23,6 +23,8 @@ const (
 	MetricsErrorInvalidUserState = "invalid_user_state" // Invalid user state error
 	MetricsErrorBadUsage         = "bad_usage"          // Package usage error
 	MetricsErrorConnectionError  = "connection_error"   // Connection error
+	MetricsErrorForgedCallback   = "forged_callback"    // Callback data with invalid signature
+	MetricsErrorTimeout          = "timeout"            // Handler deadline exceeded
 
 	// Error severity levels
 	MetricsErrorSeverityLow  = "low"  // Low severity error
@@ -59,11 +61,26 @@ type metrics struct {
 	stateRequestsTotal *prometheus.CounterVec // Total requests per state (labeled by state)
 	handlerDurationMs  prometheus.Histogram   // All handlers execution duration
 
+	// Handler worker pool metrics
+	handlerQueueDepth       *prometheus.GaugeVec     // Number of updates waiting for a worker (labeled by lane)
+	handlerQueueWaitSeconds *prometheus.HistogramVec // Time an update waits for a worker (labeled by lane)
+	handlerQueueShedTotal   *prometheus.CounterVec   // Updates shed because a lane is full (labeled by lane and policy)
+
 	// Message operation metrics
 	sendMessagesTotal   prometheus.Counter // Total messages sent
 	editMessagesTotal   prometheus.Counter // Total messages edited
 	deleteMessagesTotal prometheus.Counter // Total messages deleted
 
+	// Outgoing requests throttling metrics
+	outgoingQueued         prometheus.Gauge       // Number of outgoing requests waiting for the limiter
+	outgoingThrottledTotal *prometheus.CounterVec // Outgoing requests delayed or dropped by the limiter
+	outgoingRetriedTotal   *prometheus.CounterVec // Outgoing requests retried after flood errors
+
+	// Per-user queues metrics
+	userQueueDepth        prometheus.Gauge     // Number of updates waiting in per-user queues
+	userQueueWaitSeconds  prometheus.Histogram // Time an update waits in a per-user queue
+	userQueueDroppedTotal prometheus.Counter   // Updates dropped because a per-user queue is full
+
 	// Error tracking metrics
 	errorsTotal *prometheus.CounterVec // Total errors by type and severity
 
@@ -79,6 +96,13 @@ type metrics struct {
 	webhookErrorsTotal         *prometheus.CounterVec   // Total webhook errors by path and status
 	webhookResponseTimeSeconds *prometheus.HistogramVec // Webhook response time by path
 	webhookRequestsInFlight    *prometheus.GaugeVec     // Current requests in flight by path
+	webhookInboxDepth          prometheus.Gauge         // Saved webhook updates whose handlers have not returned
+	webhookInboxLagSeconds     prometheus.Gauge         // Age of the oldest pending webhook update
+	webhookInboxDuplicates     prometheus.Counter       // Webhook updates ignored as duplicates
+
+	// Broadcast metrics
+	broadcastMessagesTotal *prometheus.CounterVec // Broadcast messages by campaign and result
+	broadcastRetriesTotal  *prometheus.CounterVec // Broadcast retries after flood errors by campaign
 
 	// Internal state tracking
 	onFlyHandlersCount int64                                    // Atomic counter for active handlers
@@ -90,6 +114,8 @@ type metrics struct {
 	// request metrics; set once by newWebhookPoller before the server starts.
 	webhookMetricsPath string
 
+	collectors []prometheus.Collector // Registered collectors, they are unregistered when a bot leaves a Manager
+
 	disabled bool // Whether metrics collection is disabled
 }
 
@@ -121,11 +147,26 @@ func newMetrics(config MetricsConfig) *metrics {
 	m.stateRequestsTotal = m.newCounter("state_requests_total", "Total number of requests for provided state", "state")
 	m.handlerDurationMs = m.newSimpleHistogram("handler_duration_seconds", "All handlers execution duration in seconds", BotHandlerDurationBuckets)
 
+	// Initialize handler worker pool metrics
+	m.handlerQueueDepth = m.newGauge("handler_queue_depth", "Number of updates waiting for a handler worker", "lane")
+	m.handlerQueueWaitSeconds = m.newHistogram("handler_queue_wait_seconds", "Time an update waits for a handler worker in seconds", BotHandlerDurationBuckets, "lane")
+	m.handlerQueueShedTotal = m.newCounter("handler_queue_shed_total", "Total number of updates shed because a handler queue is full", "lane", "policy")
+
 	// Initialize message operation metrics
 	m.sendMessagesTotal = m.newSimpleCounter("messages_send_total", "Total number of messages sent")
 	m.editMessagesTotal = m.newSimpleCounter("messages_edit_total", "Total number of messages edited")
 	m.deleteMessagesTotal = m.newSimpleCounter("messages_delete_total", "Total number of messages deleted")
 
+	// Initialize outgoing requests throttling metrics
+	m.outgoingQueued = m.newSimpleGauge("outgoing_queued", "Number of outgoing requests waiting for the rate limiter")
+	m.outgoingThrottledTotal = m.newCounter("outgoing_throttled_total", "Total number of outgoing requests delayed or dropped by the rate limiter", "method", "result")
+	m.outgoingRetriedTotal = m.newCounter("outgoing_retried_total", "Total number of outgoing requests retried after flood errors", "method")
+
+	// Initialize per-user queues metrics
+	m.userQueueDepth = m.newSimpleGauge("user_queue_depth", "Number of updates waiting in per-user queues")
+	m.userQueueWaitSeconds = m.newSimpleHistogram("user_queue_wait_seconds", "Time an update waits in a per-user queue in seconds", BotHandlerDurationBuckets)
+	m.userQueueDroppedTotal = m.newSimpleCounter("user_queue_dropped_total", "Total number of updates dropped because a per-user queue is full")
+
 	// Initialize error tracking metrics
 	m.errorsTotal = m.newCounter("errors_total", "Total number of errors by type and severity", "type", "severity")
 
@@ -141,6 +182,13 @@ func newMetrics(config MetricsConfig) *metrics {
 	m.webhookErrorsTotal = m.newCounter("webhook_errors_total", "Total number of webhook errors", "path", "status_code")
 	m.webhookResponseTimeSeconds = m.newHistogram("webhook_request_duration_seconds", "Webhook response time in seconds", WebhookHistogramBuckets, "path")
 	m.webhookRequestsInFlight = m.newGauge("webhook_in_flight_requests", "Number of requests on fly", "path")
+	m.webhookInboxDepth = m.newSimpleGauge("webhook_inbox_depth", "Number of saved webhook updates that are not handled yet")
+	m.webhookInboxLagSeconds = m.newSimpleGauge("webhook_inbox_lag_seconds", "Age of the oldest webhook update that is not handled yet in seconds")
+	m.webhookInboxDuplicates = m.newSimpleCounter("webhook_inbox_duplicates_total", "Total number of webhook updates ignored as duplicates")
+
+	// Initialize broadcast metrics
+	m.broadcastMessagesTotal = m.newCounter("broadcast_messages_total", "Total number of broadcast messages by campaign and result", "campaign", "result")
+	m.broadcastRetriesTotal = m.newCounter("broadcast_retries_total", "Total number of broadcast retries after flood errors", "campaign")
 
 	return m
 }
@@ -195,6 +243,33 @@ func (m *metrics) recordHandlerFinish() {
 	m.handlersInFlight.Set(float64(count))
 }
 
+// setHandlerQueueDepth sets the number of updates waiting for a worker in the lane.
+// Called when an update is put to or taken from the worker pool.
+func (m *metrics) setHandlerQueueDepth(lane string, count int) {
+	if m == nil || m.disabled {
+		return
+	}
+	m.handlerQueueDepth.WithLabelValues(lane).Set(float64(count))
+}
+
+// observeHandlerQueueWait records the time an update waited for a worker in the lane.
+// Called when a worker takes an update.
+func (m *metrics) observeHandlerQueueWait(lane string, d time.Duration) {
+	if m == nil || m.disabled {
+		return
+	}
+	m.handlerQueueWaitSeconds.WithLabelValues(lane).Observe(d.Seconds())
+}
+
+// incHandlerQueueShed increments the shed updates counter.
+// Called when an update is shed because the lane of the worker pool is full.
+func (m *metrics) incHandlerQueueShed(lane, policy string) {
+	if m == nil || m.disabled {
+		return
+	}
+	m.handlerQueueShedTotal.WithLabelValues(lane, policy).Inc()
+}
+
 // incError increments the error counter for the given error type and severity.
 // Called when an error occurs to track error rates and types.
 func (m *metrics) incError(errorType, severity string) {
@@ -231,6 +306,78 @@ func (m *metrics) incDeleteMessagesTotal() {
 	m.deleteMessagesTotal.Inc()
 }
 
+// incBroadcastMessage increments the broadcast messages counter for the given campaign and result.
+// Called once for every user processed by a broadcast.
+func (m *metrics) incBroadcastMessage(campaign, result string) {
+	if m == nil || m.disabled {
+		return
+	}
+	m.broadcastMessagesTotal.WithLabelValues(campaign, result).Inc()
+}
+
+// incBroadcastRetry increments the broadcast retries counter for the given campaign.
+// Called when a broadcast message is retried after a flood error.
+func (m *metrics) incBroadcastRetry(campaign string) {
+	if m == nil || m.disabled {
+		return
+	}
+	m.broadcastRetriesTotal.WithLabelValues(campaign).Inc()
+}
+
+// setOutgoingQueued sets the number of outgoing requests waiting for the limiter.
+// Called when a request starts and stops waiting.
+func (m *metrics) setOutgoingQueued(count int64) {
+	if m == nil || m.disabled {
+		return
+	}
+	m.outgoingQueued.Set(float64(count))
+}
+
+// incOutgoingThrottled increments the throttled requests counter for the given method and result.
+// Called when a request is delayed by the limiter or dropped because of a full queue or a timeout.
+func (m *metrics) incOutgoingThrottled(method, result string) {
+	if m == nil || m.disabled {
+		return
+	}
+	m.outgoingThrottledTotal.WithLabelValues(method, result).Inc()
+}
+
+// incOutgoingRetried increments the retried requests counter for the given method.
+// Called when a request is retried after a flood error.
+func (m *metrics) incOutgoingRetried(method string) {
+	if m == nil || m.disabled {
+		return
+	}
+	m.outgoingRetriedTotal.WithLabelValues(method).Inc()
+}
+
+// setUserQueueDepth sets the number of updates waiting in per-user queues.
+// Called when an update is put to or taken from a queue.
+func (m *metrics) setUserQueueDepth(count int) {
+	if m == nil || m.disabled {
+		return
+	}
+	m.userQueueDepth.Set(float64(count))
+}
+
+// observeUserQueueWait records the time an update waited in a per-user queue.
+// Called when an update is taken from a queue to be handled.
+func (m *metrics) observeUserQueueWait(d time.Duration) {
+	if m == nil || m.disabled {
+		return
+	}
+	m.userQueueWaitSeconds.Observe(d.Seconds())
+}
+
+// incUserQueueDropped increments the dropped updates counter.
+// Called when an update is dropped because a per-user queue is full.
+func (m *metrics) incUserQueueDropped() {
+	if m == nil || m.disabled {
+		return
+	}
+	m.userQueueDroppedTotal.Inc()
+}
+
 // addActiveUser records user activity and updates active user metrics.
 // Called when a user interacts with the bot to track user engagement.
 func (m *metrics) addActiveUser(userID int64) {
@@ -358,6 +505,25 @@ func (m *metrics) setWebhookStatus(url string, address string) {
 	m.webhookStatus.WithLabelValues(url, address).Set(1)
 }
 
+// setWebhookInbox sets the number of pending webhook updates and the age of the oldest one.
+// Called when an update is saved to or handled from the inbox.
+func (m *metrics) setWebhookInbox(depth int, lag time.Duration) {
+	if m == nil || m.disabled {
+		return
+	}
+	m.webhookInboxDepth.Set(float64(depth))
+	m.webhookInboxLagSeconds.Set(lag.Seconds())
+}
+
+// incWebhookInboxDuplicates increments the duplicate webhook updates counter.
+// Called when Telegram sends an update that is already saved or handled.
+func (m *metrics) incWebhookInboxDuplicates() {
+	if m == nil || m.disabled {
+		return
+	}
+	m.webhookInboxDuplicates.Inc()
+}
+
 // HandleRequest records webhook request metrics.
 // Called at the start of webhook request processing to track request volume and concurrency.
 func (m *metrics) HandleRequest(r *http.Request) {
@@ -394,6 +560,18 @@ func (m *metrics) HandleResponse(r *http.Request, w http.ResponseWriter, statusC
 	m.webhookRequestsInFlight.WithLabelValues(r.URL.Path).Set(float64(count))
 }
 
+// unregister removes all metrics from the registry, so a bot with the same labels can register them again.
+// Called when a bot is removed from a Manager.
+func (m *metrics) unregister() {
+	if m == nil || m.disabled {
+		return
+	}
+	for _, c := range m.collectors {
+		m.Registry.Unregister(c)
+	}
+	m.collectors = nil
+}
+
 // newCounter creates a new CounterVec with the given name, help text, and label names.
 // The counter is automatically registered with the registry.
 // Uses the configured subsystem or defaults to "bote".
@@ -409,6 +587,7 @@ func (r *metrics) newCounter(name, help string, labelNames ...string) *prometheu
 		labelNames,
 	)
 	r.Registry.MustRegister(counter)
+	r.collectors = append(r.collectors, counter)
 	return counter
 }
 
@@ -427,6 +606,7 @@ func (r *metrics) newGauge(name, help string, labelNames ...string) *prometheus.
 		labelNames,
 	)
 	r.Registry.MustRegister(gauge)
+	r.collectors = append(r.collectors, gauge)
 	return gauge
 }
 
@@ -446,6 +626,7 @@ func (r *metrics) newHistogram(name, help string, buckets []float64, labelNames
 		labelNames,
 	)
 	r.Registry.MustRegister(histogram)
+	r.collectors = append(r.collectors, histogram)
 	return histogram
 }
 
@@ -463,6 +644,7 @@ func (r *metrics) newSimpleCounter(name, help string) prometheus.Counter {
 		},
 	)
 	r.Registry.MustRegister(counter)
+	r.collectors = append(r.collectors, counter)
 	return counter
 }
 
@@ -480,6 +662,7 @@ func (r *metrics) newSimpleGauge(name, help string) prometheus.Gauge {
 		},
 	)
 	r.Registry.MustRegister(gauge)
+	r.collectors = append(r.collectors, gauge)
 	return gauge
 }
 
@@ -498,5 +681,6 @@ func (r *metrics) newSimpleHistogram(name, help string, buckets []float64) prome
 		},
 	)
 	r.Registry.MustRegister(histogram)
+	r.collectors = append(r.collectors, histogram)
 	return histogram
 }
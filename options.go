@@ -36,17 +36,39 @@ const (
 	defaultWebhookHealthPath  = "/health"
 	defaultWebhookMetricsPath = "/metrics"
 
+	defaultWebhookInboxPath     = "bote-inbox"
+	defaultWebhookInboxDedupTTL = 24 * time.Hour
+
 	defaultBotParseMode       = tele.ModeHTML
 	defaultBotDefaultLanguage = LanguageDefault
 	defaultBotDeleteMessages  = true
 	defaultUserCacheCapacity  = 10000
 	defaultUserCacheTTL       = 24 * time.Hour
+	defaultCallbackDataTTL    = 30 * 24 * time.Hour
+	defaultIdempotencyTTL     = 30 * 24 * time.Hour
+	defaultStreamInterval     = time.Second
 
 	defaultLogEnable  = true
 	defaultLogUpdates = true
 	defaultLogLevel   = "info"
 
 	defaultUpdatesChannelCapacity = 1000
+
+	defaultThrottleEnabled         = false
+	defaultThrottleGlobalPerSecond = 30
+	defaultThrottleChatPerSecond   = 1
+	defaultThrottleGroupPerMinute  = 20
+	defaultThrottleChatBurst       = 3
+	defaultThrottleMaxQueue        = 1000
+	defaultThrottleMaxWait         = 10 * time.Second
+	defaultThrottleMaxRetries      = 3
+
+	defaultSerialQueueSize  = 10
+	defaultSerialDropPolicy = SerialDropNewest
+
+	defaultPoolWorkers   = 100
+	defaultPoolQueueSize = 1000
+	defaultPoolOverload  = OverloadBusy
 )
 
 // https://core.telegram.org/bots/webhooks
@@ -127,6 +149,35 @@ type (
 		// It is used to create a bot without network for testing purposes.
 		Offline bool
 
+		// APIURL is a URL of Telegram Bot API server. It uses https://api.telegram.org by default.
+		// Set it to use a local Bot API server or a fake one in tests, see botetest package.
+		APIURL string
+
+		// Synchronous makes handlers of an update run in the goroutine of the poller instead of a goroutine
+		// per update, so updates are handled one by one, which makes handling deterministic in tests and replays.
+		// Per-user queues and the worker pool still handle updates in their own goroutines.
+		Synchronous bool
+
+		// JobStore persists scheduled jobs. It uses in-memory storage by default,
+		// so jobs are lost on restart unless you provide a persistent one.
+		JobStore JobStore
+
+		// OutgoingLimiter limits outgoing requests to Telegram Bot API.
+		// It uses token buckets from Config.Throttle if it is enabled, there are no limits by default.
+		OutgoingLimiter OutgoingLimiter
+
+		// CallbackDataStore keeps button payloads longer than Telegram callback data limit.
+		// It is disabled by default: oversized payloads are truncated.
+		CallbackDataStore CallbackDataStore
+
+		// IdempotencyStore keeps records of handled taps on buttons marked with [Idempotent].
+		// It uses in-memory storage by default, so records are lost on restart.
+		IdempotencyStore IdempotencyStore
+
+		// InboxStore persists webhook updates until their handlers return, see [WebhookInboxConfig].
+		// It enables the inbox in webhook mode, [FileInboxStore] is used if the inbox is enabled without a store.
+		InboxStore InboxStore
+
 		// KeysProvider is a provider of encryption and HMAC keys for the bot.
 		// It is used to provide encryption and HMAC keys for the bot in strict privacy mode.
 		KeysProvider KeysProvider
@@ -149,7 +200,14 @@ type (
 		//     non-blocking. Do slow work asynchronously.
 		OnStateChange StateChangeFunc
 
-		metrics *metrics
+		// OnUpdateHandled is called when handlers of an update return or the update is dropped
+		// by middlewares or queues. It is optional, botetest uses it to wait until the bot handles
+		// an update. Panics are recovered and logged. It runs inline on the update's goroutine,
+		// so it must be cheap and non-blocking.
+		OnUpdateHandled func(upd *tele.Update)
+
+		metrics            *metrics
+		callbackSigningKey *EncryptionKey
 	}
 
 	// StateChangeFunc is called on a real state transition. See [Options.OnStateChange].
@@ -187,6 +245,7 @@ const (
 	PollingModeLong    PollingMode = "long"
 	PollingModeWebhook PollingMode = "webhook"
 	PollingModeCustom  PollingMode = "custom"
+	PollingModeReplay  PollingMode = "replay"
 )
 
 const (
@@ -215,6 +274,7 @@ type Config struct {
 	// - "long" - long polling
 	// - "webhook" - webhook
 	// - "custom" - custom poller (you should provide poller using [WithPoller] option)
+	// - "replay" - replay updates recorded with [RecordConfig], see [ReplayConfig]
 	// Environment variable: BOTE_MODE.
 	Mode PollingMode `yaml:"mode" json:"mode" env:"BOTE_MODE"`
 
@@ -227,6 +287,21 @@ type Config struct {
 	// Bot contains bot configuration.
 	Bot BotConfig `yaml:"bot" json:"bot"`
 
+	// Throttle contains configuration of outgoing requests limiter.
+	Throttle ThrottleConfig `yaml:"throttle" json:"throttle"`
+
+	// Serial contains configuration of per-user serialized handling of updates.
+	Serial SerialConfig `yaml:"serial" json:"serial"`
+
+	// Pool contains configuration of the bounded pool of handler workers.
+	Pool PoolConfig `yaml:"pool" json:"pool"`
+
+	// Record contains configuration of recording incoming updates.
+	Record RecordConfig `yaml:"record" json:"record"`
+
+	// Replay contains configuration of replay mode.
+	Replay ReplayConfig `yaml:"replay" json:"replay"`
+
 	// Log contains log configuration.
 	Log LogConfig `yaml:"log" json:"log"`
 }
@@ -268,6 +343,72 @@ type BotConfig struct {
 	// Default: 24 hours.
 	// Environment variable: BOTE_USER_CACHE_TTL.
 	UserCacheTTL time.Duration `yaml:"user_cache_ttl" json:"user_cache_ttl" env:"BOTE_USER_CACHE_TTL"`
+
+	// NotificationTTL is the time after which notification messages are deleted automatically.
+	// It can be overridden for a single message with [DeleteAfter].
+	// Default: 0 (notification lives until it is replaced or closed).
+	// Environment variable: BOTE_NOTIFICATION_TTL.
+	NotificationTTL time.Duration `yaml:"notification_ttl" json:"notification_ttl" env:"BOTE_NOTIFICATION_TTL"`
+
+	// ErrorTTL is the time after which error messages are deleted automatically if the user
+	// does nothing, any user action deletes the error message anyway.
+	// It can be overridden for a single message with [DeleteAfter].
+	// Default: 0 (error message lives until the next user action).
+	// Environment variable: BOTE_ERROR_TTL.
+	ErrorTTL time.Duration `yaml:"error_ttl" json:"error_ttl" env:"BOTE_ERROR_TTL"`
+
+	// CallbackDataTTL is the time a button payload is kept in [Options.CallbackDataStore].
+	// A button with an oversized payload loses its data after this time.
+	// Default: 30 days.
+	// Environment variable: BOTE_CALLBACK_DATA_TTL.
+	CallbackDataTTL time.Duration `yaml:"callback_data_ttl" json:"callback_data_ttl" env:"BOTE_CALLBACK_DATA_TTL"`
+
+	// HandlerTimeout is the deadline of a handler of a single update. When it passes, [Context.Ctx] is canceled,
+	// the user gets [TimeoutMessages.HandlerTimeout] notification and the timeout error is counted in metrics.
+	// The handler is not interrupted, it should return on the canceled context.
+	// It can be overridden for a single handler with [Timeout].
+	// Default: 0 (no deadline).
+	// Environment variable: BOTE_HANDLER_TIMEOUT.
+	HandlerTimeout time.Duration `yaml:"handler_timeout" json:"handler_timeout" env:"BOTE_HANDLER_TIMEOUT"`
+
+	// ChatActionDelay is the time after which a chat action ("typing…" by default) is shown while a handler runs,
+	// it is repeated every 4 seconds until the bot sends or edits a message or the handler returns.
+	// The action can be changed with [Context.SetChatAction].
+	// Default: 0 (disabled).
+	// Environment variable: BOTE_CHAT_ACTION_DELAY.
+	ChatActionDelay time.Duration `yaml:"chat_action_delay" json:"chat_action_delay" env:"BOTE_CHAT_ACTION_DELAY"`
+
+	// StreamInterval is the time between drafts sent by [Context.Stream] while the text is being generated.
+	// Telegram limits the rate of requests, too short interval leads to throttled drafts.
+	// Default: 1s.
+	// Environment variable: BOTE_STREAM_INTERVAL.
+	StreamInterval time.Duration `yaml:"stream_interval" json:"stream_interval" env:"BOTE_STREAM_INTERVAL"`
+
+	// DebounceWindow is the time after a tap on a button when repeated taps on the same button
+	// with the same payload in the same message are answered without running the handler.
+	// Taps while the handler of the first one is running are dropped too.
+	// Default: 0 (disabled).
+	// Environment variable: BOTE_DEBOUNCE_WINDOW.
+	DebounceWindow time.Duration `yaml:"debounce_window" json:"debounce_window" env:"BOTE_DEBOUNCE_WINDOW"`
+
+	// IdempotencyTTL is the time a record of a handled tap on a button marked with [Idempotent] is kept.
+	// Default: 30 days.
+	// Environment variable: BOTE_IDEMPOTENCY_TTL.
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl" json:"idempotency_ttl" env:"BOTE_IDEMPOTENCY_TTL"`
+
+	// SignCallbackData enables signing button data with a truncated HMAC.
+	// Telegram clients can send any callback data, so without signing a modified client can tap
+	// a button with a payload the bot never sent. Taps with a missing or wrong signature are dropped.
+	// It takes 12 bytes of callback data, and buttons sent before enabling it stop working.
+	// Default: false.
+	// Environment variable: BOTE_SIGN_CALLBACK_DATA.
+	SignCallbackData bool `yaml:"sign_callback_data" json:"sign_callback_data" env:"BOTE_SIGN_CALLBACK_DATA"`
+
+	// CallbackSigningKey is the key for signing button data, it is required if SignCallbackData is enabled.
+	// Key should be a hex encoded string of 32 bytes. It is separate from the HMAC key of [KeysProvider],
+	// so rotating that key does not make sent buttons look forged.
+	// Environment variable: BOTE_CALLBACK_SIGNING_KEY.
+	CallbackSigningKey *string `yaml:"callback_signing_key" json:"callback_signing_key" env:"BOTE_CALLBACK_SIGNING_KEY"`
 }
 
 type PrivacyConfig struct {
@@ -392,6 +533,14 @@ type WebhookConfig struct {
 	// Environment variable: BOTE_WEBHOOK_LISTEN.
 	Listen string `yaml:"listen" json:"listen" env:"BOTE_WEBHOOK_LISTEN"`
 
+	// ExternalServer disables the built-in webhook server: updates come from [Bot.WebhookHandler]
+	// mounted under your own HTTP server. The bot still sets the webhook on start and deletes it on stop,
+	// the handler checks the secret token and allowed IPs and counts requests in metrics.
+	// Listen, rate limit, security headers, StartHTTPS and metrics serving are not used with it.
+	// Default: false.
+	// Environment variable: BOTE_WEBHOOK_EXTERNAL_SERVER.
+	ExternalServer bool `yaml:"external_server" json:"external_server" env:"BOTE_WEBHOOK_EXTERNAL_SERVER"`
+
 	// ReadTimeout is the maximum duration for reading the entire request.
 	// Default: 30 seconds.
 	// Environment variable: BOTE_WEBHOOK_READ_TIMEOUT.
@@ -429,6 +578,9 @@ type WebhookConfig struct {
 	// RateLimit contains rate limiting configuration.
 	RateLimit WebhookRateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
 
+	// Inbox contains configuration of the persistent inbox of webhook updates.
+	Inbox WebhookInboxConfig `yaml:"inbox" json:"inbox"`
+
 	// AllowedUpdates is a list of update types the bot wants to receive.
 	// Empty list means all update types.
 	// Possible values:
@@ -539,6 +691,148 @@ type WebhookRateLimitConfig struct {
 	BurstSize int `yaml:"burst_size" json:"burst_size" env:"BOTE_WEBHOOK_RATE_LIMIT_BURST"`
 }
 
+// WebhookInboxConfig contains configuration of the persistent inbox of webhook updates.
+type WebhookInboxConfig struct {
+	// Enabled enables saving of every webhook update before it is acknowledged to Telegram.
+	// An update stays in the inbox until its handlers return, so updates are not lost if the process dies:
+	// unhandled updates are handled again after restart. Updates retried by Telegram are handled once.
+	// The inbox does not limit concurrency: updates are handled in a goroutine per update as without it,
+	// use [Config.Serial] or [Config.Pool] to bound it.
+	// Default: false.
+	// Environment variable: BOTE_WEBHOOK_INBOX_ENABLED.
+	Enabled bool `yaml:"enabled" json:"enabled" env:"BOTE_WEBHOOK_INBOX_ENABLED"`
+
+	// Path is the directory of the default file store, it is not used with [Options.InboxStore].
+	// Default: "bote-inbox".
+	// Environment variable: BOTE_WEBHOOK_INBOX_PATH.
+	Path string `yaml:"path" json:"path" env:"BOTE_WEBHOOK_INBOX_PATH"`
+
+	// DedupTTL is how long handled updates are remembered to ignore their duplicates.
+	// Default: 24 hours.
+	// Environment variable: BOTE_WEBHOOK_INBOX_DEDUP_TTL.
+	DedupTTL time.Duration `yaml:"dedup_ttl" json:"dedup_ttl" env:"BOTE_WEBHOOK_INBOX_DEDUP_TTL"`
+}
+
+// ThrottleConfig contains configuration of outgoing requests limiter.
+// Telegram allows about 30 messages per second in total, 1 message per second in a private chat
+// and 20 messages per minute in a group; exceeding them leads to "Too Many Requests" errors.
+type ThrottleConfig struct {
+	// Enabled enables token buckets for outgoing requests. Flood errors are retried anyway.
+	// The per-chat bucket delays replies of several messages in a row, so it is opt-in.
+	// Default: false.
+	// Environment variable: BOTE_THROTTLE_ENABLED.
+	Enabled *bool `yaml:"enabled" json:"enabled" env:"BOTE_THROTTLE_ENABLED"`
+
+	// GlobalPerSecond is the maximum number of requests per second for all chats.
+	// Default: 30.
+	// Environment variable: BOTE_THROTTLE_GLOBAL_PER_SECOND.
+	GlobalPerSecond int `yaml:"global_per_second" json:"global_per_second" env:"BOTE_THROTTLE_GLOBAL_PER_SECOND"`
+
+	// ChatPerSecond is the maximum number of requests per second for a private chat.
+	// Default: 1.
+	// Environment variable: BOTE_THROTTLE_CHAT_PER_SECOND.
+	ChatPerSecond int `yaml:"chat_per_second" json:"chat_per_second" env:"BOTE_THROTTLE_CHAT_PER_SECOND"`
+
+	// GroupPerMinute is the maximum number of requests per minute for a group or a channel.
+	// Default: 20.
+	// Environment variable: BOTE_THROTTLE_GROUP_PER_MINUTE.
+	GroupPerMinute int `yaml:"group_per_minute" json:"group_per_minute" env:"BOTE_THROTTLE_GROUP_PER_MINUTE"`
+
+	// ChatBurst is the number of requests to a single chat that can be made at once,
+	// e.g. sending a notification and editing the main message in one handler.
+	// Default: 3.
+	// Environment variable: BOTE_THROTTLE_CHAT_BURST.
+	ChatBurst int `yaml:"chat_burst" json:"chat_burst" env:"BOTE_THROTTLE_CHAT_BURST"`
+
+	// MaxQueue is the maximum number of requests waiting for the limiter. Requests above it fail at once.
+	// Default: 1000.
+	// Environment variable: BOTE_THROTTLE_MAX_QUEUE.
+	MaxQueue int `yaml:"max_queue" json:"max_queue" env:"BOTE_THROTTLE_MAX_QUEUE"`
+
+	// MaxWait is the maximum time a request waits for the limiter or for retry_after of a flood error.
+	// Default: 10 seconds.
+	// Environment variable: BOTE_THROTTLE_MAX_WAIT.
+	MaxWait time.Duration `yaml:"max_wait" json:"max_wait" env:"BOTE_THROTTLE_MAX_WAIT"`
+
+	// MaxRetries is the maximum number of retries of a request after a flood error.
+	// Default: 3.
+	// Environment variable: BOTE_THROTTLE_MAX_RETRIES.
+	MaxRetries int `yaml:"max_retries" json:"max_retries" env:"BOTE_THROTTLE_MAX_RETRIES"`
+}
+
+type SerialConfig struct {
+	// Enabled enables handling updates of a single user strictly one after another in the order they came.
+	// Updates of different users are still handled in parallel. It prevents races in handlers of the same user,
+	// e.g. on a fast double tap or a text sent during a slow callback.
+	// Default: false.
+	// Environment variable: BOTE_SERIAL_ENABLED.
+	Enabled bool `yaml:"enabled" json:"enabled" env:"BOTE_SERIAL_ENABLED"`
+
+	// QueueSize is the maximum number of updates waiting in a queue of a single user.
+	// Default: 10.
+	// Environment variable: BOTE_SERIAL_QUEUE_SIZE.
+	QueueSize int `yaml:"queue_size" json:"queue_size" env:"BOTE_SERIAL_QUEUE_SIZE"`
+
+	// DropPolicy is the policy of dropping updates when a queue of a user is full.
+	// Default: "newest".
+	// Possible values:
+	// - "newest" - drop an incoming update
+	// - "oldest" - drop the oldest waiting update
+	// Environment variable: BOTE_SERIAL_DROP_POLICY.
+	DropPolicy SerialDropPolicy `yaml:"drop_policy" json:"drop_policy" env:"BOTE_SERIAL_DROP_POLICY"`
+}
+
+type PoolConfig struct {
+	// Enabled enables handling updates with a fixed number of workers instead of a goroutine per update.
+	// Updates wait for a worker in two lanes: button taps and commands are handled before
+	// plain text, media, group and channel messages. It protects the bot and its database from bursts.
+	// Default: false.
+	// Environment variable: BOTE_POOL_ENABLED.
+	Enabled bool `yaml:"enabled" json:"enabled" env:"BOTE_POOL_ENABLED"`
+
+	// Workers is the maximum number of concurrently running handlers.
+	// Default: 100.
+	// Environment variable: BOTE_POOL_WORKERS.
+	Workers int `yaml:"workers" json:"workers" env:"BOTE_POOL_WORKERS"`
+
+	// QueueSize is the maximum number of updates waiting for a worker in a single lane.
+	// Default: 1000.
+	// Environment variable: BOTE_POOL_QUEUE_SIZE.
+	QueueSize int `yaml:"queue_size" json:"queue_size" env:"BOTE_POOL_QUEUE_SIZE"`
+
+	// Overload is the policy of handling updates when a lane is full.
+	// Default: "busy".
+	// Possible values:
+	// - "busy" - answer with a localized "busy" message in private chats, see [BusyMessages]
+	// - "drop" - drop the update silently
+	// Environment variable: BOTE_POOL_OVERLOAD.
+	Overload OverloadPolicy `yaml:"overload" json:"overload" env:"BOTE_POOL_OVERLOAD"`
+}
+
+type RecordConfig struct {
+	// Path is the path of a JSONL file to append every incoming update to, see [RecordedUpdate].
+	// Recording is disabled if it is empty. In strict privacy mode user IDs are replaced
+	// with [RecordingUserID] and names are removed, but texts of messages are recorded as is.
+	// Default: "".
+	// Environment variable: BOTE_RECORD_PATH.
+	Path string `yaml:"path" json:"path" env:"BOTE_RECORD_PATH"`
+}
+
+type ReplayConfig struct {
+	// Path is the path of a recording to replay in "replay" mode. It is required in this mode.
+	// Updates are handled one by one in the recorded order and requests to Bot API are answered
+	// offline unless [Options.APIURL] is set, so replayed handlers never reach real users.
+	// Default: "".
+	// Environment variable: BOTE_REPLAY_PATH.
+	Path string `yaml:"path" json:"path" env:"BOTE_REPLAY_PATH"`
+
+	// UserID is the ID of a user whose updates are replayed, updates of other users are skipped.
+	// All updates are replayed if it is 0. Use the real ID for redacted recordings too.
+	// Default: 0.
+	// Environment variable: BOTE_REPLAY_USER_ID.
+	UserID int64 `yaml:"user_id" json:"user_id" env:"BOTE_REPLAY_USER_ID"`
+}
+
 // WithConfig returns an option that sets the bot configuration.
 func WithConfig(cfg Config) func(opts *Options) {
 	return func(opts *Options) {
@@ -599,6 +893,16 @@ func WithWebhook(url string, listen ...string) func(opts *Options) {
 	}
 }
 
+// WithWebhookHandler returns an option that receives webhook updates from [Bot.WebhookHandler] mounted
+// under your own HTTP server instead of the built-in one, see [WebhookConfig.ExternalServer].
+func WithWebhookHandler(url string) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Mode = PollingModeWebhook
+		opts.Config.Webhook.URL = url
+		opts.Config.Webhook.ExternalServer = true
+	}
+}
+
 // WithWebhookServer returns an option that sets the webhook server listen address.
 func WithWebhookServer(listen string, isHTTPS bool) func(opts *Options) {
 	return func(opts *Options) {
@@ -697,6 +1001,49 @@ func WithCustomPoller(poller tele.Poller) func(opts *Options) {
 	}
 }
 
+// WithAPIURL returns an option that sets a URL of Telegram Bot API server, see [Options.APIURL].
+func WithAPIURL(url string) func(opts *Options) {
+	return func(opts *Options) {
+		opts.APIURL = url
+	}
+}
+
+// WithSynchronous returns an option that runs handlers of updates one by one in the goroutine of the poller,
+// see [Options.Synchronous].
+func WithSynchronous() func(opts *Options) {
+	return func(opts *Options) {
+		opts.Synchronous = true
+	}
+}
+
+// WithRecorder returns an option that appends every incoming update to the JSONL file, see [RecordConfig].
+func WithRecorder(path string) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Record.Path = path
+	}
+}
+
+// WithReplay returns an option that replays updates from the recording instead of polling Telegram.
+// If userID is provided, only updates of this user are replayed, see [ReplayConfig].
+func WithReplay(path string, userID ...int64) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Mode = PollingModeReplay
+		opts.Config.Replay.Path = path
+		opts.Config.Replay.UserID = lang.First(userID)
+	}
+}
+
+// WithWebhookInbox returns an option that saves webhook updates until their handlers return,
+// see [WebhookInboxConfig]. It uses [FileInboxStore] if store is not provided.
+func WithWebhookInbox(store ...InboxStore) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Webhook.Inbox.Enabled = true
+		if len(store) > 0 {
+			opts.InboxStore = store[0]
+		}
+	}
+}
+
 // WithBotConfig returns an option that sets the bot configuration.
 func WithBotConfig(cfg BotConfig) func(opts *Options) {
 	return func(opts *Options) {
@@ -711,6 +1058,15 @@ func WithDefaultLanguage(lang Language) func(opts *Options) {
 	}
 }
 
+// WithMessagesTTL returns an option that sets the default time after which notification and error
+// messages are deleted automatically. Zero disables deletion for the message type.
+func WithMessagesTTL(notificationTTL, errorTTL time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Bot.NotificationTTL = notificationTTL
+		opts.Config.Bot.ErrorTTL = errorTTL
+	}
+}
+
 // WithLowPrivacyMode returns an option that sets the low privacy mode.
 func WithLowPrivacyMode() func(opts *Options) {
 	return func(opts *Options) {
@@ -737,6 +1093,117 @@ func WithStrictPrivacyModeKeyProvider(keysProvider KeysProvider) func(opts *Opti
 	}
 }
 
+// WithJobStore returns an option that sets the storage of scheduled jobs.
+func WithJobStore(store JobStore) func(opts *Options) {
+	return func(opts *Options) {
+		opts.JobStore = store
+	}
+}
+
+// WithCallbackDataStore returns an option that enables keeping button payloads longer than
+// Telegram callback data limit in the store. Use [NewMemoryCallbackDataStore] or a persistent store.
+func WithCallbackDataStore(store CallbackDataStore, ttl ...time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.CallbackDataStore = store
+		opts.Config.Bot.CallbackDataTTL = lang.First(ttl)
+	}
+}
+
+// WithHandlerTimeout returns an option that sets the deadline of handlers, see [BotConfig.HandlerTimeout].
+func WithHandlerTimeout(timeout time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Bot.HandlerTimeout = timeout
+	}
+}
+
+// WithChatAction returns an option that shows "typing…" for handlers that run longer than the delay,
+// see [BotConfig.ChatActionDelay].
+func WithChatAction(delay time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Bot.ChatActionDelay = delay
+	}
+}
+
+// WithStreamInterval returns an option that sets the time between drafts of [Context.Stream],
+// see [BotConfig.StreamInterval].
+func WithStreamInterval(interval time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Bot.StreamInterval = interval
+	}
+}
+
+// WithDebounce returns an option that drops repeated taps on the same button within the window
+// and while the handler of the first tap is running.
+func WithDebounce(window time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Bot.DebounceWindow = window
+	}
+}
+
+// WithIdempotencyStore returns an option that sets a store of records of handled taps on buttons
+// marked with [Idempotent]. Use a persistent store to keep records after a restart.
+func WithIdempotencyStore(store IdempotencyStore, ttl ...time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.IdempotencyStore = store
+		opts.Config.Bot.IdempotencyTTL = lang.First(ttl)
+	}
+}
+
+// WithCallbackSigning returns an option that enables signing button data, so forged button taps
+// are dropped. The key is a hex encoded string of 32 bytes, changing it makes sent buttons stop working.
+func WithCallbackSigning(key string) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Bot.SignCallbackData = true
+		opts.Config.Bot.CallbackSigningKey = &key
+	}
+}
+
+// WithOutgoingLimiter returns an option that sets a custom limiter of outgoing requests.
+func WithOutgoingLimiter(limiter OutgoingLimiter) func(opts *Options) {
+	return func(opts *Options) {
+		opts.OutgoingLimiter = limiter
+	}
+}
+
+// WithThrottle returns an option that enables limits of outgoing requests, see [ThrottleConfig].
+func WithThrottle(globalPerSecond, chatPerSecond, groupPerMinute int) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Throttle.Enabled = lang.Ptr(true)
+		opts.Config.Throttle.GlobalPerSecond = globalPerSecond
+		opts.Config.Throttle.ChatPerSecond = chatPerSecond
+		opts.Config.Throttle.GroupPerMinute = groupPerMinute
+	}
+}
+
+// WithSerialUpdates returns an option that enables handling updates of a single user one after another.
+// queueSize limits waiting updates of a user, policy is [SerialDropNewest] by default.
+func WithSerialUpdates(queueSize int, policy ...SerialDropPolicy) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Serial.Enabled = true
+		opts.Config.Serial.QueueSize = queueSize
+		opts.Config.Serial.DropPolicy = lang.First(policy)
+	}
+}
+
+// WithWorkerPool returns an option that limits the number of concurrently running handlers.
+// queueSize limits waiting updates in a lane, overload is [OverloadBusy] by default.
+func WithWorkerPool(workers, queueSize int, overload ...OverloadPolicy) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Pool.Enabled = true
+		opts.Config.Pool.Workers = workers
+		opts.Config.Pool.QueueSize = queueSize
+		opts.Config.Pool.Overload = lang.First(overload)
+	}
+}
+
+// WithoutThrottle returns an option that disables limits of outgoing requests.
+// Requests rejected by Telegram with a flood error are still retried.
+func WithoutThrottle() func(opts *Options) {
+	return func(opts *Options) {
+		opts.Config.Throttle.Enabled = lang.Ptr(false)
+	}
+}
+
 // WithUserDB returns an option that sets the user storage.
 func WithUserDB(db UsersStorage) func(opts *Options) {
 	return func(opts *Options) {
@@ -841,6 +1308,15 @@ func (cfg *Config) prepareAndValidate() error {
 		}
 	}
 
+	if cfg.Mode == PollingModeReplay {
+		if cfg.Replay.Path == "" {
+			return erro.New("replay path is required in replay mode")
+		}
+		// Updates are replayed one by one in the recorded order
+		cfg.Serial.Enabled = false
+		cfg.Pool.Enabled = false
+	}
+
 	cfg.Mode = lang.Check(cfg.Mode, PollingModeLong)
 	cfg.LongPolling.Timeout = lang.Check(cfg.LongPolling.Timeout, defaultLongPollingTimeout)
 
@@ -870,11 +1346,56 @@ func (cfg *Config) prepareAndValidate() error {
 
 	cfg.Webhook.MetricsPath = lang.Check(cfg.Webhook.MetricsPath, defaultWebhookMetricsPath)
 
+	cfg.Webhook.Inbox.Path = lang.Check(cfg.Webhook.Inbox.Path, defaultWebhookInboxPath)
+	cfg.Webhook.Inbox.DedupTTL = lang.Check(cfg.Webhook.Inbox.DedupTTL, defaultWebhookInboxDedupTTL)
+	if cfg.Webhook.Inbox.DedupTTL < 0 {
+		return erro.New("webhook inbox dedup TTL cannot be negative")
+	}
+
 	cfg.Bot.ParseMode = lang.Check(cfg.Bot.ParseMode, defaultBotParseMode)
 	cfg.Bot.DefaultLanguage = lang.Check(cfg.Bot.DefaultLanguage, defaultBotDefaultLanguage)
 	cfg.Bot.DeleteMessages = lang.Ptr(lang.CheckPtr(cfg.Bot.DeleteMessages, defaultBotDeleteMessages))
 	cfg.Bot.UserCacheCapacity = lang.Check(cfg.Bot.UserCacheCapacity, defaultUserCacheCapacity)
 	cfg.Bot.UserCacheTTL = lang.Check(cfg.Bot.UserCacheTTL, defaultUserCacheTTL)
+	cfg.Bot.CallbackDataTTL = lang.Check(cfg.Bot.CallbackDataTTL, defaultCallbackDataTTL)
+	cfg.Bot.IdempotencyTTL = lang.Check(cfg.Bot.IdempotencyTTL, defaultIdempotencyTTL)
+	cfg.Bot.StreamInterval = lang.Check(cfg.Bot.StreamInterval, defaultStreamInterval)
+	if cfg.Bot.DebounceWindow < 0 {
+		return erro.New("debounce window cannot be negative")
+	}
+	if cfg.Bot.ChatActionDelay < 0 {
+		return erro.New("chat action delay cannot be negative")
+	}
+	if cfg.Bot.StreamInterval < 0 {
+		return erro.New("stream interval cannot be negative")
+	}
+	if cfg.Bot.HandlerTimeout < 0 {
+		return erro.New("handler timeout cannot be negative")
+	}
+	if cfg.Bot.NotificationTTL < 0 || cfg.Bot.ErrorTTL < 0 {
+		return erro.New("notification and error TTL cannot be negative")
+	}
+
+	cfg.Throttle.prepare()
+
+	cfg.Serial.QueueSize = lang.Check(cfg.Serial.QueueSize, defaultSerialQueueSize)
+	cfg.Serial.DropPolicy = lang.Check(cfg.Serial.DropPolicy, defaultSerialDropPolicy)
+	if cfg.Serial.QueueSize < 0 {
+		return erro.New("serial queue size cannot be negative")
+	}
+	if cfg.Serial.DropPolicy != SerialDropNewest && cfg.Serial.DropPolicy != SerialDropOldest {
+		return erro.New("invalid serial drop policy", "policy", cfg.Serial.DropPolicy)
+	}
+
+	cfg.Pool.Workers = lang.Check(cfg.Pool.Workers, defaultPoolWorkers)
+	cfg.Pool.QueueSize = lang.Check(cfg.Pool.QueueSize, defaultPoolQueueSize)
+	cfg.Pool.Overload = lang.Check(cfg.Pool.Overload, defaultPoolOverload)
+	if cfg.Pool.Workers < 0 || cfg.Pool.QueueSize < 0 {
+		return erro.New("pool workers and queue size cannot be negative")
+	}
+	if cfg.Pool.Overload != OverloadBusy && cfg.Pool.Overload != OverloadDrop {
+		return erro.New("invalid pool overload policy", "policy", cfg.Pool.Overload)
+	}
 
 	cfg.Log.Enable = lang.Ptr(lang.CheckPtr(cfg.Log.Enable, defaultLogEnable))
 	cfg.Log.LogUpdates = lang.Ptr(lang.CheckPtr(cfg.Log.LogUpdates, defaultLogUpdates))
@@ -883,6 +1404,17 @@ func (cfg *Config) prepareAndValidate() error {
 	return nil
 }
 
+func (cfg *ThrottleConfig) prepare() {
+	cfg.Enabled = lang.Ptr(lang.CheckPtr(cfg.Enabled, defaultThrottleEnabled))
+	cfg.GlobalPerSecond = lang.Check(cfg.GlobalPerSecond, defaultThrottleGlobalPerSecond)
+	cfg.ChatPerSecond = lang.Check(cfg.ChatPerSecond, defaultThrottleChatPerSecond)
+	cfg.GroupPerMinute = lang.Check(cfg.GroupPerMinute, defaultThrottleGroupPerMinute)
+	cfg.ChatBurst = lang.Check(cfg.ChatBurst, defaultThrottleChatBurst)
+	cfg.MaxQueue = lang.Check(cfg.MaxQueue, defaultThrottleMaxQueue)
+	cfg.MaxWait = lang.Check(cfg.MaxWait, defaultThrottleMaxWait)
+	cfg.MaxRetries = lang.Check(cfg.MaxRetries, defaultThrottleMaxRetries)
+}
+
 func (t UpdateType) String() string {
 	return string(t)
 }
@@ -944,6 +1476,17 @@ func prepareOpts(opts Options) (Options, error) {
 			return opts, erro.Wrap(err, "create webhook poller")
 		}
 		opts.Poller = webhookPoller
+
+		if opts.Config.Webhook.Inbox.Enabled || opts.InboxStore != nil {
+			store := opts.InboxStore
+			if store == nil {
+				store, err = NewFileInboxStore(opts.Config.Webhook.Inbox.Path, opts.Config.Webhook.Inbox.DedupTTL)
+				if err != nil {
+					return opts, erro.Wrap(err, "create inbox store")
+				}
+			}
+			webhookPoller.inbox = newWebhookInbox(store, opts.Logger, opts.metrics)
+		}
 	}
 
 	if opts.Config.Bot.Privacy.Mode.IsStrict() {
@@ -966,6 +1509,22 @@ func prepareOpts(opts Options) (Options, error) {
 		}
 	}
 
+	if opts.Config.Mode == PollingModeReplay {
+		opts.Poller = newReplayPoller(opts.Config.Replay, opts.Logger, opts.KeysProvider)
+		opts.Synchronous = true
+	}
+
+	if opts.Config.Bot.SignCallbackData {
+		key := opts.Config.Bot.CallbackSigningKey
+		if key == nil || *key == "" {
+			return opts, erro.New("callback signing key is required to sign callback data")
+		}
+		opts.callbackSigningKey, err = NewEncryptionKeyFromString(*key, nil)
+		if err != nil {
+			return opts, erro.Wrap(err, "parse callback signing key")
+		}
+	}
+
 	return opts, nil
 }
 
@@ -68,6 +68,10 @@ func TestWithOptionsHelpers(t *testing.T) {
 	if opts.Config.Webhook.RateLimit.Enabled == nil || *opts.Config.Webhook.RateLimit.Enabled == false {
 		t.Fatalf("WithWebhookRateLimit should enable ratelimit")
 	}
+	WithWebhookHandler("https://example.com/bot/hook")(&opts)
+	if opts.Config.Webhook.URL != "https://example.com/bot/hook" || !opts.Config.Webhook.ExternalServer {
+		t.Fatalf("WithWebhookHandler not applied")
+	}
 }
 
 func TestGetLogLevel(t *testing.T) {
@@ -0,0 +1,275 @@
+package bote
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/maxbolgarin/erro"
+	tele "github.com/maxbolgarin/telebot/v4"
+)
+
+const (
+	defaultPageSize    = 5
+	defaultPageColumns = 1
+	defaultPagePrev    = "«"
+	defaultPageNext    = "»"
+
+	pageDataPrefix = "page:"
+)
+
+// Page describes the page of a [Paginator] that is being rendered.
+type Page struct {
+	// Number is a zero-based number of the page.
+	Number int
+	// Pages is a total number of pages, it is at least 1.
+	Pages int
+	// Total is a total number of items.
+	Total int
+	// Offset is an index of the first item of the page.
+	Offset int
+	// Limit is a maximum number of items on the page.
+	Limit int
+}
+
+// IsFirst reports whether the page is the first one.
+func (p Page) IsFirst() bool {
+	return p.Number == 0
+}
+
+// IsLast reports whether the page is the last one.
+func (p Page) IsLast() bool {
+	return p.Number >= p.Pages-1
+}
+
+// PageItem is a button of a list item.
+type PageItem struct {
+	// Text is a text of the button. Items on one page should have different texts,
+	// because a button is identified by its text (see [Context.Btn]).
+	Text string
+	// Handler is called when the user taps the item. It is required.
+	Handler HandlerFunc
+	// Data is passed to the button and can be obtained with [Context.Data] inside Handler.
+	// Keep it short: callback data is limited to 64 bytes together with the button ID.
+	Data []string
+}
+
+// PageCounter returns a total number of items in the list.
+type PageCounter func(ctx Context) (int, error)
+
+// PageFetcher returns items of the page, usually with a query limited by page.Offset and page.Limit.
+type PageFetcher func(ctx Context, page Page) ([]PageItem, error)
+
+// PageText returns a text of the main message for the page.
+type PageText func(ctx Context, page Page) string
+
+// PaginatorOptions contains optional settings of [Paginator].
+type PaginatorOptions struct {
+	// PageSize is a number of items on a page. Default: 5.
+	PageSize int
+	// Columns is a number of item buttons in a row. Default: 1.
+	Columns int
+	// PrevText and NextText are texts of navigation buttons. Default: "«" and "»".
+	PrevText string
+	NextText string
+	// Buttons adds custom buttons (e.g. "Back") after the items and before the navigation row.
+	Buttons func(ctx Context, page Page, kb *Keyboard)
+}
+
+// WithPageSize sets a number of items on a page.
+func WithPageSize(size int) func(opts *PaginatorOptions) {
+	return func(opts *PaginatorOptions) {
+		opts.PageSize = size
+	}
+}
+
+// WithPageColumns sets a number of item buttons in a row.
+func WithPageColumns(columns int) func(opts *PaginatorOptions) {
+	return func(opts *PaginatorOptions) {
+		opts.Columns = columns
+	}
+}
+
+// WithPageNavigation sets texts of navigation buttons.
+func WithPageNavigation(prevText, nextText string) func(opts *PaginatorOptions) {
+	return func(opts *PaginatorOptions) {
+		opts.PrevText = prevText
+		opts.NextText = nextText
+	}
+}
+
+// WithPageButtons sets a function that adds custom buttons to every page.
+func WithPageButtons(f func(ctx Context, page Page, kb *Keyboard)) func(opts *PaginatorOptions) {
+	return func(opts *PaginatorOptions) {
+		opts.Buttons = f
+	}
+}
+
+// Paginator renders a list screen: item buttons of the current page and a navigation row
+// with previous/next buttons and a page indicator, added with [Keyboard.AddFooter].
+//
+// The state of the paginator is registered with [Bot.RegisterState], so [Context.Transition] to it
+// shows the list and its [InitBundle] restores the screen after a restart. Navigation buttons
+// carry the target page in their data and re-render the list with [Context.EditMain].
+// The current page is also saved with [User.SetValue], so the restored screen shows the page the
+// user left, unless [InitBundle.Data] (or [StateNode.Data]) is set with [PageData].
+type Paginator struct {
+	bt    *Bot
+	state State
+	text  PageText
+	total PageCounter
+	fetch PageFetcher
+	opts  PaginatorOptions
+}
+
+// NewPaginator creates a paginator and registers its state in the bot.
+// You should create paginators before calling [Bot.Start].
+func NewPaginator(b *Bot, state State, text PageText, total PageCounter, fetch PageFetcher, optsFuncs ...func(*PaginatorOptions)) *Paginator {
+	var opts PaginatorOptions
+	for _, f := range optsFuncs {
+		f(&opts)
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultPageSize
+	}
+	if opts.Columns <= 0 {
+		opts.Columns = defaultPageColumns
+	}
+	if opts.PrevText == "" {
+		opts.PrevText = defaultPagePrev
+	}
+	if opts.NextText == "" {
+		opts.NextText = defaultPageNext
+	}
+
+	p := &Paginator{
+		bt:    b,
+		state: state,
+		text:  text,
+		total: total,
+		fetch: fetch,
+		opts:  opts,
+	}
+	if state == nil || text == nil || total == nil || fetch == nil {
+		b.bot.log.Error("paginator must have state, text, counter and fetcher", "state", state)
+		b.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return p
+	}
+
+	b.RegisterState(state, StateNode{Render: p.Render})
+	return p
+}
+
+// State returns a state of the list screen.
+func (p *Paginator) State() State {
+	return p.state
+}
+
+// Render shows the page from [Context.Data] if it was created with [PageData], otherwise the page
+// the user saw last. It is the render handler of the paginator state.
+func (p *Paginator) Render(ctx Context) error {
+	page, ok := parsePageData(ctx.Data())
+	if !ok {
+		page = p.savedPage(ctx.User())
+	}
+	return p.Show(ctx, page)
+}
+
+// Show renders the page: it edits the main message if there is one and sends a new main message
+// otherwise. The page number is clamped to the existing pages.
+func (p *Paginator) Show(ctx Context, number int) error {
+	if p.fetch == nil {
+		return nil
+	}
+
+	total, err := p.total(ctx)
+	if err != nil {
+		return erro.Wrap(err, "count items", "state", p.state)
+	}
+	page := p.page(number, total)
+
+	items, err := p.fetch(ctx, page)
+	if err != nil {
+		return erro.Wrap(err, "fetch page", "state", p.state, "page", page.Number)
+	}
+
+	kb := p.keyboard(ctx, page, items)
+	ctx.User().SetValue(p.valueKey(), PageData(page.Number))
+
+	if ctx.User().Messages().MainID == 0 {
+		return ctx.SendMain(p.state, p.text(ctx, page), kb)
+	}
+	return ctx.EditMain(p.state, p.text(ctx, page), kb)
+}
+
+func (p *Paginator) page(number, total int) Page {
+	total = max(total, 0)
+	pages := max((total+p.opts.PageSize-1)/p.opts.PageSize, 1)
+	number = min(max(number, 0), pages-1)
+	return Page{
+		Number: number,
+		Pages:  pages,
+		Total:  total,
+		Offset: number * p.opts.PageSize,
+		Limit:  p.opts.PageSize,
+	}
+}
+
+func (p *Paginator) keyboard(ctx Context, page Page, items []PageItem) *tele.ReplyMarkup {
+	kb := NewKeyboard(p.opts.Columns)
+	for _, item := range items {
+		if item.Text == "" || item.Handler == nil {
+			continue
+		}
+		kb.Add(ctx.Btn(item.Text, item.Handler, item.Data...))
+	}
+	if p.opts.Buttons != nil {
+		kb.StartNewRow()
+		p.opts.Buttons(ctx, page, kb)
+	}
+
+	if page.Pages > 1 {
+		if !page.IsFirst() {
+			kb.AddFooter(ctx.Btn(p.opts.PrevText, p.Render, PageData(page.Number-1)))
+		}
+		// The indicator re-renders the current page, which refreshes a changed list.
+		indicator := strconv.Itoa(page.Number+1) + "/" + strconv.Itoa(page.Pages)
+		kb.AddFooter(ctx.Btn(indicator, p.Render, PageData(page.Number)))
+		if !page.IsLast() {
+			kb.AddFooter(ctx.Btn(p.opts.NextText, p.Render, PageData(page.Number+1)))
+		}
+	}
+
+	return kb.CreateInlineMarkup()
+}
+
+func (p *Paginator) savedPage(user User) int {
+	raw, ok := user.GetValue(p.valueKey())
+	if !ok {
+		return 0
+	}
+	text, _ := raw.(string)
+	page, _ := parsePageData(text)
+	return page
+}
+
+func (p *Paginator) valueKey() string {
+	return "paginator:" + p.state.String()
+}
+
+// PageData returns button data that opens the page of a [Paginator], e.g. for [InitBundle.Data]
+// or for a button that leads to the list. number is zero-based.
+func PageData(number int) string {
+	return pageDataPrefix + strconv.Itoa(number)
+}
+
+func parsePageData(data string) (int, bool) {
+	raw, ok := strings.CutPrefix(data, pageDataPrefix)
+	if !ok {
+		return 0, false
+	}
+	page, err := strconv.Atoi(raw)
+	if err != nil || page < 0 {
+		return 0, false
+	}
+	return page, true
+}
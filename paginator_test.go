@@ -0,0 +1,109 @@
+package bote
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPaginator(t *testing.T, total int, fetched *[]Page) (*Bot, *Paginator) {
+	t.Helper()
+	bot := setupTestBot(t)
+	p := NewPaginator(bot, UserState("tasks"),
+		func(ctx Context, page Page) string { return "Tasks" },
+		func(ctx Context) (int, error) { return total, nil },
+		func(ctx Context, page Page) ([]PageItem, error) {
+			*fetched = append(*fetched, page)
+			items := make([]PageItem, 0, page.Limit)
+			for i := page.Offset; i < min(page.Offset+page.Limit, total); i++ {
+				items = append(items, PageItem{
+					Text:    "Task " + strconv.Itoa(i),
+					Handler: func(ctx Context) error { return nil },
+					Data:    []string{strconv.Itoa(i)},
+				})
+			}
+			return items, nil
+		},
+		WithPageSize(3),
+	)
+	return bot, p
+}
+
+func TestPaginatorPage(t *testing.T) {
+	var fetched []Page
+	_, p := newTestPaginator(t, 7, &fetched)
+
+	assert.Equal(t, Page{Number: 0, Pages: 3, Total: 7, Offset: 0, Limit: 3}, p.page(0, 7))
+	assert.Equal(t, Page{Number: 2, Pages: 3, Total: 7, Offset: 6, Limit: 3}, p.page(10, 7), "clamped to the last page")
+	assert.Equal(t, Page{Number: 0, Pages: 1, Total: 0, Offset: 0, Limit: 3}, p.page(-1, 0), "empty list has one page")
+	assert.True(t, p.page(2, 7).IsLast())
+	assert.True(t, p.page(0, 7).IsFirst())
+}
+
+// TestPaginatorKeyboard verifies item buttons are followed by the navigation footer with the target page in data.
+func TestPaginatorKeyboard(t *testing.T) {
+	var fetched []Page
+	bot, p := newTestPaginator(t, 7, &fetched)
+	ctx := NewContext(bot, 8001, 1)
+
+	page := p.page(1, 7)
+	items, err := p.fetch(ctx, page)
+	require.NoError(t, err)
+
+	kb := p.keyboard(ctx, page, items)
+	require.Len(t, kb.InlineKeyboard, 4, "three item rows and navigation")
+	assert.Equal(t, "Task 3", kb.InlineKeyboard[0][0].Text)
+	assert.Equal(t, "3", kb.InlineKeyboard[0][0].Data)
+
+	nav := kb.InlineKeyboard[3]
+	require.Len(t, nav, 3)
+	assert.Equal(t, []string{"«", "2/3", "»"}, []string{nav[0].Text, nav[1].Text, nav[2].Text})
+	assert.Equal(t, PageData(0), nav[0].Data)
+	assert.Equal(t, PageData(2), nav[2].Data)
+	for _, btn := range nav {
+		assert.LessOrEqual(t, len(btn.Unique)+len(btn.Data)+2, MaxDataLengthBytes)
+	}
+
+	first := p.keyboard(ctx, p.page(0, 7), nil)
+	require.Len(t, first.InlineKeyboard, 1)
+	assert.Equal(t, "1/3", first.InlineKeyboard[0][0].Text, "no previous button on the first page")
+
+	single := p.keyboard(ctx, p.page(0, 2), nil)
+	assert.Empty(t, single.InlineKeyboard, "no navigation for a single page")
+}
+
+// TestPaginatorRenderRestoresPage verifies the page comes from data and falls back to the page the user saw last.
+func TestPaginatorRenderRestoresPage(t *testing.T) {
+	var fetched []Page
+	bot, p := newTestPaginator(t, 7, &fetched)
+
+	// Sending fails in offline mode, only the fetched pages are checked.
+	_ = p.Render(NewContext(bot, 8002, 1, PageData(2)))
+	require.Len(t, fetched, 1)
+	assert.Equal(t, 2, fetched[0].Number)
+
+	_ = p.Render(NewContext(bot, 8002, 1))
+	require.Len(t, fetched, 2)
+	assert.Equal(t, 2, fetched[1].Number, "saved page is restored when data has no page")
+
+	_ = p.Render(NewContext(bot, 8003, 1, "42"))
+	require.Len(t, fetched, 3)
+	assert.Equal(t, 0, fetched[2].Number, "data of other buttons is not a page")
+
+	bundle, ok := bot.stateMap.Lookup("tasks")
+	require.True(t, ok, "state is registered for restart recovery")
+	assert.NotNil(t, bundle.Handler)
+}
+
+func TestPageData(t *testing.T) {
+	page, ok := parsePageData(PageData(12))
+	assert.True(t, ok)
+	assert.Equal(t, 12, page)
+
+	for _, data := range []string{"", "12", "page:", "page:-1", "page:x"} {
+		_, ok := parsePageData(data)
+		assert.False(t, ok, data)
+	}
+}
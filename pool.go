@@ -0,0 +1,204 @@
+package bote
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/maxbolgarin/lang"
+	tele "github.com/maxbolgarin/telebot/v4"
+)
+
+// OverloadPolicy is a policy of handling updates when a queue of the worker pool is full.
+type OverloadPolicy string
+
+const (
+	// OverloadBusy answers a shed update with a localized "busy" message, see [BusyMessages].
+	// The message is sent only in private chats, updates from groups and channels are dropped silently.
+	OverloadBusy OverloadPolicy = "busy"
+	// OverloadDrop drops a shed update, a tapped button is answered without text.
+	OverloadDrop OverloadPolicy = "drop"
+)
+
+// poolLane is a priority lane of the worker pool.
+type poolLane string
+
+const (
+	// poolLaneHigh is a lane of button taps and commands, users are waiting for an answer to them.
+	poolLaneHigh poolLane = "high"
+	// poolLaneLow is a lane of plain text, media, group and channel messages and other updates.
+	poolLaneLow poolLane = "low"
+)
+
+// workerPool handles updates with a fixed number of workers. Updates are queued in two lanes
+// and workers take updates from the high lane first. When a lane is full, the update is shed.
+type workerPool struct {
+	bot      *baseBot
+	ctx      context.Context
+	handle   func(tele.Update)
+	overload OverloadPolicy
+
+	// busyText returns a text of the "busy" message for the update, it is set by [Bot].
+	busyText func(upd *tele.Update) string
+
+	high chan poolTask
+	low  chan poolTask
+}
+
+type poolTask struct {
+	upd      tele.Update
+	lane     poolLane
+	queuedAt time.Time
+	done     chan struct{}
+}
+
+func newWorkerPool(ctx context.Context, bot *baseBot, cfg PoolConfig) *workerPool {
+	p := &workerPool{
+		bot: bot,
+		ctx: ctx,
+		// Telebot is synchronous with the pool, so handlers of the update run in the worker.
+		handle:   bot.processUpdate,
+		overload: cfg.Overload,
+		busyText: func(*tele.Update) string { return enMessages{}.Busy() },
+		high:     make(chan poolTask, cfg.QueueSize),
+		low:      make(chan poolTask, cfg.QueueSize),
+	}
+	for range cfg.Workers {
+		lang.Go(bot.log, p.work)
+	}
+	return p
+}
+
+// middleware passes the update through bot middlewares and puts it to the pool.
+// It always returns false: the update is processed by workers, not by the poller.
+func (p *workerPool) middleware(upd *tele.Update) bool {
+	if !p.bot.middleware(upd) {
+		p.bot.handled(upd)
+		return false
+	}
+	p.submit(*upd, nil)
+	return false
+}
+
+// wait puts the update to the pool and waits until it is handled or shed.
+// It is used by per-user queues, so the pool limits handlers of all users.
+func (p *workerPool) wait(upd tele.Update) {
+	done := make(chan struct{})
+	if !p.submit(upd, done) {
+		return
+	}
+	select {
+	case <-done:
+	case <-p.ctx.Done():
+	}
+}
+
+// submit puts the update to its lane, it sheds the update and returns false if the lane is full.
+func (p *workerPool) submit(upd tele.Update, done chan struct{}) bool {
+	task := poolTask{upd: upd, lane: updateLane(&upd), queuedAt: time.Now(), done: done}
+
+	queue := lang.If(task.lane == poolLaneHigh, p.high, p.low)
+	select {
+	case queue <- task:
+		p.bot.metr.setHandlerQueueDepth(string(task.lane), len(queue))
+		return true
+	default:
+		p.shed(&task)
+		return false
+	}
+}
+
+func (p *workerPool) work() {
+	for {
+		// Take updates from the high lane while there are any.
+		select {
+		case task := <-p.high:
+			p.process(task)
+			continue
+		default:
+		}
+
+		select {
+		case task := <-p.high:
+			p.process(task)
+		case task := <-p.low:
+			p.process(task)
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *workerPool) process(task poolTask) {
+	queue := lang.If(task.lane == poolLaneHigh, p.high, p.low)
+	p.bot.metr.setHandlerQueueDepth(string(task.lane), len(queue))
+	p.bot.metr.observeHandlerQueueWait(string(task.lane), time.Since(task.queuedAt))
+
+	if task.done != nil {
+		defer close(task.done)
+	}
+	defer lang.Recover(p.bot.log)
+	p.handle(task.upd)
+}
+
+// shed drops the update and tells the user that the bot is busy if the policy allows it.
+func (p *workerPool) shed(task *poolTask) {
+	chatID, chatType, _ := getChatID(&task.upd)
+	p.bot.log.Warn("handler queue is full, update is shed",
+		"chat_id", prepareUserID(chatID, p.bot.priv),
+		"update_id", task.upd.ID,
+		"lane", string(task.lane),
+		"policy", string(p.overload),
+	)
+	p.bot.metr.incHandlerQueueShed(string(task.lane), string(p.overload))
+	p.bot.handled(&task.upd)
+
+	var text string
+	if p.overload == OverloadBusy {
+		text = p.busyText(&task.upd)
+	}
+
+	upd := task.upd
+	switch {
+	case upd.Callback != nil:
+		lang.Go(p.bot.log, func() {
+			if err := p.bot.tbot.Respond(upd.Callback, &tele.CallbackResponse{Text: text}); err != nil {
+				p.bot.log.Debug("failed to respond to shed callback", "error", err.Error())
+			}
+		})
+
+	case text != "" && upd.Message != nil && chatType == tele.ChatPrivate:
+		lang.Go(p.bot.log, func() {
+			if _, err := p.bot.send(chatID, text); err != nil {
+				p.bot.log.Debug("failed to send busy message", "error", err.Error())
+			}
+		})
+	}
+}
+
+// updateLane returns a lane of the update: button taps and commands go to the high lane.
+func updateLane(upd *tele.Update) poolLane {
+	switch {
+	case upd.Callback != nil:
+		return poolLaneHigh
+	case upd.Message != nil && strings.HasPrefix(upd.Message.Text, "/"):
+		return poolLaneHigh
+	default:
+		return poolLaneLow
+	}
+}
+
+// busyText returns a localized text of the "busy" message for the sender of the update.
+func (b *Bot) busyText(upd *tele.Update) string {
+	language := b.defaultLanguage
+	if sender := getSender(upd); sender != nil {
+		if l, err := ParseLanguage(sender.LanguageCode); err == nil {
+			language = l
+		}
+	}
+	msgs, ok := b.msgs.Messages(language).(BusyMessages)
+	if !ok {
+		msgs = enMessages{}
+	}
+	return msgs.Busy()
+}
@@ -0,0 +1,127 @@
+package bote
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func poolTestCallback(id int, userID int64) tele.Update {
+	return tele.Update{ID: id, Callback: &tele.Callback{
+		Sender:  &tele.User{ID: userID},
+		Message: &tele.Message{Chat: &tele.Chat{ID: userID, Type: tele.ChatPrivate}},
+	}}
+}
+
+func newWorkerPoolForTest(t *testing.T, cfg PoolConfig) (*workerPool, chan int, chan struct{}) {
+	bot := setupTestBot(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	handled := make(chan int, 100)
+	block := make(chan struct{})
+	p := newWorkerPool(ctx, bot.bot, cfg)
+	p.handle = func(upd tele.Update) {
+		if upd.ID == 1 {
+			<-block
+		}
+		handled <- upd.ID
+	}
+	return p, handled, block
+}
+
+func receiveHandled(t *testing.T, handled chan int, n int) []int {
+	ids := make([]int, 0, n)
+	for range n {
+		select {
+		case id := <-handled:
+			ids = append(ids, id)
+		case <-time.After(time.Second):
+			t.Fatal("update is not handled")
+		}
+	}
+	return ids
+}
+
+// TestUpdateLane verifies button taps and commands go to the high lane.
+func TestUpdateLane(t *testing.T) {
+	assert.Equal(t, poolLaneHigh, updateLane(&tele.Update{Callback: &tele.Callback{}}))
+	assert.Equal(t, poolLaneHigh, updateLane(&tele.Update{Message: &tele.Message{Text: "/start"}}))
+	assert.Equal(t, poolLaneLow, updateLane(&tele.Update{Message: &tele.Message{Text: "hello"}}))
+	assert.Equal(t, poolLaneLow, updateLane(&tele.Update{ChannelPost: &tele.Message{Text: "/news"}}))
+}
+
+// TestWorkerPoolPriority verifies waiting taps and commands are handled before plain messages.
+func TestWorkerPoolPriority(t *testing.T) {
+	p, handled, block := newWorkerPoolForTest(t, PoolConfig{Workers: 1, QueueSize: 10, Overload: OverloadDrop})
+
+	require.True(t, p.submit(serialTestUpdate(1, 1), nil))
+	// Wait until the only worker is busy with the first update.
+	require.Eventually(t, func() bool { return len(p.low) == 0 }, time.Second, time.Millisecond)
+
+	require.True(t, p.submit(serialTestUpdate(2, 2), nil))
+	require.True(t, p.submit(serialTestUpdate(3, 3), nil))
+	require.True(t, p.submit(poolTestCallback(4, 4), nil))
+	require.True(t, p.submit(poolTestCallback(5, 5), nil))
+	close(block)
+
+	assert.Equal(t, []int{1, 4, 5, 2, 3}, receiveHandled(t, handled, 5))
+}
+
+// TestWorkerPoolShed verifies updates are shed when a lane is full and other lanes are not affected.
+func TestWorkerPoolShed(t *testing.T) {
+	p, handled, block := newWorkerPoolForTest(t, PoolConfig{Workers: 1, QueueSize: 1, Overload: OverloadDrop})
+
+	require.True(t, p.submit(serialTestUpdate(1, 1), nil))
+	require.Eventually(t, func() bool { return len(p.low) == 0 }, time.Second, time.Millisecond)
+
+	assert.True(t, p.submit(serialTestUpdate(2, 2), nil))
+	assert.False(t, p.submit(serialTestUpdate(3, 3), nil), "low lane is full")
+	assert.True(t, p.submit(poolTestCallback(4, 4), nil), "high lane has its own queue")
+	close(block)
+
+	assert.ElementsMatch(t, []int{1, 2, 4}, receiveHandled(t, handled, 3))
+}
+
+// TestWorkerPoolWait verifies per-user queues wait until the update is handled.
+func TestWorkerPoolWait(t *testing.T) {
+	p, handled, _ := newWorkerPoolForTest(t, PoolConfig{Workers: 2, QueueSize: 1, Overload: OverloadDrop})
+
+	p.wait(serialTestUpdate(2, 2))
+	select {
+	case id := <-handled:
+		assert.Equal(t, 2, id)
+	default:
+		t.Fatal("wait returned before the update is handled")
+	}
+}
+
+// TestBusyText verifies the busy message is localized by the language of the sender.
+func TestBusyText(t *testing.T) {
+	bot := setupTestBot(t)
+
+	ru := serialTestUpdate(1, 1)
+	ru.Message.Sender.LanguageCode = "ru"
+	assert.Equal(t, ruMessages{}.Busy(), bot.busyText(&ru))
+
+	unknown := serialTestUpdate(2, 2)
+	unknown.Message.Sender.LanguageCode = "xx"
+	assert.Equal(t, bot.msgs.Messages(bot.defaultLanguage).(BusyMessages).Busy(), bot.busyText(&unknown))
+}
+
+// TestPoolOptions verifies defaults and validation of the pool configuration.
+func TestPoolOptions(t *testing.T) {
+	opts := Options{Offline: true, Poller: &mockPoller{}, Config: Config{Mode: PollingModeCustom}}
+	WithWorkerPool(0, 0)(&opts)
+	prepared, err := prepareOpts(opts)
+	require.NoError(t, err)
+	assert.Equal(t, PoolConfig{Enabled: true, Workers: 100, QueueSize: 1000, Overload: OverloadBusy}, prepared.Config.Pool)
+
+	WithWorkerPool(10, 10, "panic")(&opts)
+	_, err = prepareOpts(opts)
+	assert.Error(t, err)
+}
@@ -0,0 +1,373 @@
+package bote
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/maxbolgarin/erro"
+	"github.com/maxbolgarin/lang"
+	tele "github.com/maxbolgarin/telebot/v4"
+)
+
+// RecordedUpdate is an update written by the recorder, see [RecordConfig].
+// A recording is a JSONL file with one RecordedUpdate per line.
+type RecordedUpdate struct {
+	// Time is the time when the bot received the update.
+	Time time.Time `json:"time"`
+	// Redacted is true if the update is recorded in strict privacy mode: IDs of users are replaced
+	// with [RecordingUserID] and their names, usernames and phone numbers are removed.
+	Redacted bool `json:"redacted,omitempty"`
+	// Update is the update as it came from Telegram.
+	Update tele.Update `json:"update"`
+}
+
+// ReadRecording reads updates recorded by the recorder from the file.
+// If userID is provided, it returns only updates sent by this user; for redacted recordings
+// pass an ID from [RecordingUserID].
+func ReadRecording(path string, userID ...int64) ([]RecordedUpdate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, erro.Wrap(err, "open recording")
+	}
+	defer f.Close()
+
+	var out []RecordedUpdate
+	err = readRecording(f, func(rec RecordedUpdate) bool {
+		if len(userID) == 0 || isRecordedFrom(rec.Update, userID[0]) {
+			out = append(out, rec)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RecordingUserID returns an ID that replaces the user ID in recordings of strict privacy mode.
+// The same user gets the same ID in all recordings made with the same HMAC key, so a session of
+// a single user can be found and replayed without storing the real ID.
+func RecordingUserID(id int64, hmacKey *EncryptionKey) int64 {
+	if hmacKey == nil || hmacKey.key == nil {
+		return 0
+	}
+	var bytesID [8]byte
+	binary.BigEndian.PutUint64(bytesID[:], uint64(id))
+
+	mac := hmac.New(sha256.New, hmacKey.key[:])
+	mac.Write([]byte("bote-record\x00"))
+	mac.Write(bytesID[:])
+
+	// 48 bits keep the ID positive and exact in JSON parsers that use float64.
+	return int64(binary.BigEndian.Uint64(mac.Sum(nil)[:8])>>16) + 1
+}
+
+// recorder writes every incoming update to a JSONL file.
+type recorder struct {
+	bot  *baseBot
+	keys KeysProvider
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newRecorder(b *baseBot, cfg RecordConfig, keys KeysProvider) (*recorder, error) {
+	file, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, erro.Wrap(err, "open record file")
+	}
+	return &recorder{bot: b, keys: keys, file: file}, nil
+}
+
+// middleware records the update and passes it to the next filter.
+// Updates restored from the webhook inbox are not recorded again, they were recorded before restart.
+func (r *recorder) middleware(next func(upd *tele.Update) bool) func(upd *tele.Update) bool {
+	return func(upd *tele.Update) bool {
+		if !r.bot.inbox.isRestored(upd.ID) {
+			r.write(upd)
+		}
+		return next(upd)
+	}
+}
+
+func (r *recorder) write(upd *tele.Update) {
+	line, err := r.encode(upd)
+	if err != nil {
+		r.bot.log.Error("failed to encode recorded update", "update_id", upd.ID, "error", err.Error())
+		r.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityLow)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return
+	}
+	if _, err := r.file.Write(line); err != nil {
+		r.bot.log.Error("failed to write recorded update", "update_id", upd.ID, "error", err.Error())
+		r.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityLow)
+	}
+}
+
+func (r *recorder) encode(upd *tele.Update) ([]byte, error) {
+	data, err := json.Marshal(upd)
+	if err != nil {
+		return nil, err
+	}
+	redacted := r.bot.priv.IsStrict()
+	if redacted {
+		if data, err = redactUpdate(data, r.keys.GetHMACKey()); err != nil {
+			return nil, err
+		}
+	}
+
+	line, err := json.Marshal(struct {
+		Time     time.Time       `json:"time"`
+		Redacted bool            `json:"redacted,omitempty"`
+		Update   json.RawMessage `json:"update"`
+	}{time.Now(), redacted, data})
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+func (r *recorder) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// redactUpdate replaces IDs of users with [RecordingUserID] and removes their personal data.
+// Users are objects with "is_bot", private chats and objects with "user_id" (e.g. contacts).
+func redactUpdate(data []byte, hmacKey *EncryptionKey) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	redactValue(v, hmacKey)
+	return json.Marshal(v)
+}
+
+func redactValue(v any, hmacKey *EncryptionKey) {
+	switch v := v.(type) {
+	case map[string]any:
+		_, isUser := v["is_bot"]
+		_, hasUserID := v["user_id"]
+		if isUser || v["type"] == string(tele.ChatPrivate) {
+			redactID(v, "id", hmacKey)
+		}
+		if hasUserID {
+			redactID(v, "user_id", hmacKey)
+		}
+		if isUser || hasUserID || v["type"] == string(tele.ChatPrivate) {
+			for _, key := range []string{"first_name", "last_name", "username", "phone_number"} {
+				delete(v, key)
+			}
+		}
+		for _, child := range v {
+			redactValue(child, hmacKey)
+		}
+
+	case []any:
+		for _, child := range v {
+			redactValue(child, hmacKey)
+		}
+	}
+}
+
+func redactID(m map[string]any, key string, hmacKey *EncryptionKey) {
+	num, ok := m[key].(json.Number)
+	if !ok {
+		return
+	}
+	id, err := num.Int64()
+	if err != nil {
+		return
+	}
+	m[key] = json.Number(strconv.FormatInt(RecordingUserID(id, hmacKey), 10))
+}
+
+// readRecording calls f for every update of the recording until f returns false.
+func readRecording(r io.Reader, f func(rec RecordedUpdate) bool) error {
+	reader := bufio.NewReader(r)
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec RecordedUpdate
+			if err := json.Unmarshal(line, &rec); err != nil {
+				return erro.Wrap(err, "parse recorded update", "line", lineNum)
+			}
+			if !f(rec) {
+				return nil
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return erro.Wrap(err, "read recording")
+		}
+	}
+}
+
+func isRecordedFrom(upd tele.Update, userID int64) bool {
+	sender := getSender(&upd)
+	return sender != nil && sender.ID == userID
+}
+
+// replayPoller feeds updates from a recording to the bot one by one.
+type replayPoller struct {
+	cfg  ReplayConfig
+	log  Logger
+	keys KeysProvider
+}
+
+func newReplayPoller(cfg ReplayConfig, log Logger, keys KeysProvider) *replayPoller {
+	return &replayPoller{cfg: cfg, log: log, keys: keys}
+}
+
+// Poll replays the recording and waits for the bot to stop.
+func (p *replayPoller) Poll(_ *tele.Bot, dest chan tele.Update, stop chan struct{}) {
+	count, err := p.replay(dest, stop)
+	if err != nil {
+		p.log.Error("failed to replay updates", "path", p.cfg.Path, "error", err.Error())
+	} else {
+		p.log.Info("replay is finished", "path", p.cfg.Path, "updates", count)
+	}
+	<-stop
+}
+
+func (p *replayPoller) replay(dest chan tele.Update, stop chan struct{}) (int, error) {
+	f, err := os.Open(p.cfg.Path)
+	if err != nil {
+		return 0, erro.Wrap(err, "open recording")
+	}
+	defer f.Close()
+
+	var count int
+	err = readRecording(f, func(rec RecordedUpdate) bool {
+		if p.cfg.UserID != 0 && !isRecordedFrom(rec.Update, p.userID(rec)) {
+			return true
+		}
+		select {
+		case dest <- rec.Update:
+			count++
+			return true
+		case <-stop:
+			return false
+		}
+	})
+	return count, err
+}
+
+// userID returns an ID of the replayed user as it is written in the record.
+func (p *replayPoller) userID(rec RecordedUpdate) int64 {
+	if rec.Redacted {
+		return RecordingUserID(p.cfg.UserID, p.keys.GetHMACKey())
+	}
+	return p.cfg.UserID
+}
+
+// offlineTransport answers requests to Bot API without network, so replayed handlers never reach real users.
+// Every method succeeds and returns a new message in the requested chat.
+type offlineTransport struct {
+	log   Logger
+	msgID atomic.Int64
+}
+
+func newOfflineTransport(log Logger) *offlineTransport {
+	return &offlineTransport{log: log}
+}
+
+func (t *offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	chatID := requestChatID(req)
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	t.log.Debug("offline request to Bot API", "method", method)
+
+	var result any
+	if method == "getMe" {
+		result = map[string]any{"id": 1, "is_bot": true, "first_name": "bote", "username": "bote_offline_bot"}
+	} else {
+		result = map[string]any{
+			"message_id": t.msgID.Add(1),
+			"date":       time.Now().Unix(),
+			"chat":       map[string]any{"id": chatID, "type": lang.If(chatID < 0, "supergroup", "private")},
+		}
+	}
+
+	body, err := json.Marshal(map[string]any{"ok": true, "result": result})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// requestChatID returns chat_id of a JSON or multipart request to Bot API.
+func requestChatID(req *http.Request) int64 {
+	if req.Body == nil {
+		return 0
+	}
+
+	var raw string
+	mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		mr := multipart.NewReader(req.Body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			if part.FormName() == "chat_id" {
+				data, _ := io.ReadAll(part)
+				raw = string(data)
+				break
+			}
+		}
+	} else {
+		var body struct {
+			ChatID json.RawMessage `json:"chat_id"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err == nil {
+			raw = string(bytes.Trim(body.ChatID, `"`))
+		}
+	}
+
+	chatID, _ := strconv.ParseInt(raw, 10, 64)
+	return chatID
+}
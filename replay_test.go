@@ -0,0 +1,189 @@
+package bote
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recordedMessage(id int, userID int64, text string) *tele.Update {
+	user := &tele.User{ID: userID, FirstName: "Ivan", Username: "ivan"}
+	return &tele.Update{
+		ID: id,
+		Message: &tele.Message{
+			ID:     id,
+			Sender: user,
+			Chat:   &tele.Chat{ID: userID, Type: tele.ChatPrivate, FirstName: "Ivan", Username: "ivan"},
+			Text:   text,
+		},
+	}
+}
+
+func TestRecordingUserID(t *testing.T) {
+	key := NewEncryptionKey(nil)
+
+	id := RecordingUserID(42, key)
+	assert.Positive(t, id)
+	assert.Less(t, id, int64(1)<<48+1)
+	assert.Equal(t, id, RecordingUserID(42, key))
+	assert.NotEqual(t, id, RecordingUserID(43, key))
+	assert.NotEqual(t, id, RecordingUserID(42, NewEncryptionKey(nil)))
+	assert.Zero(t, RecordingUserID(42, nil))
+}
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updates.jsonl")
+	b := &baseBot{log: noopLogger{}, priv: PrivacyModeLow}
+
+	rec, err := newRecorder(b, RecordConfig{Path: path}, nil)
+	require.NoError(t, err)
+
+	var passed int
+	filter := rec.middleware(func(*tele.Update) bool {
+		passed++
+		return true
+	})
+	assert.True(t, filter(recordedMessage(1, 42, "/start")))
+	assert.True(t, filter(recordedMessage(2, 7, "hello")))
+	assert.True(t, filter(recordedMessage(3, 42, "buy milk")))
+	require.NoError(t, rec.close())
+	assert.Equal(t, 3, passed)
+
+	// Closed recorder drops updates
+	filter(recordedMessage(4, 42, "late"))
+
+	all, err := ReadRecording(path)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.False(t, all[0].Redacted)
+	assert.False(t, all[0].Time.IsZero())
+	assert.Equal(t, "ivan", all[0].Update.Message.Sender.Username)
+
+	user, err := ReadRecording(path, 42)
+	require.NoError(t, err)
+	require.Len(t, user, 2)
+	assert.Equal(t, "/start", user[0].Update.Message.Text)
+	assert.Equal(t, "buy milk", user[1].Update.Message.Text)
+}
+
+func TestRecorderStrictPrivacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updates.jsonl")
+	hmacKey := NewEncryptionKey(nil)
+	keys := &simpleKeysProvider{hmacKey: hmacKey}
+
+	b := &baseBot{log: noopLogger{}, priv: PrivacyModeStrict}
+	rec, err := newRecorder(b, RecordConfig{Path: path}, keys)
+	require.NoError(t, err)
+
+	upd := recordedMessage(1, 42, "/start")
+	upd.Message.Contact = &tele.Contact{PhoneNumber: "+100", FirstName: "Petr", UserID: 7}
+	rec.write(upd)
+	require.NoError(t, rec.close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{"ivan", "Ivan", "Petr", "+100", `"id":42`, `"user_id":7`} {
+		assert.NotContains(t, string(data), secret)
+	}
+
+	recs, err := ReadRecording(path, RecordingUserID(42, hmacKey))
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.True(t, recs[0].Redacted)
+	assert.Equal(t, "/start", recs[0].Update.Message.Text)
+	assert.Equal(t, RecordingUserID(42, hmacKey), recs[0].Update.Message.Chat.ID)
+	assert.Equal(t, RecordingUserID(7, hmacKey), recs[0].Update.Message.Contact.UserID)
+}
+
+func TestReplayPoller(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updates.jsonl")
+	rec, err := newRecorder(&baseBot{log: noopLogger{}}, RecordConfig{Path: path}, nil)
+	require.NoError(t, err)
+	for i, userID := range []int64{42, 7, 42, 7, 42} {
+		rec.write(recordedMessage(i+1, userID, "msg"))
+	}
+	require.NoError(t, rec.close())
+
+	replay := func(userID int64) []int {
+		p := newReplayPoller(ReplayConfig{Path: path, UserID: userID}, noopLogger{}, nil)
+		dest := make(chan tele.Update, 10)
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			p.Poll(nil, dest, stop)
+			close(done)
+		}()
+
+		var ids []int
+		for {
+			select {
+			case upd := <-dest:
+				ids = append(ids, upd.ID)
+				continue
+			case <-time.After(100 * time.Millisecond):
+			}
+			break
+		}
+		close(stop)
+		<-done
+		return ids
+	}
+
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, replay(0))
+	assert.Equal(t, []int{1, 3, 5}, replay(42))
+}
+
+func TestReadRecordingBadLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updates.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(`{"update":{"update_id":1}}`+"\n\nnot json\n"), 0o600))
+
+	_, err := ReadRecording(path)
+	assert.Error(t, err)
+}
+
+func TestOfflineTransport(t *testing.T) {
+	client := &http.Client{Transport: newOfflineTransport(noopLogger{})}
+
+	post := func(method, body string) map[string]any {
+		resp, err := client.Post("https://api.telegram.org/bot123:abc/"+method, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var out struct {
+			OK     bool           `json:"ok"`
+			Result map[string]any `json:"result"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		assert.True(t, out.OK)
+		return out.Result
+	}
+
+	me := post("getMe", "")
+	assert.Equal(t, true, me["is_bot"])
+
+	first := post("sendMessage", `{"chat_id":"42","text":"hi"}`)
+	assert.Equal(t, float64(42), first["chat"].(map[string]any)["id"])
+
+	second := post("editMessageText", `{"chat_id":-100,"message_id":"1","text":"hi"}`)
+	assert.Equal(t, float64(-100), second["chat"].(map[string]any)["id"])
+	assert.Equal(t, "supergroup", second["chat"].(map[string]any)["type"])
+	assert.NotEqual(t, first["message_id"], second["message_id"])
+}
+
+func TestReplayModeConfig(t *testing.T) {
+	cfg := Config{Mode: PollingModeReplay}
+	cfg.Serial.Enabled = true
+	assert.Error(t, cfg.prepareAndValidate())
+
+	cfg.Replay.Path = "updates.jsonl"
+	require.NoError(t, cfg.prepareAndValidate())
+	assert.False(t, cfg.Serial.Enabled)
+}
@@ -0,0 +1,442 @@
+package bote
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxbolgarin/abstract"
+	"github.com/maxbolgarin/erro"
+	"github.com/maxbolgarin/lang"
+)
+
+// schedulerIdleInterval is the longest sleep of the scheduler loop when there are no close jobs.
+const schedulerIdleInterval = time.Hour
+
+var (
+	errEmptyJobHandler     = erro.New("job handler cannot be nil")
+	errEmptyJobHandlerName = erro.New("job handler name cannot be empty")
+	errEmptyJobKey         = erro.New("job key cannot be empty")
+	errUnnamedJobHandler   = erro.New("closures and method values must be registered with RegisterJobHandler")
+	errJobHandlerNamed     = erro.New("job handler is already registered under another name")
+)
+
+// unstableFuncNameRx matches runtime names of closures and method values, e.g. "main.main.func1" or
+// "main.(*Service).Remind-fm". They depend on the code around them and cannot identify a stored job.
+var unstableFuncNameRx = regexp.MustCompile(`\.func\d+(\.\d+)*$|\.gowrap\d+$|-fm$`)
+
+// ScheduledJob is a persisted job of the scheduler. Handler is stored by name: the name passed to
+// [Bot.RegisterJobHandler] or the full name of the function, so it should not change between releases.
+type ScheduledJob struct {
+	// Key is a unique key of the job. It is used to cancel or replace the job.
+	Key string `json:"key" bson:"key" db:"key"`
+	// UserID is an ID of the user the handler runs for. It is encrypted in strict privacy mode.
+	UserID FullUserID `json:"user_id" bson:"user_id" db:"user_id"`
+	// Handler is a name of the handler, e.g. "main.remind".
+	Handler string `json:"handler" bson:"handler" db:"handler"`
+	// At is a time of the next run.
+	At time.Time `json:"at" bson:"at" db:"at"`
+	// Cron is a recurrence spec, see [Cron]. Job runs once if it is empty.
+	Cron string `json:"cron,omitempty" bson:"cron,omitempty" db:"cron"`
+	// Data is a payload available in the handler via [Context.Data] and [Context.DataParsed].
+	Data []string `json:"data,omitempty" bson:"data,omitempty" db:"data"`
+}
+
+// JobStore persists scheduled jobs. You should implement it (or use filestorage.NewJobStore)
+// if you want jobs to survive restarts; in-memory store is used by default.
+type JobStore interface {
+	// Save inserts the job or replaces a job with the same key.
+	Save(ctx context.Context, job ScheduledJob) error
+	// Delete deletes the job by key. It should not return an error if the job does not exist.
+	Delete(ctx context.Context, key string) error
+	// List returns all jobs.
+	List(ctx context.Context) ([]ScheduledJob, error)
+}
+
+// Schedule runs the handler for the user once at the given time and returns a key of the job.
+// The handler gets a synthetic [Context] as [NewContext] creates it for the user's main message;
+// data is available via [Context.Data]. Jobs that were due while the bot was stopped run on start.
+// The handler should be a named function or a handler registered with [Bot.RegisterJobHandler],
+// closures and method values are rejected because their names cannot be restored after restart.
+func (b *Bot) Schedule(userID int64, at time.Time, handler HandlerFunc, data ...string) (string, error) {
+	key := abstract.GetRandomString(16)
+	if err := b.sched.schedule(key, userID, at, "", handler, data); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// ScheduleCron runs the handler for the user by the cron-like spec (see [Cron]) in the local time
+// zone. The key identifies the job: scheduling with an existing key replaces the job, so it is safe
+// to call it on every start, e.g. for daily digests with key "digest:<user id>".
+// The handler is named as in [Bot.Schedule].
+func (b *Bot) ScheduleCron(key string, userID int64, spec string, handler HandlerFunc, data ...string) error {
+	if key == "" {
+		b.bot.log.Error("job key cannot be empty", "spec", spec)
+		b.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return errEmptyJobKey
+	}
+	cron, err := ParseCron(spec)
+	if err != nil {
+		b.bot.log.Error("invalid cron spec", "key", key, "spec", spec, "error", err.Error())
+		b.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return err
+	}
+	next := cron.Next(time.Now())
+	if next.IsZero() {
+		b.bot.log.Error("cron spec never fires", "key", key, "spec", spec)
+		b.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return erro.New("cron spec never fires", "spec", spec)
+	}
+	return b.sched.schedule(key, userID, next, spec, handler, data)
+}
+
+// CancelJob cancels a scheduled job by key. It does nothing if there is no such job.
+func (b *Bot) CancelJob(key string) error {
+	return b.sched.cancel(key)
+}
+
+// RegisterJobHandler registers a handler of scheduled jobs under the name that is stored with its jobs
+// instead of the function name, e.g. "remind", so the function can be renamed between releases.
+// A closure should be registered once, jobs of any closure created by the same function literal run
+// the registered one. [Bot.Schedule] registers named functions itself, but with a persistent
+// [JobStore] you should register every handler before [Bot.Start], so loaded jobs find their handlers.
+// Registering a name again replaces its handler.
+func (b *Bot) RegisterJobHandler(name string, handler HandlerFunc) error {
+	if name == "" {
+		b.bot.log.Error("job handler name cannot be empty")
+		b.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return errEmptyJobHandlerName
+	}
+	if handler == nil {
+		b.bot.log.Error("job handler cannot be nil", "handler", name)
+		b.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return errEmptyJobHandler
+	}
+	if registered, ok := b.sched.names.Lookup(funcPointer(handler)); ok && registered != name {
+		b.bot.log.Error("job handler is already registered under another name", "handler", name, "registered", registered)
+		b.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return errJobHandlerNamed
+	}
+	b.sched.handlers.Set(name, handler)
+	b.sched.names.Set(funcPointer(handler), name)
+	return nil
+}
+
+// scheduler keeps jobs in memory and fires them in a single loop started by [Bot.Start].
+// A job is removed from the store (or moved to its next time) before the handler runs, so a crash
+// in the middle of the handler does not repeat it after restart.
+type scheduler struct {
+	bt       *Bot
+	store    JobStore
+	handlers *abstract.SafeMap[string, HandlerFunc]
+	// names are names of registered handlers by their code pointers.
+	names *abstract.SafeMap[uintptr, string]
+
+	mu   sync.Mutex
+	jobs map[string]ScheduledJob
+	wake chan struct{}
+}
+
+func newScheduler(b *Bot, store JobStore) *scheduler {
+	return &scheduler{
+		bt:       b,
+		store:    lang.If[JobStore](store != nil, store, newInMemoryJobStore()),
+		handlers: abstract.NewSafeMap[string, HandlerFunc](),
+		names:    abstract.NewSafeMap[uintptr, string](),
+		jobs:     make(map[string]ScheduledJob),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+func (s *scheduler) schedule(key string, userID int64, at time.Time, spec string, handler HandlerFunc, data []string) error {
+	name, err := s.handlerName(handler)
+	if err != nil {
+		s.bt.bot.log.Error("invalid job handler", "key", key, "handler", name, "error", err.Error())
+		s.bt.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return err
+	}
+	if userID == 0 {
+		s.bt.bot.log.Error("job user ID cannot be empty", "key", key)
+		s.bt.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return errEmptyUserID
+	}
+
+	fullID, err := s.fullUserID(userID)
+	if err != nil {
+		return erro.Wrap(err, "create user ID")
+	}
+
+	job := ScheduledJob{
+		Key:     key,
+		UserID:  fullID,
+		Handler: name,
+		At:      at,
+		Cron:    spec,
+		Data:    data,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.store.Save(ctx, job); err != nil {
+		return erro.Wrap(err, "save job", "key", key)
+	}
+
+	s.mu.Lock()
+	s.jobs[key] = job
+	s.mu.Unlock()
+	s.notify()
+
+	return nil
+}
+
+// handlerName returns the name the handler is stored with. A named function that is not registered
+// is registered under its full name, e.g. "main.remind".
+func (s *scheduler) handlerName(handler HandlerFunc) (string, error) {
+	if handler == nil {
+		return "", errEmptyJobHandler
+	}
+	ptr := funcPointer(handler)
+	if name, ok := s.names.Lookup(ptr); ok {
+		return name, nil
+	}
+
+	fn := runtime.FuncForPC(ptr)
+	if fn == nil {
+		return "", errEmptyJobHandlerName
+	}
+	name := fn.Name()
+	// Package path may contain dots, the function name starts after the package name
+	local := name[strings.LastIndex(name, "/")+1:]
+	if unstableFuncNameRx.MatchString(local[strings.Index(local, ".")+1:]) {
+		return name, errUnnamedJobHandler
+	}
+
+	s.handlers.Set(name, handler)
+	s.names.Set(ptr, name)
+	return name, nil
+}
+
+func funcPointer(handler HandlerFunc) uintptr {
+	return reflect.ValueOf(handler).Pointer()
+}
+
+func (s *scheduler) cancel(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.store.Delete(ctx, key); err != nil {
+		return erro.Wrap(err, "delete job", "key", key)
+	}
+
+	s.mu.Lock()
+	delete(s.jobs, key)
+	s.mu.Unlock()
+	s.notify()
+
+	return nil
+}
+
+func (s *scheduler) run(ctx context.Context) {
+	jobs, err := s.store.List(ctx)
+	if err != nil {
+		s.bt.bot.log.Error("cannot load scheduled jobs", "error", err.Error())
+		s.bt.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+	}
+	s.mu.Lock()
+	for _, job := range jobs {
+		s.jobs[job.Key] = job
+	}
+	s.mu.Unlock()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C:
+			s.fireDue(ctx, time.Now())
+		}
+
+		timer.Stop()
+		timer.Reset(s.untilNext(time.Now()))
+	}
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) untilNext(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := schedulerIdleInterval
+	for _, job := range s.jobs {
+		if d := job.At.Sub(now); d < next {
+			next = max(d, 0)
+		}
+	}
+	return next
+}
+
+// fireDue runs handlers of all jobs that are due at now.
+func (s *scheduler) fireDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	var due []ScheduledJob
+	for _, job := range s.jobs {
+		if !job.At.After(now) {
+			due = append(due, job)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].At.Before(due[j].At) })
+
+	for _, job := range due {
+		if !s.advance(ctx, job, now) {
+			continue
+		}
+		lang.Go(s.bt.bot.log, func() {
+			s.fire(job)
+		})
+	}
+}
+
+// advance removes a one-time job or moves a recurring job to its next time.
+// It returns false if the job was canceled or replaced after it had been found due.
+func (s *scheduler) advance(ctx context.Context, job ScheduledJob, now time.Time) bool {
+	var next ScheduledJob
+	if job.Cron != "" {
+		cron, err := ParseCron(job.Cron)
+		if err == nil {
+			next = job
+			next.At = cron.Next(now)
+		} else {
+			s.bt.bot.log.Error("invalid cron spec of stored job", "key", job.Key, "spec", job.Cron, "error", err.Error())
+			s.bt.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+		}
+	}
+
+	s.mu.Lock()
+	if current, ok := s.jobs[job.Key]; !ok || !current.At.Equal(job.At) {
+		s.mu.Unlock()
+		return false
+	}
+	if next.At.IsZero() {
+		delete(s.jobs, job.Key)
+	} else {
+		s.jobs[job.Key] = next
+	}
+	s.mu.Unlock()
+
+	var err error
+	if next.At.IsZero() {
+		err = s.store.Delete(ctx, job.Key)
+	} else {
+		err = s.store.Save(ctx, next)
+	}
+	if err != nil {
+		// The job still runs now; it may run once more after restart.
+		s.bt.bot.log.Error("cannot update scheduled job", "key", job.Key, "error", err.Error())
+		s.bt.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+	}
+
+	return true
+}
+
+func (s *scheduler) fire(job ScheduledJob) {
+	handler, ok := s.handlers.Lookup(job.Handler)
+	if !ok {
+		s.bt.bot.log.Error("handler of scheduled job is not registered, register it before start",
+			"key", job.Key, "handler", job.Handler)
+		s.bt.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return
+	}
+
+	userID, err := s.bt.GetUserID(job.UserID)
+	if err != nil {
+		s.bt.bot.log.Error("cannot get user ID of scheduled job", "key", job.Key, "error", err.Error())
+		s.bt.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+		return
+	}
+
+	// Job must not create a deleted user or enable a user that blocked the bot
+	user, found, err := s.bt.um.findUser(userID)
+	if err != nil {
+		s.bt.bot.log.Error("cannot find user of scheduled job", "key", job.Key, "error", err.Error())
+		s.bt.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+		return
+	}
+	if !found || user.IsDisabled() {
+		s.bt.bot.log.Debug("drop scheduled job of missing or disabled user", "key", job.Key, "user_id", job.UserID.String())
+		if job.Cron != "" {
+			if err := s.cancel(job.Key); err != nil {
+				s.bt.bot.log.Error("cannot cancel scheduled job", "key", job.Key, "error", err.Error())
+				s.bt.bot.metr.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+			}
+		}
+		return
+	}
+
+	start := time.Now()
+	s.bt.bot.metr.recordHandlerStart()
+	defer func() {
+		s.bt.bot.metr.recordHandlerFinish()
+		s.bt.bot.metr.observeHandlerDuration(time.Since(start))
+	}()
+
+	ctx := NewContext(s.bt, userID, user.Messages().MainID, job.Data...).(*contextImpl)
+
+	defer lang.RecoverWithErrAndStack(s.bt.bot.log, &err)
+
+	if err = handler(ctx); err != nil {
+		err = ctx.handleError(err)
+	}
+}
+
+func (s *scheduler) fullUserID(userID int64) (FullUserID, error) {
+	if !s.bt.um.priv.IsStrict() {
+		return NewPlainUserID(userID), nil
+	}
+	return NewPrivateUserID(userID, s.bt.um.keysProvider.GetEncryptionKey(), s.bt.um.keysProvider.GetHMACKey())
+}
+
+type inMemoryJobStore struct {
+	jobs *abstract.SafeMap[string, ScheduledJob]
+}
+
+func newInMemoryJobStore() *inMemoryJobStore {
+	return &inMemoryJobStore{jobs: abstract.NewSafeMap[string, ScheduledJob]()}
+}
+
+func (s *inMemoryJobStore) Save(_ context.Context, job ScheduledJob) error {
+	s.jobs.Set(job.Key, job)
+	return nil
+}
+
+func (s *inMemoryJobStore) Delete(_ context.Context, key string) error {
+	s.jobs.Delete(key)
+	return nil
+}
+
+func (s *inMemoryJobStore) List(context.Context) ([]ScheduledJob, error) {
+	out := make([]ScheduledJob, 0, s.jobs.Len())
+	s.jobs.Range(func(_ string, job ScheduledJob) bool {
+		out = append(out, job)
+		return true
+	})
+	return out, nil
+}
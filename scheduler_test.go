@@ -0,0 +1,216 @@
+package bote
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jobCalls collects data of fired jobs.
+var jobCalls = make(chan string, 10)
+
+func remindJob(ctx Context) error {
+	jobCalls <- ctx.Data()
+	return nil
+}
+
+func drainJobCalls() {
+	for {
+		select {
+		case <-jobCalls:
+		default:
+			return
+		}
+	}
+}
+
+// setupJobBot returns a started bot with user 123 and the "remind" job handler.
+func setupJobBot(t *testing.T) *Bot {
+	t.Helper()
+	bot := setupTestBot(t)
+	bot.um.getUser(123)
+	require.NoError(t, bot.RegisterJobHandler("remind", remindJob))
+	return bot
+}
+
+func waitJobCall(t *testing.T) string {
+	t.Helper()
+	select {
+	case data := <-jobCalls:
+		return data
+	case <-time.After(2 * time.Second):
+		t.Fatal("job was not fired")
+		return ""
+	}
+}
+
+// TestScheduleFires verifies a job runs the handler with a synthetic context and is removed after.
+func TestScheduleFires(t *testing.T) {
+	drainJobCalls()
+	bot := setupJobBot(t)
+
+	key, err := bot.Schedule(123, time.Now().Add(50*time.Millisecond), remindJob, "water", "plants")
+	require.NoError(t, err)
+	assert.NotEmpty(t, key)
+
+	assert.Equal(t, "water|plants", waitJobCall(t))
+
+	require.Eventually(t, func() bool {
+		jobs, _ := bot.sched.store.List(context.Background())
+		return len(jobs) == 0
+	}, time.Second, 10*time.Millisecond, "one-time job is deleted from store")
+}
+
+// TestCancelJob verifies a canceled job never fires.
+func TestCancelJob(t *testing.T) {
+	drainJobCalls()
+	bot := setupJobBot(t)
+
+	key, err := bot.Schedule(123, time.Now().Add(100*time.Millisecond), remindJob, "canceled")
+	require.NoError(t, err)
+	require.NoError(t, bot.CancelJob(key))
+
+	select {
+	case data := <-jobCalls:
+		t.Fatalf("canceled job fired with %q", data)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+// TestScheduleCronAdvances verifies a recurring job is moved to its next time instead of being deleted.
+func TestScheduleCronAdvances(t *testing.T) {
+	drainJobCalls()
+	bot := setupJobBot(t)
+
+	require.NoError(t, bot.ScheduleCron("tick", 123, "@every 1s", remindJob, "tick"))
+	require.NoError(t, bot.ScheduleCron("tick", 123, "@every 1s", remindJob, "tick"), "same key replaces the job")
+
+	assert.Equal(t, "tick", waitJobCall(t))
+
+	jobs, err := bot.sched.store.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.True(t, jobs[0].At.After(time.Now()))
+
+	require.NoError(t, bot.CancelJob("tick"))
+}
+
+// TestScheduledJobsLoadedOnStart verifies jobs from the store run after a restart once their
+// handlers are registered.
+func TestScheduledJobsLoadedOnStart(t *testing.T) {
+	drainJobCalls()
+	bot := setupJobBot(t)
+
+	store := newInMemoryJobStore()
+	require.NoError(t, store.Save(context.Background(), ScheduledJob{
+		Key:     "restored",
+		UserID:  NewPlainUserID(123),
+		Handler: "remind",
+		At:      time.Now().Add(-time.Minute),
+		Data:    []string{"restored"},
+	}))
+
+	sched := newScheduler(bot, store)
+	sched.handlers.Set("remind", remindJob)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sched.run(ctx)
+
+	assert.Equal(t, "restored", waitJobCall(t), "overdue job runs at start")
+}
+
+// TestScheduleValidation verifies misuse is rejected.
+func TestScheduleValidation(t *testing.T) {
+	bot := setupJobBot(t)
+
+	assert.ErrorIs(t, bot.RegisterJobHandler("", remindJob), errEmptyJobHandlerName)
+	assert.ErrorIs(t, bot.RegisterJobHandler("empty", nil), errEmptyJobHandler)
+	assert.ErrorIs(t, bot.RegisterJobHandler("other", remindJob), errJobHandlerNamed)
+
+	_, err := bot.Schedule(123, time.Now(), nil)
+	assert.ErrorIs(t, err, errEmptyJobHandler)
+
+	_, err = bot.Schedule(0, time.Now(), remindJob)
+	assert.ErrorIs(t, err, errEmptyUserID)
+
+	assert.ErrorIs(t, bot.ScheduleCron("", 123, "@daily", remindJob), errEmptyJobKey)
+	assert.Error(t, bot.ScheduleCron("bad", 123, "61 * * * *", remindJob))
+	assert.Error(t, bot.ScheduleCron("never", 123, "0 0 31 2 *", remindJob))
+}
+
+func digestJob(ctx Context) error {
+	jobCalls <- "digest:" + ctx.Data()
+	return nil
+}
+
+type jobService struct{}
+
+func (jobService) remind(Context) error { return nil }
+
+// TestScheduleHandlerNames verifies named functions are stored by their names and closures and
+// method values are rejected at schedule time unless they are registered.
+func TestScheduleHandlerNames(t *testing.T) {
+	drainJobCalls()
+	bot := setupJobBot(t)
+
+	_, err := bot.Schedule(123, time.Now().Add(20*time.Millisecond), digestJob, "daily")
+	require.NoError(t, err)
+	assert.Equal(t, "digest:daily", waitJobCall(t))
+	_, ok := bot.sched.handlers.Lookup("github.com/maxbolgarin/bote.digestJob")
+	assert.True(t, ok, "named function is registered under its full name")
+
+	_, err = bot.Schedule(123, time.Now(), func(Context) error { return nil })
+	assert.ErrorIs(t, err, errUnnamedJobHandler)
+	_, err = bot.Schedule(123, time.Now(), jobService{}.remind)
+	assert.ErrorIs(t, err, errUnnamedJobHandler)
+
+	var closures []HandlerFunc
+	for _, name := range []string{"first", "second"} {
+		closures = append(closures, func(Context) error {
+			jobCalls <- name
+			return nil
+		})
+	}
+	require.NoError(t, bot.RegisterJobHandler("first", closures[0]))
+	assert.ErrorIs(t, bot.RegisterJobHandler("second", closures[1]), errJobHandlerNamed,
+		"closures of the same literal cannot be told apart")
+
+	_, err = bot.Schedule(123, time.Now().Add(20*time.Millisecond), closures[0])
+	require.NoError(t, err)
+	assert.Equal(t, "first", waitJobCall(t))
+}
+
+// TestScheduledJobUnknownUser verifies a job does not create a missing user or enable a disabled one.
+func TestScheduledJobUnknownUser(t *testing.T) {
+	drainJobCalls()
+	bot := setupJobBot(t)
+
+	bot.um.getUser(456)
+	bot.um.disableUser(456)
+
+	require.NoError(t, bot.ScheduleCron("missing", 789, "@every 1s", remindJob, "missing"))
+	require.NoError(t, bot.ScheduleCron("disabled", 456, "@every 1s", remindJob, "disabled"))
+
+	select {
+	case data := <-jobCalls:
+		t.Fatalf("job of missing or disabled user fired with %q", data)
+	case <-time.After(1500 * time.Millisecond):
+	}
+
+	_, found, err := bot.um.findUser(789)
+	require.NoError(t, err)
+	assert.False(t, found, "missing user is not created")
+
+	user, found, err := bot.um.findUser(456)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.True(t, user.IsDisabled(), "disabled user is not enabled")
+
+	jobs, err := bot.sched.store.List(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, jobs, "recurring jobs of missing and disabled users are dropped")
+}
@@ -0,0 +1,151 @@
+package bote
+
+import (
+	"sync"
+	"time"
+
+	"github.com/maxbolgarin/lang"
+	tele "github.com/maxbolgarin/telebot/v4"
+)
+
+// SerialDropPolicy is a policy of dropping updates when a queue of a user is full.
+type SerialDropPolicy string
+
+const (
+	// SerialDropNewest drops an incoming update, the user keeps getting answers to earlier actions.
+	SerialDropNewest SerialDropPolicy = "newest"
+	// SerialDropOldest drops the oldest waiting update, the latest actions of the user are handled.
+	SerialDropOldest SerialDropPolicy = "oldest"
+)
+
+// serialDispatcher runs updates of a single user strictly one after another in the order they came,
+// while updates of different users are handled in parallel. Every user with waiting updates has
+// its own goroutine, it exits when the queue of the user is empty.
+type serialDispatcher struct {
+	bot    *baseBot
+	handle func(tele.Update)
+	size   int
+	policy SerialDropPolicy
+
+	mu     sync.Mutex
+	queues map[int64][]serialUpdate
+	queued int
+}
+
+type serialUpdate struct {
+	upd      tele.Update
+	queuedAt time.Time
+}
+
+func newSerialDispatcher(bot *baseBot, cfg SerialConfig) *serialDispatcher {
+	d := &serialDispatcher{
+		bot: bot,
+		// Telebot is synchronous in serial mode, so handlers of the update run in the goroutine of the queue.
+		handle: bot.processUpdate,
+		size:   cfg.QueueSize,
+		policy: cfg.DropPolicy,
+		queues: make(map[int64][]serialUpdate),
+	}
+	if bot.pool != nil {
+		// The queue of the user waits for a worker, so the pool limits handlers of all users.
+		d.handle = bot.pool.wait
+	}
+	return d
+}
+
+// middleware passes the update through bot middlewares and puts it to the queue of the user.
+// It always returns false: the update is processed by the queue, not by the poller.
+func (d *serialDispatcher) middleware(upd *tele.Update) bool {
+	if !d.bot.middleware(upd) {
+		d.bot.handled(upd)
+		return false
+	}
+	d.enqueue(*upd)
+	return false
+}
+
+func (d *serialDispatcher) enqueue(upd tele.Update) {
+	key := serialKey(&upd)
+	item := serialUpdate{upd: upd, queuedAt: time.Now()}
+
+	d.mu.Lock()
+	queue, running := d.queues[key]
+
+	var dropped *tele.Update
+	if len(queue) >= d.size {
+		if d.policy == SerialDropOldest {
+			oldest := queue[0].upd
+			dropped = &oldest
+			queue = append(queue[1:], item)
+		} else {
+			dropped = &upd
+		}
+	} else {
+		queue = append(queue, item)
+		d.queued++
+	}
+	d.queues[key] = queue
+	d.bot.metr.setUserQueueDepth(d.queued)
+	d.mu.Unlock()
+
+	if dropped != nil {
+		d.drop(key, dropped)
+	}
+	if !running {
+		lang.Go(d.bot.log, func() { d.run(key) })
+	}
+}
+
+// run handles updates of the user until the queue is empty.
+func (d *serialDispatcher) run(key int64) {
+	for {
+		d.mu.Lock()
+		queue := d.queues[key]
+		if len(queue) == 0 {
+			delete(d.queues, key)
+			d.mu.Unlock()
+			return
+		}
+		item := queue[0]
+		d.queues[key] = queue[1:]
+		d.queued--
+		d.bot.metr.setUserQueueDepth(d.queued)
+		d.mu.Unlock()
+
+		d.bot.metr.observeUserQueueWait(time.Since(item.queuedAt))
+		d.process(item.upd)
+	}
+}
+
+func (d *serialDispatcher) process(upd tele.Update) {
+	defer lang.Recover(d.bot.log)
+	d.handle(upd)
+}
+
+// drop answers a dropped callback, so the button of the user does not hang with a loading spinner.
+func (d *serialDispatcher) drop(key int64, upd *tele.Update) {
+	d.bot.log.Warn("user queue is full, update is dropped",
+		"user_id", prepareUserID(key, d.bot.priv),
+		"update_id", upd.ID,
+		"policy", string(d.policy),
+	)
+	d.bot.metr.incUserQueueDropped()
+	d.bot.handled(upd)
+
+	if cb := upd.Callback; cb != nil {
+		lang.Go(d.bot.log, func() {
+			if err := d.bot.tbot.Respond(cb, &tele.CallbackResponse{}); err != nil {
+				d.bot.log.Debug("failed to respond to dropped callback", "error", err.Error())
+			}
+		})
+	}
+}
+
+// serialKey returns an ID of the user who sent the update or an ID of the chat if there is no sender.
+func serialKey(upd *tele.Update) int64 {
+	if sender := getSender(upd); sender != nil {
+		return sender.ID
+	}
+	chatID, _, _ := getChatID(upd)
+	return chatID
+}
@@ -0,0 +1,107 @@
+package bote
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serialTestUpdate(id int, userID int64) tele.Update {
+	return tele.Update{ID: id, Message: &tele.Message{
+		Sender: &tele.User{ID: userID},
+		Chat:   &tele.Chat{ID: userID, Type: tele.ChatPrivate},
+	}}
+}
+
+type serialRecorder struct {
+	mu      sync.Mutex
+	handled map[int64][]int
+	done    chan int
+}
+
+func newSerialDispatcherForTest(t *testing.T, size int, policy SerialDropPolicy, block chan struct{}) (*serialDispatcher, *serialRecorder) {
+	bot := setupTestBot(t)
+	rec := &serialRecorder{handled: make(map[int64][]int), done: make(chan int, 100)}
+	d := newSerialDispatcher(bot.bot, SerialConfig{QueueSize: size, DropPolicy: policy})
+	d.handle = func(upd tele.Update) {
+		if upd.Message.Sender.ID == 1 {
+			<-block
+		}
+		rec.mu.Lock()
+		rec.handled[upd.Message.Sender.ID] = append(rec.handled[upd.Message.Sender.ID], upd.ID)
+		rec.mu.Unlock()
+		rec.done <- upd.ID
+	}
+	return d, rec
+}
+
+func (r *serialRecorder) wait(t *testing.T, n int) {
+	for range n {
+		select {
+		case <-r.done:
+		case <-time.After(time.Second):
+			t.Fatal("update is not handled")
+		}
+	}
+}
+
+// TestSerialDispatcherOrder verifies updates of a user are handled in order while other users are not blocked.
+func TestSerialDispatcherOrder(t *testing.T) {
+	block := make(chan struct{})
+	d, rec := newSerialDispatcherForTest(t, 10, SerialDropNewest, block)
+
+	for i := 1; i <= 5; i++ {
+		d.enqueue(serialTestUpdate(i, 1))
+	}
+	d.enqueue(serialTestUpdate(100, 2))
+	rec.wait(t, 1)
+
+	rec.mu.Lock()
+	assert.Equal(t, []int{100}, rec.handled[2], "other user is handled while the first one is busy")
+	assert.Empty(t, rec.handled[1])
+	rec.mu.Unlock()
+
+	close(block)
+	rec.wait(t, 5)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, rec.handled[1])
+}
+
+// TestSerialDispatcherDropPolicy verifies overflowing updates are dropped according to the policy.
+func TestSerialDispatcherDropPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy SerialDropPolicy
+		want   []int
+	}{
+		{SerialDropNewest, []int{1, 2, 3}},
+		{SerialDropOldest, []int{1, 4, 5}},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			block := make(chan struct{})
+			d, rec := newSerialDispatcherForTest(t, 2, tc.policy, block)
+
+			d.enqueue(serialTestUpdate(1, 1))
+			require.Eventually(t, func() bool {
+				d.mu.Lock()
+				defer d.mu.Unlock()
+				return len(d.queues[1]) == 0
+			}, time.Second, time.Millisecond, "first update is taken by the worker")
+
+			for i := 2; i <= 5; i++ {
+				d.enqueue(serialTestUpdate(i, 1))
+			}
+			close(block)
+			rec.wait(t, 3)
+
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			assert.Equal(t, tc.want, rec.handled[1])
+		})
+	}
+}
@@ -30,6 +30,7 @@ import (
 //	msgID, _ := bot.SendInChat(adminChatID, 0, draft, kb)
 
 // RegisterButton registers a stateless handler for an inline button action identified by name.
+// The name is a route of the button: use a stable id and [Bot.NewButtonID] for localized buttons.
 // It should be called once per action (e.g. at startup). Every button created with
 // [Bot.NewButton] using the same name routes to this handler when tapped, no matter the chat
 // type or whether the tapper is a tracked user. The per-message payload is delivered to the
@@ -49,22 +50,12 @@ func (b *Bot) RegisterButton(name string, handler HandlerFunc) {
 //
 // Unlike [Context.Btn], NewButton needs no [Context] or tracked user, so it is suitable for
 // channel/admin messages. Build a fresh button per message with the payload identifying that
-// message's subject (e.g. a database row id). Overlong payloads are truncated at a rune
-// boundary to stay within Telegram's 64-byte callback-data limit.
+// message's subject (e.g. a database row id). Overlong payloads are kept in [CallbackDataStore]
+// if it is set, otherwise they are truncated at a rune boundary to stay within Telegram's
+// 64-byte callback-data limit.
 func (b *Bot) NewButton(name string, data ...string) tele.Btn {
-	_, unique := getBtnIDAndUnique(name)
-	payload := CreateBtnData(data...)
-	maxDataLength := MaxDataLengthBytes - len(unique) - 2
-	if len(payload) > maxDataLength {
-		b.bot.log.Warn("button data length exceeds limit, it will be truncated", "length", len(payload))
-		runes := []rune(payload)
-		for i := len(runes); i > 0; i-- {
-			if truncated := string(runes[:i]); len(truncated) <= maxDataLength {
-				payload = truncated
-				break
-			}
-		}
-	}
+	id, unique := getBtnIDAndUnique(name)
+	payload := b.buttonData(id, MaxDataLengthBytes-len(unique)-2, data...)
 	return tele.Btn{
 		Text:   name,
 		Unique: unique,
@@ -72,6 +63,15 @@ func (b *Bot) NewButton(name string, data ...string) tele.Btn {
 	}
 }
 
+// NewButtonID builds an inline button for an action registered with [Bot.RegisterButton] under id.
+// The text of the button is resolved for the language with [ButtonMessages], falling back to the id,
+// so the same action can be rendered in any language and is still routed by id.
+func (b *Bot) NewButtonID(id string, language Language, data ...string) tele.Btn {
+	btn := b.NewButton(id, data...)
+	btn.Text = b.buttonText(language, id)
+	return btn
+}
+
 // AddURL adds an inline URL button to the current row. URL buttons open a link and produce no
 // callback, so they need no handler — handy for "View source" / deep-link CTAs in channel posts.
 func (k *Keyboard) AddURL(text, url string) *Keyboard {
@@ -124,3 +124,20 @@ func TestKeyboardAddURL(t *testing.T) {
 	}
 	assert.ElementsMatch(t, []string{"https://doi.org/10.1/abc", "https://app.example.io"}, urls)
 }
+
+// TestNewButtonIDDispatchesByID verifies a localized button built with NewButtonID is routed by its id.
+func TestNewButtonIDDispatchesByID(t *testing.T) {
+	bot := setupTestBot(t)
+	bot.SetMessageProvider(buttonTestProvider{})
+
+	var gotData string
+	bot.RegisterButton("add_task", func(ctx Context) error {
+		gotData = ctx.Data()
+		return nil
+	})
+
+	btn := bot.NewButtonID("add_task", LanguageRussian, "5")
+	assert.Equal(t, "Добавить задачу", btn.Text)
+	require.NoError(t, dispatchCallback(bot, btn, tele.ChatChannel))
+	assert.Equal(t, "5", gotData)
+}
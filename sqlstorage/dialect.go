@@ -0,0 +1,90 @@
+package sqlstorage
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect describes SQL differences between supported databases.
+type Dialect interface {
+	// Name returns a name of the dialect for logs and errors.
+	Name() string
+	// Placeholder returns a placeholder of the n-th query argument, starting from 1.
+	Placeholder(n int) string
+	// Type returns a column type of the database for a generic column kind.
+	Type(kind ColumnKind) string
+}
+
+// ColumnKind is a generic kind of a column that is mapped to a database type by [Dialect].
+type ColumnKind string
+
+const (
+	KindInt  ColumnKind = "int"
+	KindText ColumnKind = "text"
+	KindBool ColumnKind = "bool"
+	KindTime ColumnKind = "time"
+	KindJSON ColumnKind = "json"
+)
+
+var (
+	// Postgres is a dialect for PostgreSQL (lib/pq, pgx stdlib).
+	Postgres Dialect = postgresDialect{}
+	// SQLite is a dialect for SQLite (mattn/go-sqlite3, modernc.org/sqlite).
+	SQLite Dialect = sqliteDialect{}
+)
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return "postgres" }
+
+func (postgresDialect) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+func (postgresDialect) Type(kind ColumnKind) string {
+	switch kind {
+	case KindInt:
+		return "BIGINT"
+	case KindBool:
+		return "BOOLEAN"
+	case KindTime:
+		return "TIMESTAMPTZ"
+	case KindJSON:
+		return "JSONB"
+	default:
+		return "TEXT"
+	}
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite" }
+
+func (sqliteDialect) Placeholder(int) string { return "?" }
+
+func (sqliteDialect) Type(kind ColumnKind) string {
+	switch kind {
+	case KindInt, KindBool:
+		return "INTEGER"
+	case KindTime:
+		return "TIMESTAMP"
+	default:
+		return "TEXT"
+	}
+}
+
+// quote quotes an identifier. Both PostgreSQL and SQLite accept double quotes,
+// which also protects column names that are keywords.
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// placeholders returns a comma separated list of count placeholders starting from start.
+func placeholders(d Dialect, start, count int) string {
+	var b strings.Builder
+	for i := range count {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(d.Placeholder(start + i))
+	}
+	return b.String()
+}
@@ -0,0 +1,173 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxbolgarin/bote"
+	"github.com/maxbolgarin/erro"
+	"github.com/maxbolgarin/lang"
+)
+
+const (
+	defaultInboxTable    = "bote_inbox"
+	defaultInboxDedupTTL = 24 * time.Hour
+	inboxSweepInterval   = time.Hour
+)
+
+const (
+	colUpdateID   = "update_id"
+	colData       = "data"
+	colReceivedAt = "received_at"
+	colDoneAt     = "done_at"
+)
+
+// inboxColumns is the order of columns in inserts and selects of the inbox table.
+var inboxColumns = []column{
+	{name: colUpdateID, kind: KindInt, notNull: true, unique: true},
+	{name: colData, kind: KindText, notNull: true},
+	{name: colReceivedAt, kind: KindTime, notNull: true},
+	{name: colDoneAt, kind: KindTime},
+}
+
+var inboxMigrations = []migration{
+	createInboxTable,
+}
+
+// createInboxTable creates the table of webhook updates with a unique index on update ID, which
+// deduplicates updates retried by Telegram, and an index on the time the update was handled.
+func createInboxTable(d Dialect, table string) []string {
+	var defs []string
+	for _, c := range inboxColumns {
+		defs = append(defs, columnDef(d, c))
+	}
+	return []string{
+		"CREATE TABLE IF NOT EXISTS " + quote(table) + " (\n\t" + strings.Join(defs, ",\n\t") + "\n)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS " + quote(table+"_"+colUpdateID+"_idx") + " ON " + quote(table) + " (" + quote(colUpdateID) + ")",
+		"CREATE INDEX IF NOT EXISTS " + quote(table+"_"+colDoneAt+"_idx") + " ON " + quote(table) + " (" + quote(colDoneAt) + ")",
+	}
+}
+
+// InboxStore is a [bote.InboxStore] backed by a SQL database, pass it to [bote.WithWebhookInbox].
+// A pending update has no done time. Handled updates are kept for deduplication and removed
+// by Done at most once an hour after the deduplication window ends.
+type InboxStore struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+	ttl     time.Duration
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+var _ bote.InboxStore = (*InboxStore)(nil)
+
+// NewInboxStore creates an inbox store and migrates its schema to the latest version.
+// Its table is "bote_inbox" by default, use [WithTable] and [WithDedupTTL] to change it.
+func NewInboxStore(ctx context.Context, db *sql.DB, dialect Dialect, optsFuncs ...func(*Options)) (*InboxStore, error) {
+	if db == nil {
+		return nil, erro.New("db cannot be nil")
+	}
+	if dialect == nil {
+		return nil, erro.New("dialect cannot be nil")
+	}
+
+	var opts Options
+	for _, f := range optsFuncs {
+		f(&opts)
+	}
+	opts.Table = lang.Check(opts.Table, defaultInboxTable)
+	opts.DedupTTL = lang.Check(opts.DedupTTL, defaultInboxDedupTTL)
+	if !tableNameRx.MatchString(opts.Table) {
+		return nil, erro.New("invalid table name", "table", opts.Table)
+	}
+	if opts.DedupTTL < 0 {
+		return nil, erro.New("dedup TTL cannot be negative", "dedup_ttl", opts.DedupTTL)
+	}
+
+	s := &InboxStore{
+		db:      db,
+		dialect: dialect,
+		table:   opts.Table,
+		ttl:     opts.DedupTTL,
+	}
+	sc := schema{db: db, dialect: dialect, table: s.table, migrations: inboxMigrations}
+	if err := sc.migrate(ctx, len(inboxMigrations)); err != nil {
+		return nil, erro.Wrap(err, "migrate")
+	}
+	return s, nil
+}
+
+// Put inserts the update. A conflict on its ID means it is pending or it was handled within the deduplication window.
+func (s *InboxStore) Put(ctx context.Context, upd bote.InboxUpdate) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "INSERT INTO "+quote(s.table)+" ("+quote(colUpdateID)+", "+quote(colData)+", "+
+		quote(colReceivedAt)+") VALUES ("+placeholders(s.dialect, 1, 3)+") ON CONFLICT DO NOTHING",
+		upd.ID, string(upd.Data), upd.Received.UTC())
+	if err != nil {
+		return false, erro.Wrap(err, "insert update", "update_id", upd.ID)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, erro.Wrap(err, "get rows affected", "update_id", upd.ID)
+	}
+	return n > 0, nil
+}
+
+// Done sets the time the update was handled and removes updates handled before the deduplication window.
+func (s *InboxStore) Done(ctx context.Context, updateID int) error {
+	now := time.Now().UTC()
+	_, err := s.db.ExecContext(ctx, "UPDATE "+quote(s.table)+" SET "+quote(colDoneAt)+" = "+s.dialect.Placeholder(1)+
+		" WHERE "+quote(colUpdateID)+" = "+s.dialect.Placeholder(2), now, updateID)
+	if err != nil {
+		return erro.Wrap(err, "mark update as handled", "update_id", updateID)
+	}
+	return s.sweep(ctx, now)
+}
+
+// Pending returns updates that are saved but not handled, ordered by ID.
+func (s *InboxStore) Pending(ctx context.Context) ([]bote.InboxUpdate, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+quote(colUpdateID)+", "+quote(colData)+", "+quote(colReceivedAt)+
+		" FROM "+quote(s.table)+" WHERE "+quote(colDoneAt)+" IS NULL ORDER BY "+quote(colUpdateID))
+	if err != nil {
+		return nil, erro.Wrap(err, "select pending updates")
+	}
+	defer rows.Close()
+
+	var out []bote.InboxUpdate
+	for rows.Next() {
+		var (
+			upd  bote.InboxUpdate
+			data string
+		)
+		if err := rows.Scan(&upd.ID, &data, &upd.Received); err != nil {
+			return nil, erro.Wrap(err, "scan update")
+		}
+		upd.Data = []byte(data)
+		out = append(out, upd)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, erro.Wrap(err, "read pending updates")
+	}
+	return out, nil
+}
+
+// sweep removes updates handled before the deduplication window, at most once per sweep interval.
+func (s *InboxStore) sweep(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < inboxSweepInterval {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM "+quote(s.table)+" WHERE "+quote(colDoneAt)+" < "+s.dialect.Placeholder(1), now.Add(-s.ttl))
+	if err != nil {
+		return erro.Wrap(err, "remove handled updates")
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/maxbolgarin/erro"
+)
+
+// migration is a single schema change. Migrations are applied in order and never edited after a
+// release: a schema change is a new migration appended to the list.
+type migration func(d Dialect, table string) []string
+
+var migrations = []migration{
+	createUsersTable,
+	addMessagesExpiry,
+}
+
+// createUsersTable creates the users table with one column per field of [bote.UserModel] and unique
+// indexes on plain and HMAC user IDs, which are used for lookups depending on the privacy mode.
+func createUsersTable(d Dialect, table string) []string {
+	var defs []string
+	for _, c := range userColumns {
+		if c.since != 0 {
+			continue
+		}
+		defs = append(defs, columnDef(d, c))
+	}
+
+	out := []string{"CREATE TABLE IF NOT EXISTS " + quote(table) + " (\n\t" + strings.Join(defs, ",\n\t") + "\n)"}
+	for _, c := range userColumns {
+		if c.unique && c.since == 0 {
+			out = append(out, "CREATE UNIQUE INDEX IF NOT EXISTS "+quote(table+"_"+c.name+"_idx")+
+				" ON "+quote(table)+" ("+quote(c.name)+")")
+		}
+	}
+	return out
+}
+
+// addMessagesExpiry adds deadlines of automatic deletion of notification and error messages.
+func addMessagesExpiry(d Dialect, table string) []string {
+	return addColumns(d, table, 1)
+}
+
+// addColumns returns statements that add columns introduced by the migration with the index.
+func addColumns(d Dialect, table string, since int) []string {
+	var out []string
+	for _, c := range userColumns {
+		if c.since == since {
+			out = append(out, "ALTER TABLE "+quote(table)+" ADD COLUMN "+columnDef(d, c))
+		}
+	}
+	return out
+}
+
+func columnDef(d Dialect, c column) string {
+	def := quote(c.name) + " " + d.Type(c.kind)
+	if c.notNull {
+		def += " NOT NULL"
+	}
+	return def
+}
+
+// Migrate creates the schema or upgrades it to the latest version. It is called by [New].
+// Applied versions are stored in "<table>_migrations". Pending migrations run in one transaction
+// that first locks the row of version 0 in this table, so instances started at once apply them once:
+// PostgreSQL makes the others wait for the lock. SQLite does it if busy_timeout is set and transactions
+// begin immediately (e.g. "file:bot.db?_pragma=busy_timeout(5000)&_txlock=immediate" for modernc.org/sqlite),
+// otherwise the other instances fail with SQLITE_BUSY.
+func (s *Storage) Migrate(ctx context.Context) error {
+	return s.migrate(ctx, len(migrations))
+}
+
+// migrate applies migrations of the users table up to the target version.
+func (s *Storage) migrate(ctx context.Context, target int) error {
+	return schema{db: s.db, dialect: s.dialect, table: s.table, migrations: migrations}.migrate(ctx, target)
+}
+
+// schema is a table with its own list of migrations, applied versions are stored in "<table>_migrations".
+type schema struct {
+	db         *sql.DB
+	dialect    Dialect
+	table      string
+	migrations []migration
+}
+
+// migrate applies migrations up to the target version.
+func (s schema) migrate(ctx context.Context, target int) (err error) {
+	versions := quote(s.table + "_migrations")
+	_, err = s.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+versions+" ("+
+		quote("version")+" "+s.dialect.Type(KindInt)+" PRIMARY KEY, "+
+		quote("applied_at")+" "+s.dialect.Type(KindTime)+" NOT NULL)")
+	if err != nil {
+		return erro.Wrap(err, "create migrations table")
+	}
+	_, err = s.db.ExecContext(ctx, "INSERT INTO "+versions+" ("+quote("version")+", "+quote("applied_at")+") VALUES ("+
+		placeholders(s.dialect, 1, 2)+") ON CONFLICT DO NOTHING", 0, time.Now().UTC())
+	if err != nil {
+		return erro.Wrap(err, "create migrations lock")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return erro.Wrap(err, "begin")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// Another instance that migrates the schema holds the lock until it commits
+	_, err = tx.ExecContext(ctx, "UPDATE "+versions+" SET "+quote("applied_at")+" = "+s.dialect.Placeholder(1)+
+		" WHERE "+quote("version")+" = 0", time.Now().UTC())
+	if err != nil {
+		return erro.Wrap(err, "lock migrations")
+	}
+
+	current, err := schemaVersion(ctx, tx, versions)
+	if err != nil {
+		return erro.Wrap(err, "get schema version")
+	}
+
+	for i := current; i < target; i++ {
+		version := i + 1
+		if err = s.applyMigration(ctx, tx, version, s.migrations[i](s.dialect, s.table)); err != nil {
+			return erro.Wrap(err, "apply migration", "version", version, "dialect", s.dialect.Name())
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return erro.Wrap(err, "commit")
+	}
+	return nil
+}
+
+func schemaVersion(ctx context.Context, tx *sql.Tx, versions string) (int, error) {
+	var version sql.NullInt64
+	err := tx.QueryRowContext(ctx, "SELECT MAX("+quote("version")+") FROM "+versions).Scan(&version)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+func (s schema) applyMigration(ctx context.Context, tx *sql.Tx, version int, statements []string) error {
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return erro.Wrap(err, "exec", "statement", stmt)
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, "INSERT INTO "+quote(s.table+"_migrations")+" ("+quote("version")+", "+quote("applied_at")+") VALUES ("+
+		placeholders(s.dialect, 1, 2)+")", version, time.Now().UTC())
+	if err != nil {
+		return erro.Wrap(err, "save version")
+	}
+	return nil
+}
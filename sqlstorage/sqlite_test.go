@@ -0,0 +1,247 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maxbolgarin/bote"
+	"github.com/maxbolgarin/lang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+// openSQLite opens a database in a file: every connection to ":memory:" gets its own empty database.
+// Transactions begin immediately and wait for locks, as Migrate expects.
+func openSQLite(t *testing.T) *sql.DB {
+	path := filepath.Join(t.TempDir(), "bot.db")
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_txlock=immediate")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// utcTimes returns the user with times in UTC, the driver may return them in another location.
+func utcTimes(u bote.UserModel) bote.UserModel {
+	u.Messages.NotificationExpiresAt = u.Messages.NotificationExpiresAt.UTC()
+	u.Messages.ErrorExpiresAt = u.Messages.ErrorExpiresAt.UTC()
+	u.Stats.LastSeenTime = u.Stats.LastSeenTime.UTC()
+	u.Stats.CreatedTime = u.Stats.CreatedTime.UTC()
+	u.Stats.DisabledTime = u.Stats.DisabledTime.UTC()
+	for k, v := range u.Messages.LastActions {
+		u.Messages.LastActions[k] = v.UTC()
+	}
+	return u
+}
+
+func versions(t *testing.T, db *sql.DB, table string) []int {
+	rows, err := db.Query(`SELECT "version" FROM "` + table + `_migrations" WHERE "version" > 0 ORDER BY "version"`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var out []int
+	for rows.Next() {
+		var v int
+		require.NoError(t, rows.Scan(&v))
+		out = append(out, v)
+	}
+	require.NoError(t, rows.Err())
+	return out
+}
+
+// TestSQLiteStorage verifies the storage against a real SQLite database.
+func TestSQLiteStorage(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	s, err := New(ctx, db, SQLite)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, versions(t, db, defaultTable))
+
+	// Migrations are not applied twice
+	_, err = New(ctx, db, SQLite)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, versions(t, db, defaultTable))
+
+	user := testUser()
+	user.ID = bote.NewPlainUserID(42)
+	user.Values = map[string]any{"name": "Alice", "count": 3, "chat_id": int64(1) << 60}
+	require.NoError(t, s.Insert(ctx, user))
+	assert.Error(t, s.Insert(ctx, user), "plain ID is unique")
+
+	other := testUser() // HMAC ID only, as in strict privacy mode
+	require.NoError(t, s.Insert(ctx, other))
+
+	got, found, err := s.Find(ctx, bote.NewPlainUserID(42))
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, map[string]any{
+		"name":    "Alice",
+		"count":   json.Number("3"),
+		"chat_id": json.Number("1152921504606846976"),
+	}, got.Values)
+	got.Values, user.Values = nil, nil
+	assert.Equal(t, user, utcTimes(got))
+
+	got, found, err = s.Find(ctx, bote.FullUserID{IDHMAC: other.ID.IDHMAC})
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, other, utcTimes(got))
+
+	_, found, err = s.Find(ctx, bote.NewPlainUserID(7))
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	s.UpdateAsync(bote.NewPlainUserID(42), &bote.UserModelDiff{
+		Messages:   &bote.UserMessagesDiff{MainID: lang.Ptr(99), NotificationExpiresAt: &time.Time{}},
+		State:      &bote.UserStateDiff{Main: lang.Ptr(bote.UserState("settings"))},
+		IsDisabled: lang.Ptr(true),
+	})
+	got, _, err = s.Find(ctx, bote.NewPlainUserID(42))
+	require.NoError(t, err)
+	assert.Equal(t, 99, got.Messages.MainID)
+	assert.Equal(t, user.Messages.HeadID, got.Messages.HeadID, "other columns are not changed")
+	assert.True(t, got.Messages.NotificationExpiresAt.IsZero())
+	assert.Equal(t, bote.UserState("settings"), got.State.Main)
+	assert.True(t, got.IsDisabled)
+
+	all, err := s.FindAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	require.NoError(t, s.Delete(ctx, bote.NewPlainUserID(42)))
+	_, found, err = s.Find(ctx, bote.NewPlainUserID(42))
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	ns, err := s.Namespace(ctx, "shop")
+	require.NoError(t, err)
+	all, err = ns.FindAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+	assert.Equal(t, []int{1, 2}, versions(t, db, defaultTable+"_shop"))
+}
+
+// TestSQLiteUpgrade verifies a database of the first schema version is upgraded and keeps its users.
+func TestSQLiteUpgrade(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	old := &Storage{db: db, dialect: SQLite, table: "users", timeout: time.Second, log: noopLogger{}}
+	require.NoError(t, old.migrate(ctx, 1))
+	assert.Equal(t, []int{1}, versions(t, db, "users"))
+
+	// Insert a user the way the first version did: without columns of later migrations
+	user := testUser()
+	user.ID = bote.NewPlainUserID(5)
+	user.Messages.NotificationExpiresAt = time.Time{}
+	values, err := modelValues(user)
+	require.NoError(t, err)
+	var (
+		names []string
+		args  []any
+	)
+	for i, c := range userColumns {
+		if c.since == 0 {
+			names = append(names, quote(c.name))
+			args = append(args, values[i])
+		}
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO "users" (`+strings.Join(names, ", ")+`) VALUES (`+
+		placeholders(SQLite, 1, len(names))+`)`, args...)
+	require.NoError(t, err)
+
+	s, err := New(ctx, db, SQLite, WithTable("users"))
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, versions(t, db, "users"))
+
+	got, found, err := s.Find(ctx, bote.NewPlainUserID(5))
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, user, utcTimes(got))
+
+	expires := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	s.UpdateAsync(bote.NewPlainUserID(5), &bote.UserModelDiff{Messages: &bote.UserMessagesDiff{ErrorExpiresAt: &expires}})
+	got, _, err = s.Find(ctx, bote.NewPlainUserID(5))
+	require.NoError(t, err)
+	assert.True(t, expires.Equal(got.Messages.ErrorExpiresAt))
+}
+
+// TestSQLiteConcurrentMigrate verifies instances started at once apply every migration once.
+func TestSQLiteConcurrentMigrate(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = New(ctx, db, SQLite)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []int{1, 2}, versions(t, db, defaultTable))
+}
+
+// TestSQLiteInboxStore verifies webhook updates are deduplicated, kept until handled and removed after the window.
+func TestSQLiteInboxStore(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	s, err := NewInboxStore(ctx, db, SQLite, WithDedupTTL(time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, versions(t, db, defaultInboxTable))
+
+	received := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, id := range []int{2, 1} {
+		ok, err := s.Put(ctx, bote.InboxUpdate{ID: id, Data: []byte(`{"update_id":1}`), Received: received})
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+	ok, err := s.Put(ctx, bote.InboxUpdate{ID: 1, Data: []byte(`{}`), Received: received})
+	require.NoError(t, err)
+	assert.False(t, ok, "pending duplicate is ignored")
+
+	pending, err := s.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, 1, pending[0].ID)
+	assert.Equal(t, `{"update_id":1}`, string(pending[0].Data))
+	assert.True(t, received.Equal(pending[0].Received))
+	assert.Equal(t, 2, pending[1].ID)
+
+	require.NoError(t, s.Done(ctx, 1))
+	pending, err = s.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 2, pending[0].ID)
+
+	ok, err = s.Put(ctx, bote.InboxUpdate{ID: 1, Data: []byte(`{}`), Received: received})
+	require.NoError(t, err)
+	assert.False(t, ok, "handled duplicate is ignored")
+
+	// The window of update 1 is over, it is removed when the next update is handled
+	time.Sleep(10 * time.Millisecond)
+	s.lastSweep = time.Time{}
+	require.NoError(t, s.Done(ctx, 2))
+	ok, err = s.Put(ctx, bote.InboxUpdate{ID: 1, Data: []byte(`{}`), Received: received})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// A restarted instance finds the schema migrated
+	_, err = NewInboxStore(ctx, db, SQLite, WithDedupTTL(time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, versions(t, db, defaultInboxTable))
+}
@@ -0,0 +1,662 @@
+// Package sqlstorage implements [bote.UsersStorage] and [bote.InboxStore] on top of database/sql.
+//
+// It supports PostgreSQL and SQLite, creates and migrates its own schema and applies
+// [bote.UserModelDiff] as partial column updates: every field of the diff is stored in its own
+// column, so an update touches only the columns that actually changed.
+//
+// User values are stored as JSON. Numbers are read back as [json.Number], not as the type they were
+// saved with, so read them with Int64 or Float64.
+//
+// The package does not import a database driver, register the one you use in your application:
+//
+//	db, err := sql.Open("sqlite", "file:bot.db?_pragma=busy_timeout(5000)&_txlock=immediate") // import _ "modernc.org/sqlite"
+//	storage, err := sqlstorage.New(ctx, db, sqlstorage.SQLite)
+//	b, err := bote.New(ctx, token, bote.WithUserDB(storage))
+//
+// [InboxStore] keeps webhook updates of [bote.WithWebhookInbox] in the same database:
+//
+//	inbox, err := sqlstorage.NewInboxStore(ctx, db, sqlstorage.SQLite)
+//	b, err := bote.New(ctx, token, bote.WithWebhook(url), bote.WithWebhookInbox(inbox))
+package sqlstorage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/maxbolgarin/bote"
+	"github.com/maxbolgarin/erro"
+	"github.com/maxbolgarin/lang"
+)
+
+const (
+	defaultTable   = "bote_users"
+	defaultTimeout = 10 * time.Second
+)
+
+// Column names of user ID parts. They are derived from the bote DB field names
+// by dropping the "id." prefix, e.g. [bote.UserIDEncDBFieldName] is stored in "id_enc".
+var (
+	colIDPlain        = fieldColumn(bote.UserIDDBFieldName)
+	colIDEnc          = fieldColumn(bote.UserIDEncDBFieldName)
+	colEncKeyVersion  = fieldColumn(bote.UserIDEncKeyVersionDBFieldName)
+	colIDHMAC         = fieldColumn(bote.UserIDHMACDBFieldName)
+	colHMACKeyVersion = fieldColumn(bote.UserIDHMACKeyVersionDBFieldName)
+)
+
+const (
+	colLanguageCode         = "language_code"
+	colForceLanguageCode    = "force_language_code"
+	colInfoUsername         = "info_username"
+	colInfoFirstName        = "info_first_name"
+	colInfoLastName         = "info_last_name"
+	colInfoIsPremium        = "info_is_premium"
+	colMainID               = "messages_main_id"
+	colHeadID               = "messages_head_id"
+	colNotificationID       = "messages_notification_id"
+	colErrorID              = "messages_error_id"
+	colNotificationExpires  = "messages_notification_expires_at"
+	colErrorExpires         = "messages_error_expires_at"
+	colHistoryIDs           = "messages_history_ids"
+	colLastActions          = "messages_last_actions"
+	colStateMain            = "state_main"
+	colMessageStates        = "state_message_states"
+	colMessagesAwaitingText = "state_messages_awaiting_text"
+	colStateChanges         = "stats_number_of_state_changes_total"
+	colLastSeenTime         = "stats_last_seen_time"
+	colCreatedTime          = "stats_created_time"
+	colDisabledTime         = "stats_disabled_time"
+	colIsBot                = "is_bot"
+	colIsDisabled           = "is_disabled"
+	colValues               = "user_values"
+)
+
+type column struct {
+	name    string
+	kind    ColumnKind
+	notNull bool
+	unique  bool
+	// since is the index of the migration that adds the column, zero for the initial schema.
+	since int
+}
+
+// userColumns is the order of columns in inserts and selects.
+var userColumns = []column{
+	{name: colIDPlain, kind: KindInt, unique: true},
+	{name: colIDEnc, kind: KindText},
+	{name: colEncKeyVersion, kind: KindInt},
+	{name: colIDHMAC, kind: KindText, unique: true},
+	{name: colHMACKeyVersion, kind: KindInt},
+	{name: colLanguageCode, kind: KindText, notNull: true},
+	{name: colForceLanguageCode, kind: KindText, notNull: true},
+	{name: colInfoUsername, kind: KindText, notNull: true},
+	{name: colInfoFirstName, kind: KindText, notNull: true},
+	{name: colInfoLastName, kind: KindText, notNull: true},
+	{name: colInfoIsPremium, kind: KindBool},
+	{name: colMainID, kind: KindInt, notNull: true},
+	{name: colHeadID, kind: KindInt, notNull: true},
+	{name: colNotificationID, kind: KindInt, notNull: true},
+	{name: colErrorID, kind: KindInt, notNull: true},
+	{name: colNotificationExpires, kind: KindTime, since: 1},
+	{name: colErrorExpires, kind: KindTime, since: 1},
+	{name: colHistoryIDs, kind: KindJSON, notNull: true},
+	{name: colLastActions, kind: KindJSON, notNull: true},
+	{name: colStateMain, kind: KindText, notNull: true},
+	{name: colMessageStates, kind: KindJSON, notNull: true},
+	{name: colMessagesAwaitingText, kind: KindJSON, notNull: true},
+	{name: colStateChanges, kind: KindInt, notNull: true},
+	{name: colLastSeenTime, kind: KindTime, notNull: true},
+	{name: colCreatedTime, kind: KindTime, notNull: true},
+	{name: colDisabledTime, kind: KindTime, notNull: true},
+	{name: colIsBot, kind: KindBool, notNull: true},
+	{name: colIsDisabled, kind: KindBool, notNull: true},
+	{name: colValues, kind: KindJSON, notNull: true},
+}
+
+var tableNameRx = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// Options contains optional settings of [Storage].
+type Options struct {
+	// Table is a name of the users table or of the inbox table for [NewInboxStore].
+	// Migrations are tracked in "<Table>_migrations".
+	// Default: bote_users, bote_inbox for [NewInboxStore].
+	Table string
+	// Timeout limits every query made from UpdateAsync, which has no context. Default: 10s.
+	Timeout time.Duration
+	// Logger logs errors of UpdateAsync, which cannot return them. Default: no logging.
+	Logger bote.Logger
+	// DedupTTL is how long [InboxStore] remembers handled updates to ignore their duplicates. Default: 24h.
+	DedupTTL time.Duration
+}
+
+// WithTable sets a name of the users table.
+func WithTable(table string) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Table = table
+	}
+}
+
+// WithTimeout sets a timeout of queries made from UpdateAsync.
+func WithTimeout(timeout time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Timeout = timeout
+	}
+}
+
+// WithDedupTTL sets how long [InboxStore] remembers handled updates.
+func WithDedupTTL(ttl time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.DedupTTL = ttl
+	}
+}
+
+// WithLogger sets a logger for errors of UpdateAsync.
+func WithLogger(logger bote.Logger) func(opts *Options) {
+	return func(opts *Options) {
+		opts.Logger = logger
+	}
+}
+
+// Storage is a [bote.UsersStorage] backed by a SQL database.
+// UpdateAsync runs the query synchronously: bote already calls it from an ordered per-user queue.
+type Storage struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+	timeout time.Duration
+	log     bote.Logger
+}
+
+var (
+	_ bote.UsersStorage           = (*Storage)(nil)
+	_ bote.NamespacedUsersStorage = (*Storage)(nil)
+)
+
+// New creates a storage and migrates the schema to the latest version.
+func New(ctx context.Context, db *sql.DB, dialect Dialect, optsFuncs ...func(*Options)) (*Storage, error) {
+	if db == nil {
+		return nil, erro.New("db cannot be nil")
+	}
+	if dialect == nil {
+		return nil, erro.New("dialect cannot be nil")
+	}
+
+	var opts Options
+	for _, f := range optsFuncs {
+		f(&opts)
+	}
+	opts.Table = lang.Check(opts.Table, defaultTable)
+	opts.Timeout = lang.Check(opts.Timeout, defaultTimeout)
+	if !tableNameRx.MatchString(opts.Table) {
+		return nil, erro.New("invalid table name", "table", opts.Table)
+	}
+
+	s := &Storage{
+		db:      db,
+		dialect: dialect,
+		table:   opts.Table,
+		timeout: opts.Timeout,
+		log:     lang.If[bote.Logger](opts.Logger != nil, opts.Logger, noopLogger{}),
+	}
+	if err := s.Migrate(ctx); err != nil {
+		return nil, erro.Wrap(err, "migrate")
+	}
+	return s, nil
+}
+
+// Namespace returns a storage of users of another bot of [bote.Manager] in the same database.
+// Its table is "<Table>_<name>", it is created and migrated if needed.
+func (s *Storage) Namespace(ctx context.Context, name string) (bote.UsersStorage, error) {
+	return New(ctx, s.db, s.dialect, WithTable(s.table+"_"+name), WithTimeout(s.timeout), WithLogger(s.log))
+}
+
+// Insert inserts user in storage.
+func (s *Storage) Insert(ctx context.Context, user bote.UserModel) error {
+	if user.ID.IsEmpty() {
+		return erro.New("cannot insert user: empty user ID")
+	}
+	values, err := modelValues(user)
+	if err != nil {
+		return erro.Wrap(err, "prepare user")
+	}
+
+	names := make([]string, len(userColumns))
+	for i, c := range userColumns {
+		names[i] = quote(c.name)
+	}
+	query := "INSERT INTO " + quote(s.table) + " (" + strings.Join(names, ", ") + ") VALUES (" +
+		placeholders(s.dialect, 1, len(names)) + ")"
+
+	if _, err := s.db.ExecContext(ctx, query, values...); err != nil {
+		return erro.Wrap(err, "insert user", "user_id", user.ID.String())
+	}
+	return nil
+}
+
+// Find returns user from storage. It returns true as a second argument if user was found without error.
+func (s *Storage) Find(ctx context.Context, id bote.FullUserID) (bote.UserModel, bool, error) {
+	where, arg, err := s.whereID(id, 1)
+	if err != nil {
+		return bote.UserModel{}, false, err
+	}
+	row := s.db.QueryRowContext(ctx, s.selectQuery()+" WHERE "+where, arg)
+
+	user, err := scanUser(row)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return bote.UserModel{}, false, nil
+	case err != nil:
+		return bote.UserModel{}, false, erro.Wrap(err, "find user", "user_id", id.String())
+	}
+	return user, true, nil
+}
+
+// FindAll returns all users from storage.
+func (s *Storage) FindAll(ctx context.Context) ([]bote.UserModel, error) {
+	rows, err := s.db.QueryContext(ctx, s.selectQuery())
+	if err != nil {
+		return nil, erro.Wrap(err, "select users")
+	}
+	defer rows.Close()
+
+	var out []bote.UserModel
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, erro.Wrap(err, "scan user")
+		}
+		out = append(out, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, erro.Wrap(err, "iterate users")
+	}
+	return out, nil
+}
+
+// UpdateAsync updates only columns that are present in diff.
+func (s *Storage) UpdateAsync(id bote.FullUserID, diff *bote.UserModelDiff) {
+	names, values, err := diffColumns(diff)
+	if err != nil {
+		s.log.Error("cannot prepare user diff", "user_id", id.String(), "error", err.Error())
+		return
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	sets := make([]string, len(names))
+	for i, name := range names {
+		sets[i] = quote(name) + " = " + s.dialect.Placeholder(i+1)
+	}
+	where, arg, err := s.whereID(id, len(names)+1)
+	if err != nil {
+		s.log.Error("cannot update user", "user_id", id.String(), "error", err.Error())
+		return
+	}
+	query := "UPDATE " + quote(s.table) + " SET " + strings.Join(sets, ", ") + " WHERE " + where
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, query, append(values, arg)...); err != nil {
+		s.log.Error("cannot update user", "user_id", id.String(), "error", err.Error())
+	}
+}
+
+// Delete deletes user from storage.
+func (s *Storage) Delete(ctx context.Context, id bote.FullUserID) error {
+	where, arg, err := s.whereID(id, 1)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM "+quote(s.table)+" WHERE "+where, arg); err != nil {
+		return erro.Wrap(err, "delete user", "user_id", id.String())
+	}
+	return nil
+}
+
+func (s *Storage) selectQuery() string {
+	names := make([]string, len(userColumns))
+	for i, c := range userColumns {
+		names[i] = quote(c.name)
+	}
+	return "SELECT " + strings.Join(names, ", ") + " FROM " + quote(s.table)
+}
+
+// whereID returns a condition that finds the user by plain ID or, in strict privacy mode, by HMAC.
+func (s *Storage) whereID(id bote.FullUserID, argN int) (string, any, error) {
+	switch {
+	case id.IDPlain != nil:
+		return quote(colIDPlain) + " = " + s.dialect.Placeholder(argN), *id.IDPlain, nil
+	case id.IDHMAC != nil:
+		return quote(colIDHMAC) + " = " + s.dialect.Placeholder(argN), *id.IDHMAC, nil
+	default:
+		return "", nil, erro.New("user ID has neither plain ID nor HMAC")
+	}
+}
+
+// modelValues returns values of the user in the order of userColumns.
+func modelValues(u bote.UserModel) ([]any, error) {
+	historyIDs, err := marshalJSON(lang.If(u.Messages.HistoryIDs != nil, u.Messages.HistoryIDs, []int{}))
+	if err != nil {
+		return nil, err
+	}
+	lastActions, err := marshalJSON(lang.If(u.Messages.LastActions != nil, u.Messages.LastActions, map[int]time.Time{}))
+	if err != nil {
+		return nil, err
+	}
+	messageStates, err := marshalJSON(lang.If(u.State.MessageStates != nil, u.State.MessageStates, map[int]bote.UserState{}))
+	if err != nil {
+		return nil, err
+	}
+	awaitingText, err := marshalJSON(lang.If(u.State.MessagesAwaitingText != nil, u.State.MessagesAwaitingText, []int{}))
+	if err != nil {
+		return nil, err
+	}
+	values, err := marshalJSON(lang.If(u.Values != nil, u.Values, map[string]any{}))
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		nullInt(u.ID.IDPlain),
+		nullString(u.ID.IDEnc),
+		nullInt(u.ID.EncKeyVersion),
+		nullString(u.ID.IDHMAC),
+		nullInt(u.ID.HMACKeyVersion),
+		string(u.LanguageCode),
+		string(u.ForceLanguageCode),
+		u.Info.Username,
+		u.Info.FirstName,
+		u.Info.LastName,
+		nullBool(u.Info.IsPremium),
+		u.Messages.MainID,
+		u.Messages.HeadID,
+		u.Messages.NotificationID,
+		u.Messages.ErrorID,
+		nullTime(u.Messages.NotificationExpiresAt),
+		nullTime(u.Messages.ErrorExpiresAt),
+		historyIDs,
+		lastActions,
+		string(u.State.Main),
+		messageStates,
+		awaitingText,
+		u.Stats.NumberOfStateChangesTotal,
+		u.Stats.LastSeenTime.UTC(),
+		u.Stats.CreatedTime.UTC(),
+		u.Stats.DisabledTime.UTC(),
+		u.IsBot,
+		u.IsDisabled,
+		values,
+	}, nil
+}
+
+// diffColumns returns names and values of columns changed by diff.
+// It follows the semantics of [bote.UserModel.ApplyDiff]: nil fields are not changed.
+func diffColumns(diff *bote.UserModelDiff) ([]string, []any, error) {
+	if diff == nil {
+		return nil, nil, nil
+	}
+	var (
+		names  []string
+		values []any
+	)
+	add := func(name string, value any) {
+		names = append(names, name)
+		values = append(values, value)
+	}
+	addJSON := func(name string, value any) error {
+		data, err := marshalJSON(value)
+		if err != nil {
+			return erro.Wrap(err, "marshal", "column", name)
+		}
+		add(name, data)
+		return nil
+	}
+
+	if diff.LanguageCode != nil {
+		add(colLanguageCode, string(*diff.LanguageCode))
+	}
+	if diff.ForceLanguageCode != nil {
+		add(colForceLanguageCode, string(*diff.ForceLanguageCode))
+	}
+	if info := diff.Info; info != nil {
+		if info.Username != nil {
+			add(colInfoUsername, *info.Username)
+		}
+		if info.FirstName != nil {
+			add(colInfoFirstName, *info.FirstName)
+		}
+		if info.LastName != nil {
+			add(colInfoLastName, *info.LastName)
+		}
+		if info.IsPremium != nil {
+			add(colInfoIsPremium, *info.IsPremium)
+		}
+	}
+	if msgs := diff.Messages; msgs != nil {
+		if msgs.MainID != nil {
+			add(colMainID, *msgs.MainID)
+		}
+		if msgs.HeadID != nil {
+			add(colHeadID, *msgs.HeadID)
+		}
+		if msgs.NotificationID != nil {
+			add(colNotificationID, *msgs.NotificationID)
+		}
+		if msgs.ErrorID != nil {
+			add(colErrorID, *msgs.ErrorID)
+		}
+		if msgs.NotificationExpiresAt != nil {
+			add(colNotificationExpires, nullTime(*msgs.NotificationExpiresAt))
+		}
+		if msgs.ErrorExpiresAt != nil {
+			add(colErrorExpires, nullTime(*msgs.ErrorExpiresAt))
+		}
+		if msgs.HistoryIDs != nil {
+			if err := addJSON(colHistoryIDs, msgs.HistoryIDs); err != nil {
+				return nil, nil, err
+			}
+		}
+		if msgs.LastActions != nil {
+			if err := addJSON(colLastActions, msgs.LastActions); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if st := diff.State; st != nil {
+		if st.Main != nil {
+			add(colStateMain, string(*st.Main))
+		}
+		if len(st.MessageStates) > 0 {
+			if err := addJSON(colMessageStates, st.MessageStates); err != nil {
+				return nil, nil, err
+			}
+		}
+		if st.MessagesAwaitingText != nil {
+			if err := addJSON(colMessagesAwaitingText, st.MessagesAwaitingText); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if stats := diff.Stats; stats != nil {
+		if stats.NumberOfStateChanges != nil {
+			add(colStateChanges, *stats.NumberOfStateChanges)
+		}
+		if stats.LastSeenTime != nil {
+			add(colLastSeenTime, stats.LastSeenTime.UTC())
+		}
+		if stats.DisabledTime != nil {
+			add(colDisabledTime, stats.DisabledTime.UTC())
+		}
+	}
+	if diff.IsBot != nil {
+		add(colIsBot, *diff.IsBot)
+	}
+	if diff.IsDisabled != nil {
+		add(colIsDisabled, *diff.IsDisabled)
+	}
+	if diff.Values != nil {
+		if err := addJSON(colValues, diff.Values); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return names, values, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row scanner) (bote.UserModel, error) {
+	var (
+		u bote.UserModel
+
+		idPlain, encKeyVersion, hmacKeyVersion sql.NullInt64
+		idEnc, idHMAC                          sql.NullString
+		isPremium                              sql.NullBool
+		notificationExpires, errorExpires      sql.NullTime
+
+		languageCode, forceLanguageCode, stateMain string
+
+		historyIDs, lastActions, messageStates, awaitingText, values []byte
+	)
+	err := row.Scan(
+		&idPlain, &idEnc, &encKeyVersion, &idHMAC, &hmacKeyVersion,
+		&languageCode, &forceLanguageCode,
+		&u.Info.Username, &u.Info.FirstName, &u.Info.LastName, &isPremium,
+		&u.Messages.MainID, &u.Messages.HeadID, &u.Messages.NotificationID, &u.Messages.ErrorID,
+		&notificationExpires, &errorExpires,
+		&historyIDs, &lastActions,
+		&stateMain, &messageStates, &awaitingText,
+		&u.Stats.NumberOfStateChangesTotal, &u.Stats.LastSeenTime, &u.Stats.CreatedTime, &u.Stats.DisabledTime,
+		&u.IsBot, &u.IsDisabled, &values,
+	)
+	if err != nil {
+		return bote.UserModel{}, err
+	}
+
+	u.ID = bote.FullUserID{
+		IDPlain:        fromNullInt(idPlain),
+		IDEnc:          fromNullString(idEnc),
+		EncKeyVersion:  fromNullInt(encKeyVersion),
+		IDHMAC:         fromNullString(idHMAC),
+		HMACKeyVersion: fromNullInt(hmacKeyVersion),
+	}
+	if isPremium.Valid {
+		u.Info.IsPremium = lang.Ptr(isPremium.Bool)
+	}
+	u.Messages.NotificationExpiresAt = fromNullTime(notificationExpires)
+	u.Messages.ErrorExpiresAt = fromNullTime(errorExpires)
+	u.LanguageCode = bote.Language(languageCode)
+	u.ForceLanguageCode = bote.Language(forceLanguageCode)
+	u.State.Main = bote.UserState(stateMain)
+
+	for _, f := range []struct {
+		name string
+		data []byte
+		dst  any
+	}{
+		{colHistoryIDs, historyIDs, &u.Messages.HistoryIDs},
+		{colLastActions, lastActions, &u.Messages.LastActions},
+		{colMessageStates, messageStates, &u.State.MessageStates},
+		{colMessagesAwaitingText, awaitingText, &u.State.MessagesAwaitingText},
+	} {
+		if len(f.data) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(f.data, f.dst); err != nil {
+			return bote.UserModel{}, erro.Wrap(err, "unmarshal", "column", f.name)
+		}
+	}
+
+	// Numbers keep their precision: user values often hold int64 IDs that do not fit float64
+	if len(values) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(values))
+		dec.UseNumber()
+		if err := dec.Decode(&u.Values); err != nil {
+			return bote.UserModel{}, erro.Wrap(err, "unmarshal", "column", colValues)
+		}
+	}
+
+	return u, nil
+}
+
+func marshalJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func fieldColumn(field string) string {
+	_, name, found := strings.Cut(field, ".")
+	if !found {
+		return field
+	}
+	return name
+}
+
+func nullInt(v *int64) sql.NullInt64 {
+	if v == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *v, Valid: true}
+}
+
+func nullString(v *string) sql.NullString {
+	if v == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *v, Valid: true}
+}
+
+func nullBool(v *bool) sql.NullBool {
+	if v == nil {
+		return sql.NullBool{}
+	}
+	return sql.NullBool{Bool: *v, Valid: true}
+}
+
+// nullTime stores zero time as NULL, so the expiry columns added by a migration need no default.
+func nullTime(v time.Time) sql.NullTime {
+	if v.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: v.UTC(), Valid: true}
+}
+
+func fromNullInt(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return lang.Ptr(v.Int64)
+}
+
+func fromNullString(v sql.NullString) *string {
+	if !v.Valid {
+		return nil
+	}
+	return lang.Ptr(v.String)
+}
+
+func fromNullTime(v sql.NullTime) time.Time {
+	if !v.Valid {
+		return time.Time{}
+	}
+	return v.Time
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Debug(string, ...any) {}
+func (noopLogger) Info(string, ...any)  {}
+func (noopLogger) Warn(string, ...any)  {}
+func (noopLogger) Error(string, ...any) {}
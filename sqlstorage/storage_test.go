@@ -0,0 +1,191 @@
+package sqlstorage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/maxbolgarin/bote"
+	"github.com/maxbolgarin/lang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRow feeds values to scanUser the way database/sql does after driver conversion.
+type fakeRow []any
+
+func (r fakeRow) Scan(dest ...any) error {
+	for i, v := range r {
+		dv := reflect.ValueOf(dest[i]).Elem()
+		dv.Set(reflect.ValueOf(v).Convert(dv.Type()))
+	}
+	return nil
+}
+
+func testUser() bote.UserModel {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	return bote.UserModel{
+		ID: bote.FullUserID{
+			IDHMAC:         lang.Ptr("abcdef"),
+			IDEnc:          lang.Ptr("0011"),
+			EncKeyVersion:  lang.Ptr(int64(2)),
+			HMACKeyVersion: lang.Ptr(int64(3)),
+		},
+		LanguageCode: bote.LanguageEnglish,
+		Info:         bote.UserInfo{Username: "alice", IsPremium: lang.Ptr(true)},
+		Messages: bote.UserMessages{
+			MainID:                10,
+			HeadID:                9,
+			NotificationID:        12,
+			NotificationExpiresAt: now.Add(time.Minute),
+			HistoryIDs:            []int{5, 7},
+			LastActions:           map[int]time.Time{10: now},
+		},
+		State: bote.MessagesState{
+			Main:                 "menu",
+			MessageStates:        map[int]bote.UserState{10: "menu", 7: "old"},
+			MessagesAwaitingText: []int{10},
+		},
+		Stats:  bote.UserStat{NumberOfStateChangesTotal: 4, LastSeenTime: now, CreatedTime: now},
+		Values: map[string]any{"name": "Alice"},
+	}
+}
+
+// TestModelRoundTrip verifies every field survives the column mapping in both directions.
+func TestModelRoundTrip(t *testing.T) {
+	user := testUser()
+
+	values, err := modelValues(user)
+	require.NoError(t, err)
+	require.Len(t, values, len(userColumns), "values must follow userColumns")
+
+	got, err := scanUser(fakeRow(values))
+	require.NoError(t, err)
+	assert.Equal(t, user, got)
+}
+
+// TestDiffColumnsPartial verifies only the fields present in the diff produce columns.
+func TestDiffColumnsPartial(t *testing.T) {
+	names, values, err := diffColumns(&bote.UserModelDiff{
+		Messages: &bote.UserMessagesDiff{MainID: lang.Ptr(42)},
+		State:    &bote.UserStateDiff{Main: lang.Ptr(bote.UserState("settings")), MessageStates: map[int]bote.UserState{}},
+		Values:   map[string]any{"k": 1},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{colMainID, colStateMain, colValues}, names,
+		"empty MessageStates must not reset the column, like UserModel.ApplyDiff")
+	assert.Equal(t, []any{42, "settings", `{"k":1}`}, values)
+
+	names, _, err = diffColumns(nil)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+// TestDiffColumnsMatchApplyDiff verifies applying a diff through columns gives the same model as
+// bote's own in-memory merge.
+func TestDiffColumnsMatchApplyDiff(t *testing.T) {
+	user := testUser()
+	disabled := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	diff := &bote.UserModelDiff{
+		Info: &bote.UserInfoDiff{FirstName: lang.Ptr("Alice")},
+		Messages: &bote.UserMessagesDiff{
+			ErrorID:               lang.Ptr(11),
+			ErrorExpiresAt:        lang.Ptr(disabled.Add(time.Minute)),
+			NotificationID:        lang.Ptr(0),
+			NotificationExpiresAt: &time.Time{},
+			HistoryIDs:            []int{5},
+		},
+		Stats:      &bote.UserStatDiff{DisabledTime: &disabled},
+		IsDisabled: lang.Ptr(true),
+	}
+
+	expected := testUser()
+	expected.ApplyDiff(diff)
+
+	values, err := modelValues(user)
+	require.NoError(t, err)
+	names, diffValues, err := diffColumns(diff)
+	require.NoError(t, err)
+	for i, name := range names {
+		for j, c := range userColumns {
+			if c.name == name {
+				values[j] = diffValues[i]
+			}
+		}
+	}
+
+	got, err := scanUser(fakeRow(values))
+	require.NoError(t, err)
+	assert.Equal(t, expected.Info, got.Info)
+	assert.Equal(t, expected.Messages, got.Messages)
+	assert.Equal(t, expected.Stats, got.Stats)
+	assert.Equal(t, expected.IsDisabled, got.IsDisabled)
+}
+
+// TestSchemaHasIDColumns verifies the encrypted and HMAC ID columns follow bote's DB field names.
+func TestSchemaHasIDColumns(t *testing.T) {
+	assert.Equal(t, "id_plain", colIDPlain)
+	assert.Equal(t, "id_enc", colIDEnc)
+	assert.Equal(t, "id_hmac", colIDHMAC)
+
+	for _, d := range []Dialect{Postgres, SQLite} {
+		stmts := createUsersTable(d, "users")
+		require.Len(t, stmts, 3, "%s: table and two unique indexes", d.Name())
+		assert.Contains(t, stmts[0], `"id_enc" TEXT`)
+		assert.Contains(t, stmts[0], `"id_hmac" TEXT`)
+		assert.Contains(t, stmts[0], `"user_values" `+d.Type(KindJSON)+` NOT NULL`)
+		assert.Contains(t, stmts[1], `CREATE UNIQUE INDEX IF NOT EXISTS "users_id_plain_idx"`)
+		assert.Contains(t, stmts[2], `("id_hmac")`)
+	}
+}
+
+// TestMigrationsAddExpiryColumns verifies columns added later are not in the initial schema,
+// so upgraded and fresh databases end up with the same table.
+func TestMigrationsAddExpiryColumns(t *testing.T) {
+	create := createUsersTable(SQLite, "users")
+	assert.NotContains(t, create[0], colNotificationExpires)
+
+	stmts := addMessagesExpiry(SQLite, "users")
+	require.Len(t, stmts, 2)
+	assert.Equal(t, `ALTER TABLE "users" ADD COLUMN "messages_notification_expires_at" `+SQLite.Type(KindTime), stmts[0])
+	assert.Contains(t, stmts[1], `"messages_error_expires_at"`)
+}
+
+// TestDialects verifies placeholders and types differ where the databases differ.
+func TestDialects(t *testing.T) {
+	assert.Equal(t, "$1, $2, $3", placeholders(Postgres, 1, 3))
+	assert.Equal(t, "?, ?", placeholders(SQLite, 4, 2))
+	assert.Equal(t, "JSONB", Postgres.Type(KindJSON))
+	assert.Equal(t, "TEXT", SQLite.Type(KindJSON))
+	assert.Equal(t, "INTEGER", SQLite.Type(KindBool))
+	assert.Equal(t, `"a""b"`, quote(`a"b`))
+}
+
+// TestWhereID verifies lookups use the plain ID and fall back to HMAC in strict privacy mode.
+func TestWhereID(t *testing.T) {
+	s := &Storage{dialect: Postgres}
+
+	where, arg, err := s.whereID(bote.NewPlainUserID(5), 2)
+	require.NoError(t, err)
+	assert.Equal(t, `"id_plain" = $2`, where)
+	assert.Equal(t, int64(5), arg)
+
+	where, arg, err = s.whereID(bote.FullUserID{IDHMAC: lang.Ptr("ff")}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, `"id_hmac" = $1`, where)
+	assert.Equal(t, "ff", arg)
+
+	_, _, err = s.whereID(bote.FullUserID{}, 1)
+	assert.Error(t, err)
+}
+
+// TestNewValidation verifies invalid arguments are rejected before touching the database.
+func TestNewValidation(t *testing.T) {
+	_, err := New(context.Background(), nil, SQLite)
+	assert.Error(t, err)
+
+	assert.True(t, tableNameRx.MatchString("bote_users"))
+	assert.False(t, tableNameRx.MatchString(`users"; DROP TABLE x`))
+}
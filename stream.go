@@ -0,0 +1,378 @@
+package bote
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/maxbolgarin/erro"
+	tele "github.com/maxbolgarin/telebot/v4"
+)
+
+const (
+	// maxMessageLength is the maximum length of a text message in UTF-16 code units.
+	maxMessageLength = 4096
+	// streamDraftRefresh is the time after which an unchanged draft is sent again.
+	// Telegram shows a draft for about 30 seconds, a slow source should not make it disappear.
+	streamDraftRefresh = 20 * time.Second
+	// streamReadBufferSize is the size of a single read from an io.Reader source.
+	streamReadBufferSize = 1024
+)
+
+func (c *contextImpl) Stream(newState State, src any, kb *tele.ReplyMarkup, opts ...any) error {
+	if !c.validateUserInput("Stream", newState) {
+		return nil
+	}
+
+	ctx := c.Ctx()
+	var chunks <-chan string
+	var readErr func() error
+	switch src := src.(type) {
+	case <-chan string:
+		chunks, readErr = src, func() error { return nil }
+	case chan string:
+		chunks, readErr = src, func() error { return nil }
+	case io.Reader:
+		chunks, readErr = readStream(ctx, src)
+	default:
+		c.bt.bot.log.Error("stream source must be <-chan string or io.Reader", c.bt.userFields(c.user)...)
+		c.bt.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		return nil
+	}
+
+	s := &stream{
+		c:       c,
+		state:   newState,
+		opts:    opts,
+		mode:    c.bt.bot.parseMode,
+		draftID: rand.IntN(1<<31-1) + 1,
+	}
+
+	ticker := time.NewTicker(c.bt.streamInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				if err := readErr(); err != nil {
+					return erro.Wrap(err, "read stream")
+				}
+				return s.commit(kb)
+			}
+			if err := s.write(chunk); err != nil {
+				return err
+			}
+
+		case <-ticker.C:
+			s.draft()
+
+		case <-ctx.Done():
+			s.interrupt(kb)
+			return erro.Wrap(ctx.Err(), "stream")
+		}
+	}
+}
+
+// stream accumulates the text of a message that is being generated.
+type stream struct {
+	c       *contextImpl
+	state   State
+	opts    []any
+	mode    tele.ParseMode
+	draftID int
+
+	text      string
+	drafted   string
+	draftedAt time.Time
+}
+
+// write appends a chunk and sends the parts of the text that do not fit into a single message.
+func (s *stream) write(chunk string) error {
+	s.text += chunk
+	for messageLength(s.text, s.mode) > maxMessageLength {
+		part, rest := splitMessageMode(s.text, maxMessageLength, s.mode)
+		if err := s.c.SendMain(s.state, part, nil, s.opts...); err != nil {
+			return erro.Wrap(err, "send stream part")
+		}
+		s.text = rest
+		s.drafted = ""
+	}
+	return nil
+}
+
+// draft sends the accumulated text as a draft if it is changed or the previous draft is about to disappear.
+// Nothing is sent until there is some text.
+func (s *stream) draft() {
+	if strings.TrimSpace(s.text) == "" {
+		return
+	}
+	if !s.draftedAt.IsZero() && s.text == s.drafted && time.Since(s.draftedAt) < streamDraftRefresh {
+		return
+	}
+	text := s.text
+	if s.mode == tele.ModeHTML {
+		text = balanceHTML(text)
+	}
+	// A failed draft costs the user a preview frame, the stream goes on.
+	if err := s.c.bt.bot.sendDraft(s.c.user.ID(), s.draftID, text, s.mode); err != nil {
+		s.c.bt.bot.log.Debug("failed to send stream draft", s.c.bt.userFields(s.c.user, "error", err.Error())...)
+	}
+	s.drafted, s.draftedAt = s.text, time.Now()
+}
+
+// commit sends the rest of the text as the main message with the keyboard.
+func (s *stream) commit(kb *tele.ReplyMarkup) error {
+	if strings.TrimSpace(s.text) == "" {
+		s.c.bt.bot.log.Warn("stream is empty, nothing to send", s.c.bt.userFields(s.c.user)...)
+		return nil
+	}
+	return s.c.SendMain(s.state, s.text, kb, s.opts...)
+}
+
+// interrupt commits the text generated before the context is done, so the user does not keep a stale draft.
+func (s *stream) interrupt(kb *tele.ReplyMarkup) {
+	if strings.TrimSpace(s.text) == "" {
+		return
+	}
+	if s.mode == tele.ModeHTML {
+		s.text = balanceHTML(s.text)
+	}
+	if err := s.commit(kb); err != nil {
+		s.c.bt.bot.log.Warn("failed to commit interrupted stream", s.c.bt.userFields(s.c.user, "error", err.Error())...)
+	}
+}
+
+// readStream reads the reader in a goroutine and sends chunks of whole runes to the channel.
+// The returned function returns a read error after the channel is closed.
+func readStream(ctx context.Context, r io.Reader) (<-chan string, func() error) {
+	ch := make(chan string)
+	var readErr error
+
+	go func() {
+		defer close(ch)
+
+		buf := make([]byte, streamReadBufferSize)
+		var pending []byte
+		for {
+			n, err := r.Read(buf)
+			pending = append(pending, buf[:n]...)
+
+			cut := len(pending)
+			if err == nil {
+				cut = completeRunes(pending)
+			}
+			if cut > 0 {
+				select {
+				case ch <- string(pending[:cut]):
+				case <-ctx.Done():
+					return
+				}
+				pending = append(pending[:0], pending[cut:]...)
+			}
+
+			if err != nil {
+				if err != io.EOF {
+					readErr = err
+				}
+				return
+			}
+		}
+	}()
+
+	return ch, func() error { return readErr }
+}
+
+// completeRunes returns the length of the prefix of b without an incomplete rune at the end.
+func completeRunes(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if utf8.FullRune(b[i:]) {
+				return len(b)
+			}
+			return i
+		}
+	}
+	return len(b)
+}
+
+func utf16Length(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// splitMessage splits text into a part of at most limit UTF-16 code units and the rest.
+// It splits by the last line break or space in the part if there is one.
+func splitMessage(text string, limit int) (string, string) {
+	end, n := 0, 0
+	for i, r := range text {
+		n += utf16.RuneLen(r)
+		if n > limit {
+			break
+		}
+		end = i + utf8.RuneLen(r)
+	}
+
+	part := text[:end]
+	if i := strings.LastIndex(part, "\n"); i > 0 {
+		return text[:i], text[i+1:]
+	}
+	if i := strings.LastIndex(part, " "); i > 0 {
+		return text[:i], text[i+1:]
+	}
+	return part, text[end:]
+}
+
+// messageLength returns the length of the text the user sees in UTF-16 code units.
+func messageLength(text string, mode tele.ParseMode) int {
+	if mode != tele.ModeHTML {
+		return utf16Length(text)
+	}
+	_, _, visible := scanHTML(text, func(int, int) bool { return true })
+	return visible
+}
+
+// splitMessageMode splits text as [splitMessage] does, keeping HTML markup valid in both halves.
+func splitMessageMode(text string, limit int, mode tele.ParseMode) (string, string) {
+	if mode != tele.ModeHTML {
+		return splitMessage(text, limit)
+	}
+	return splitHTML(text, limit)
+}
+
+// htmlTag is a tag that is open at some position of an HTML text.
+type htmlTag struct {
+	name string
+	// raw is the opening tag as written, e.g. <a href="https://example.com">.
+	raw string
+}
+
+// scanHTML walks the HTML text and calls yield for every position outside of tags and entities with
+// the visible length before it in UTF-16 code units; an entity is a single character. It stops when
+// yield returns false and returns the tags open at the stop, the offset of the stop (or of an
+// incomplete tag or entity at the end of the text) and the visible length.
+func scanHTML(text string, yield func(offset, visible int) bool) ([]htmlTag, int, int) {
+	var open []htmlTag
+	visible, start := 0, -1 // start is an offset of the current tag or entity
+	for i, r := range text {
+		if start >= 0 {
+			switch {
+			case text[start] == '<':
+				if r == '>' {
+					open = applyHTMLTag(open, text[start:i+1])
+					start = -1
+				}
+				continue
+			case r == ';':
+				visible++
+				start = -1
+				continue
+			case r == '#' || r < utf8.RuneSelf && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'):
+				continue
+			default:
+				// Not an entity, the ampersand is counted as text
+				visible += utf16Length(text[start:i])
+				start = -1
+			}
+		}
+		if !yield(i, visible) {
+			return open, i, visible
+		}
+		if r == '<' || r == '&' {
+			start = i
+			continue
+		}
+		visible += utf16.RuneLen(r)
+	}
+	if start >= 0 {
+		return open, start, visible
+	}
+	yield(len(text), visible)
+	return open, len(text), visible
+}
+
+// applyHTMLTag returns the tags that are open after the tag.
+func applyHTMLTag(open []htmlTag, tag string) []htmlTag {
+	name := strings.TrimPrefix(strings.TrimPrefix(tag, "<"), "/")
+	if i := strings.IndexAny(name, " \t\n/>"); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.ToLower(name)
+
+	switch {
+	case strings.HasPrefix(tag, "</"):
+		for i := len(open) - 1; i >= 0; i-- {
+			if open[i].name == name {
+				return open[:i]
+			}
+		}
+		return open
+	case strings.HasSuffix(tag, "/>"):
+		return open
+	default:
+		return append(open, htmlTag{name: name, raw: tag})
+	}
+}
+
+func closeHTMLTags(open []htmlTag) string {
+	var b strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i].name + ">")
+	}
+	return b.String()
+}
+
+func reopenHTMLTags(open []htmlTag) string {
+	var b strings.Builder
+	for _, tag := range open {
+		b.WriteString(tag.raw)
+	}
+	return b.String()
+}
+
+// splitHTML splits HTML text into a part of at most limit visible UTF-16 code units and the rest.
+// It splits outside of tags and entities, by the last line break or space if there is one.
+// Tags open at the split are closed at the end of the part and opened again at the start of the rest.
+func splitHTML(text string, limit int) (string, string) {
+	end, lastBreak, lastSpace := 0, -1, -1
+	scanHTML(text, func(offset, visible int) bool {
+		if visible > limit {
+			return false
+		}
+		end = offset
+		if offset < len(text) && visible > 0 {
+			switch text[offset] {
+			case '\n':
+				lastBreak = offset
+			case ' ':
+				lastSpace = offset
+			}
+		}
+		return true
+	})
+
+	cut, skip := end, 0
+	switch {
+	case lastBreak > 0:
+		cut, skip = lastBreak, 1
+	case lastSpace > 0:
+		cut, skip = lastSpace, 1
+	}
+
+	open, _, _ := scanHTML(text[:cut], func(int, int) bool { return true })
+	return text[:cut] + closeHTMLTags(open), reopenHTMLTags(open) + text[cut+skip:]
+}
+
+// balanceHTML drops an incomplete tag or entity at the end of HTML text and closes open tags,
+// so a text that is still being generated can be sent.
+func balanceHTML(text string) string {
+	open, end, _ := scanHTML(text, func(int, int) bool { return true })
+	return text[:end] + closeHTMLTags(open)
+}
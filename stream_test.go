@@ -0,0 +1,95 @@
+package bote
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSplitMessage verifies long texts are split by words within the limit counted in UTF-16 units.
+func TestSplitMessage(t *testing.T) {
+	part, rest := splitMessage("hello brave new world", 12)
+	assert.Equal(t, "hello brave", part)
+	assert.Equal(t, "new world", rest)
+
+	part, rest = splitMessage("first line\nsecond line", 16)
+	assert.Equal(t, "first line", part)
+	assert.Equal(t, "second line", rest)
+
+	part, rest = splitMessage(strings.Repeat("я", 10), 4)
+	assert.Equal(t, strings.Repeat("я", 4), part, "a word without spaces is cut at a rune boundary")
+	assert.Equal(t, strings.Repeat("я", 6), rest)
+
+	part, rest = splitMessage("ab😀cd", 3)
+	assert.Equal(t, "ab", part, "emoji takes two UTF-16 units")
+	assert.Equal(t, "😀cd", rest)
+	assert.Equal(t, 6, utf16Length("ab😀cd"))
+}
+
+// TestSplitHTML verifies HTML texts are split outside of tags and entities and keep their markup valid.
+func TestSplitHTML(t *testing.T) {
+	assert.Equal(t, 11, messageLength("<b>hello</b> &amp; bye", tele.ModeHTML), "tags are not counted, entity is one character")
+	assert.Equal(t, 22, messageLength("<b>hello</b> &amp; bye", tele.ModeDefault))
+
+	part, rest := splitHTML(`<b>hello <a href="https://example.com/x y">brave</a> new</b> world`, 12)
+	assert.Equal(t, `<b>hello <a href="https://example.com/x y">brave</a></b>`, part)
+	assert.Equal(t, `<b>new</b> world`, rest)
+
+	part, rest = splitHTML("<i>"+strings.Repeat("a", 5)+"&lt;&gt;"+strings.Repeat("b", 5)+"</i>", 6)
+	assert.Equal(t, "<i>aaaaa&lt;</i>", part, "entity is not cut")
+	assert.Equal(t, "<i>&gt;bbbbb</i>", rest)
+
+	part, rest = splitHTML("<pre><code class=\"language-go\">line one\nline two</code></pre>", 12)
+	assert.Equal(t, "<pre><code class=\"language-go\">line one</code></pre>", part)
+	assert.Equal(t, "<pre><code class=\"language-go\">line two</code></pre>", rest)
+
+	assert.Equal(t, "<b>bold <i>it</i></b>", balanceHTML("<b>bold <i>it"))
+	assert.Equal(t, "<b>bold</b>", balanceHTML("<b>bold<a hr"), "incomplete tag is dropped")
+	assert.Equal(t, "fish ", balanceHTML("fish &am"), "incomplete entity is dropped")
+	assert.Equal(t, "a & b", balanceHTML("a & b"), "bare ampersand is text")
+}
+
+// TestReadStream verifies a reader is read by whole runes and read errors are returned.
+func TestReadStream(t *testing.T) {
+	text := "Привет, 世界 😀"
+	chunks, readErr := readStream(context.Background(), iotest.OneByteReader(strings.NewReader(text)))
+
+	var got strings.Builder
+	for chunk := range chunks {
+		require.True(t, utf8.ValidString(chunk), "chunk %q is not valid UTF-8", chunk)
+		got.WriteString(chunk)
+	}
+	assert.Equal(t, text, got.String())
+	assert.NoError(t, readErr())
+
+	failure := errors.New("connection reset")
+	chunks, readErr = readStream(context.Background(), iotest.ErrReader(failure))
+	for range chunks {
+	}
+	assert.ErrorIs(t, readErr(), failure)
+}
+
+// TestStream verifies misuse and empty sources do not send anything.
+func TestStream(t *testing.T) {
+	bot := setupTestBot(t)
+	ctx := NewContext(bot, 14001, 1)
+
+	assert.NoError(t, ctx.Stream(NoChange, "not a source", nil), "unsupported source is logged")
+
+	empty := make(chan string)
+	close(empty)
+	assert.NoError(t, ctx.Stream(NoChange, empty, nil), "empty stream sends nothing")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	impl := NewContext(bot, 14002, 1).(*contextImpl)
+	impl.deadline = newHandlerDeadline(canceled, 0, nil)
+	assert.ErrorIs(t, impl.Stream(NoChange, make(chan string), nil), context.Canceled)
+}
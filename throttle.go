@@ -0,0 +1,215 @@
+package bote
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/maxbolgarin/erro"
+	"github.com/maxbolgarin/lang"
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/maypok86/otter"
+	"golang.org/x/time/rate"
+)
+
+// OutgoingLimiter limits outgoing requests to Telegram Bot API.
+// Bote calls it before every send, edit and delete; you may provide your own implementation
+// using [WithOutgoingLimiter], e.g. to share limits between several instances of a bot.
+type OutgoingLimiter interface {
+	// Wait blocks until a request to the chat is allowed. It returns an error if ctx is done first.
+	// chatID is 0 for requests that are limited only globally (deletions).
+	Wait(ctx context.Context, chatID int64) error
+}
+
+var (
+	errThrottleQueueFull = erro.New("outgoing queue is full")
+	errThrottleTimeout   = erro.New("outgoing request waited too long for rate limit")
+)
+
+const (
+	// limiterCacheCapacity is a maximum number of chats with their own token buckets.
+	// Buckets of inactive chats expire, an expired bucket is just a full one.
+	limiterCacheCapacity = 100_000
+	limiterCacheTTL      = time.Minute
+
+	throttleResultDelayed = "delayed"
+	throttleResultDropped = "dropped"
+)
+
+// tokenBucketLimiter is a default [OutgoingLimiter] with token buckets for all requests,
+// for every private chat and for every group, following Telegram limits.
+type tokenBucketLimiter struct {
+	global *rate.Limiter
+
+	chatRate   rate.Limit
+	groupRate  rate.Limit
+	chatBurst  int
+	groupBurst int
+
+	mu    sync.Mutex
+	chats otter.Cache[int64, *rate.Limiter]
+}
+
+// NewOutgoingLimiter creates a token bucket [OutgoingLimiter] from the config.
+// Zero fields of the config are replaced with defaults.
+func NewOutgoingLimiter(cfg ThrottleConfig) (OutgoingLimiter, error) {
+	cfg.prepare()
+
+	chats, err := otter.MustBuilder[int64, *rate.Limiter](limiterCacheCapacity).WithTTL(limiterCacheTTL).Build()
+	if err != nil {
+		return nil, erro.Wrap(err, "failed to create limiters cache")
+	}
+
+	return &tokenBucketLimiter{
+		global:     rate.NewLimiter(rate.Limit(cfg.GlobalPerSecond), cfg.GlobalPerSecond),
+		chatRate:   rate.Limit(cfg.ChatPerSecond),
+		chatBurst:  cfg.ChatBurst,
+		groupRate:  rate.Limit(float64(cfg.GroupPerMinute) / 60),
+		groupBurst: cfg.ChatBurst,
+		chats:      chats,
+	}, nil
+}
+
+func (l *tokenBucketLimiter) Wait(ctx context.Context, chatID int64) error {
+	if chatID != 0 {
+		if err := l.chat(chatID).Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return l.global.Wait(ctx)
+}
+
+func (l *tokenBucketLimiter) chat(chatID int64) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limiter, ok := l.chats.Get(chatID); ok {
+		return limiter
+	}
+
+	var limiter *rate.Limiter
+	if chatID < 0 {
+		// Group and channel IDs are negative
+		limiter = rate.NewLimiter(l.groupRate, l.groupBurst)
+	} else {
+		limiter = rate.NewLimiter(l.chatRate, l.chatBurst)
+	}
+	l.chats.Set(chatID, limiter)
+
+	return limiter
+}
+
+// throttler wraps outgoing requests of [baseBot]: it waits for the limiter with a bounded number of
+// waiting requests and retries requests rejected by Telegram with a flood error after retry_after.
+type throttler struct {
+	limiter    OutgoingLimiter
+	maxQueue   int64
+	maxWait    time.Duration
+	maxRetries int
+
+	queued atomic.Int64
+
+	metr *metrics
+	log  Logger
+	priv PrivacyMode
+}
+
+func newThrottler(cfg ThrottleConfig, limiter OutgoingLimiter, metr *metrics, log Logger, priv PrivacyMode) (*throttler, error) {
+	cfg.prepare()
+
+	if limiter == nil && lang.Deref(cfg.Enabled) {
+		var err error
+		limiter, err = NewOutgoingLimiter(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &throttler{
+		limiter:    limiter,
+		maxQueue:   int64(cfg.MaxQueue),
+		maxWait:    cfg.MaxWait,
+		maxRetries: cfg.MaxRetries,
+		metr:       metr,
+		log:        log,
+		priv:       priv,
+	}, nil
+}
+
+// do runs the request f when the limiter allows it. method is used as a label in metrics.
+func (t *throttler) do(method string, chatID int64, f func() error) error {
+	if t == nil {
+		return f()
+	}
+	return t.run(method, chatID, t.maxRetries, f)
+}
+
+// doOnce runs the request f when the limiter allows it without retries of flood errors.
+// It is used by callers that retry them in their own way (e.g. [Broadcast] pauses the whole campaign)
+// and for drafts, which are outdated by the time retry_after passes.
+func (t *throttler) doOnce(method string, chatID int64, f func() error) error {
+	if t == nil {
+		return f()
+	}
+	return t.run(method, chatID, 0, f)
+}
+
+func (t *throttler) run(method string, chatID int64, maxRetries int, f func() error) error {
+	if err := t.wait(method, chatID); err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := f()
+
+		var floodErr tele.FloodError
+		if err == nil || !errors.As(err, &floodErr) || attempt >= maxRetries {
+			return err
+		}
+
+		retryAfter := time.Duration(lang.Check(floodErr.RetryAfter, 1)) * time.Second
+		if retryAfter > t.maxWait {
+			return err
+		}
+
+		t.log.Warn("too many requests, retry after delay", "method", method,
+			"user_id", prepareUserID(chatID, t.priv), "retry_after", retryAfter.String())
+		t.metr.incOutgoingRetried(method)
+
+		time.Sleep(retryAfter)
+	}
+}
+
+func (t *throttler) wait(method string, chatID int64) error {
+	if t.limiter == nil {
+		return nil
+	}
+
+	queued := t.queued.Add(1)
+	defer func() {
+		t.metr.setOutgoingQueued(t.queued.Add(-1))
+	}()
+	if queued > t.maxQueue {
+		t.metr.incOutgoingThrottled(method, throttleResultDropped)
+		return errThrottleQueueFull
+	}
+	t.metr.setOutgoingQueued(queued)
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.maxWait)
+	defer cancel()
+
+	start := time.Now()
+	if err := t.limiter.Wait(ctx, chatID); err != nil {
+		t.log.Warn("drop outgoing request", "method", method,
+			"user_id", prepareUserID(chatID, t.priv), "error", err.Error())
+		t.metr.incOutgoingThrottled(method, throttleResultDropped)
+		return errThrottleTimeout
+	}
+	if time.Since(start) > time.Millisecond {
+		t.metr.incOutgoingThrottled(method, throttleResultDelayed)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package bote
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/maxbolgarin/lang"
+	tele "github.com/maxbolgarin/telebot/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingLimiter blocks every Wait until release is closed or ctx is done.
+type blockingLimiter struct {
+	release chan struct{}
+}
+
+func (l *blockingLimiter) Wait(ctx context.Context, _ int64) error {
+	select {
+	case <-l.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newTestThrottler(t *testing.T, cfg ThrottleConfig, limiter OutgoingLimiter) *throttler {
+	t.Helper()
+	thr, err := newThrottler(cfg, limiter, nil, noopLogger{}, PrivacyModeLow)
+	require.NoError(t, err)
+	return thr
+}
+
+func TestTokenBucketLimiterPerChat(t *testing.T) {
+	limiter, err := NewOutgoingLimiter(ThrottleConfig{ChatPerSecond: 10, ChatBurst: 1, GroupPerMinute: 60})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, limiter.Wait(ctx, 1))
+
+	start := time.Now()
+	require.NoError(t, limiter.Wait(ctx, 2))
+	assert.Less(t, time.Since(start), 50*time.Millisecond, "other chat has its own bucket")
+
+	start = time.Now()
+	require.NoError(t, limiter.Wait(ctx, 1))
+	assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond, "second request to the chat waits for a token")
+
+	require.NoError(t, limiter.Wait(ctx, -100))
+	shortCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, limiter.Wait(shortCtx, -100), "group bucket refills once a second")
+
+	require.NoError(t, limiter.Wait(ctx, 0), "deletions use only the global bucket")
+}
+
+func TestThrottlerRetriesFloodError(t *testing.T) {
+	thr := newTestThrottler(t, ThrottleConfig{Enabled: lang.Ptr(false)}, nil)
+
+	var calls int
+	err := thr.do("send", 1, func() error {
+		calls++
+		if calls == 1 {
+			return tele.FloodError{RetryAfter: 1}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	calls = 0
+	plain := errors.New("telegram: Bad Request: chat not found (400)")
+	err = thr.do("send", 1, func() error {
+		calls++
+		return plain
+	})
+	assert.ErrorIs(t, err, plain)
+	assert.Equal(t, 1, calls, "only flood errors are retried")
+}
+
+func TestThrottlerGivesUpOnLongRetryAfter(t *testing.T) {
+	thr := newTestThrottler(t, ThrottleConfig{Enabled: lang.Ptr(false), MaxWait: time.Second}, nil)
+
+	var calls int
+	err := thr.do("send", 1, func() error {
+		calls++
+		return tele.FloodError{RetryAfter: 30}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestThrottlerQueueBound(t *testing.T) {
+	limiter := &blockingLimiter{release: make(chan struct{})}
+	thr := newTestThrottler(t, ThrottleConfig{Enabled: lang.Ptr(true), MaxQueue: 1}, limiter)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- thr.do("send", 1, func() error { return nil })
+	}()
+
+	require.Eventually(t, func() bool { return thr.queued.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	err := thr.do("send", 2, func() error { return nil })
+	assert.ErrorIs(t, err, errThrottleQueueFull)
+
+	close(limiter.release)
+	require.NoError(t, <-done)
+	assert.Zero(t, thr.queued.Load())
+}
+
+func TestThrottlerMaxWait(t *testing.T) {
+	limiter := &blockingLimiter{release: make(chan struct{})}
+	thr := newTestThrottler(t, ThrottleConfig{Enabled: lang.Ptr(true), MaxWait: 50 * time.Millisecond}, limiter)
+
+	called := false
+	err := thr.do("edit", 1, func() error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, errThrottleTimeout)
+	assert.False(t, called)
+}
+
+func TestThrottlerDisabled(t *testing.T) {
+	thr := newTestThrottler(t, ThrottleConfig{Enabled: lang.Ptr(false)}, nil)
+	assert.Nil(t, thr.limiter)
+
+	thr = newTestThrottler(t, ThrottleConfig{}, nil)
+	assert.Nil(t, thr.limiter, "disabled by default")
+
+	thr = newTestThrottler(t, ThrottleConfig{Enabled: lang.Ptr(true)}, nil)
+	assert.NotNil(t, thr.limiter)
+}
+
+func TestThrottlerDoOnce(t *testing.T) {
+	thr := newTestThrottler(t, ThrottleConfig{Enabled: lang.Ptr(false)}, nil)
+
+	var calls int
+	err := thr.doOnce("send", 1, func() error {
+		calls++
+		return tele.FloodError{RetryAfter: 1}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls, "the caller retries flood errors itself")
+}
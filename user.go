@@ -338,6 +338,13 @@ type UsersStorage interface {
 	Delete(ctx context.Context, id FullUserID) error
 }
 
+// UsersLister is an optional interface of [UsersStorage] that returns all stored users.
+// It is required by [Bot.Broadcast]. In-memory storage and storages from bote subpackages implement it.
+type UsersLister interface {
+	// FindAll returns all users from storage, including disabled ones.
+	FindAll(ctx context.Context) ([]UserModel, error)
+}
+
 const (
 	// maxButtonMapSize is the maximum number of button handlers per user before cleanup.
 	// Old handlers are re-registered via initUserHandler if the user clicks them.
@@ -434,6 +441,12 @@ type UserMessages struct {
 	NotificationID int `bson:"notification_id" json:"notification_id" db:"notification_id"`
 	// Error message can be sent in any time in case of error and deleted automically after next action.
 	ErrorID int `bson:"error_id" json:"error_id" db:"error_id"`
+	// NotificationExpiresAt is a time when the notification message will be deleted automatically.
+	// Zero time means the notification lives until it is replaced or deleted.
+	NotificationExpiresAt time.Time `bson:"notification_expires_at" json:"notification_expires_at" db:"notification_expires_at"`
+	// ErrorExpiresAt is a time when the error message will be deleted automatically.
+	// Zero time means the error message lives until the next user action.
+	ErrorExpiresAt time.Time `bson:"error_expires_at" json:"error_expires_at" db:"error_expires_at"`
 	// History message is the previous main messages. Main message becomes History message after new Main sending.
 	HistoryIDs []int `bson:"history_ids" json:"history_ids" db:"history_ids"`
 	// LastActions contains time of last interaction of user with every message.
@@ -484,6 +497,9 @@ type UserMessagesDiff struct {
 	ErrorID        *int              `bson:"error_id" json:"error_id" db:"error_id"`
 	HistoryIDs     []int             `bson:"history_ids" json:"history_ids" db:"history_ids"`
 	LastActions    map[int]time.Time `bson:"last_actions" json:"last_actions" db:"last_actions"`
+
+	NotificationExpiresAt *time.Time `bson:"notification_expires_at" json:"notification_expires_at" db:"notification_expires_at"`
+	ErrorExpiresAt        *time.Time `bson:"error_expires_at" json:"error_expires_at" db:"error_expires_at"`
 }
 
 // UserStateDiff contains changes that should be applied to user state.
@@ -511,6 +527,108 @@ func (u *UserModel) prepareAfterDB() {
 	}
 }
 
+// ApplyDiff applies changes from diff to the user model.
+// Storages that keep whole user models (in memory, in a file, in a document DB) can use it
+// to implement [UsersStorage.UpdateAsync] without repeating the merge logic.
+func (u *UserModel) ApplyDiff(diff *UserModelDiff) {
+	if diff == nil {
+		return
+	}
+
+	// Ensure user model is properly initialized
+	u.prepareAfterDB()
+
+	if diff.Info != nil {
+		if diff.Info.FirstName != nil {
+			u.Info.FirstName = *diff.Info.FirstName
+		}
+		if diff.Info.LastName != nil {
+			u.Info.LastName = *diff.Info.LastName
+		}
+		if diff.Info.Username != nil {
+			u.Info.Username = *diff.Info.Username
+		}
+		if diff.Info.IsPremium != nil {
+			u.Info.IsPremium = diff.Info.IsPremium
+		}
+	}
+	if diff.Messages != nil {
+		if diff.Messages.MainID != nil {
+			u.Messages.MainID = *diff.Messages.MainID
+		}
+		if diff.Messages.HeadID != nil {
+			u.Messages.HeadID = *diff.Messages.HeadID
+		}
+		if diff.Messages.NotificationID != nil {
+			u.Messages.NotificationID = *diff.Messages.NotificationID
+		}
+		if diff.Messages.ErrorID != nil {
+			u.Messages.ErrorID = *diff.Messages.ErrorID
+		}
+		if diff.Messages.NotificationExpiresAt != nil {
+			u.Messages.NotificationExpiresAt = *diff.Messages.NotificationExpiresAt
+		}
+		if diff.Messages.ErrorExpiresAt != nil {
+			u.Messages.ErrorExpiresAt = *diff.Messages.ErrorExpiresAt
+		}
+		if diff.Messages.HistoryIDs != nil {
+			u.Messages.HistoryIDs = diff.Messages.HistoryIDs
+		}
+		if diff.Messages.LastActions != nil {
+			u.Messages.LastActions = diff.Messages.LastActions
+		}
+	}
+	if diff.State != nil {
+		if diff.State.Main != nil {
+			u.State.Main = *diff.State.Main
+		}
+		if len(diff.State.MessageStates) > 0 {
+			u.State.MessageStates = diff.State.MessageStates
+		}
+		if diff.State.MessagesAwaitingText != nil {
+			u.State.MessagesAwaitingText = diff.State.MessagesAwaitingText
+			// Rebuild messagesStackInd when MessagesAwaitingText is updated
+			u.State.messagesStackInd = make(map[int]int, len(u.State.MessagesAwaitingText))
+			for i, v := range u.State.MessagesAwaitingText {
+				u.State.messagesStackInd[v] = i
+			}
+		}
+	}
+
+	if diff.Stats != nil {
+		if diff.Stats.LastSeenTime != nil {
+			u.Stats.LastSeenTime = *diff.Stats.LastSeenTime
+		}
+		if diff.Stats.DisabledTime != nil {
+			u.Stats.DisabledTime = *diff.Stats.DisabledTime
+		}
+		if diff.Stats.NumberOfStateChanges != nil {
+			u.Stats.NumberOfStateChangesTotal = *diff.Stats.NumberOfStateChanges
+		}
+	}
+	if diff.IsDisabled != nil {
+		u.IsDisabled = *diff.IsDisabled
+	}
+
+	if diff.IsBot != nil {
+		u.IsBot = *diff.IsBot
+	}
+
+	if diff.LanguageCode != nil {
+		u.LanguageCode = *diff.LanguageCode
+	}
+
+	if diff.ForceLanguageCode != nil {
+		u.ForceLanguageCode = *diff.ForceLanguageCode
+	}
+
+	if diff.Values != nil {
+		// Make a copy to avoid sharing the same map reference
+		u.Values = make(map[string]any, len(diff.Values))
+		maps.Copy(u.Values, diff.Values)
+	}
+}
+
 func (m UserMessages) HasMsgID(msgID int) bool {
 	return m.MainID == msgID ||
 		m.HeadID == msgID ||
@@ -984,9 +1102,11 @@ func (u *userContextImpl) applyDeleteAll(deleted map[int]struct{}) {
 	}
 	if _, ok := deleted[u.user.Messages.NotificationID]; ok {
 		u.user.Messages.NotificationID = 0
+		u.user.Messages.NotificationExpiresAt = time.Time{}
 	}
 	if _, ok := deleted[u.user.Messages.ErrorID]; ok {
 		u.user.Messages.ErrorID = 0
+		u.user.Messages.ErrorExpiresAt = time.Time{}
 	}
 
 	// Remove message states, last actions, and awaiting text entries for every deleted
@@ -1019,6 +1139,8 @@ func (u *userContextImpl) applyDeleteAll(deleted map[int]struct{}) {
 	headID := u.user.Messages.HeadID
 	notificationID := u.user.Messages.NotificationID
 	errorID := u.user.Messages.ErrorID
+	notificationExpiresAt := u.user.Messages.NotificationExpiresAt
+	errorExpiresAt := u.user.Messages.ErrorExpiresAt
 
 	historyIDs := make([]int, len(u.user.Messages.HistoryIDs))
 	copy(historyIDs, u.user.Messages.HistoryIDs)
@@ -1036,12 +1158,14 @@ func (u *userContextImpl) applyDeleteAll(deleted map[int]struct{}) {
 
 	u.db.UpdateAsync(userID, &UserModelDiff{
 		Messages: &UserMessagesDiff{
-			MainID:         &mainID,
-			HeadID:         &headID,
-			NotificationID: &notificationID,
-			ErrorID:        &errorID,
-			HistoryIDs:     historyIDs,
-			LastActions:    lastActions,
+			MainID:                &mainID,
+			HeadID:                &headID,
+			NotificationID:        &notificationID,
+			ErrorID:               &errorID,
+			HistoryIDs:            historyIDs,
+			LastActions:           lastActions,
+			NotificationExpiresAt: &notificationExpiresAt,
+			ErrorExpiresAt:        &errorExpiresAt,
 		},
 		State: &UserStateDiff{
 			MessageStates:        messageStates,
@@ -1083,8 +1207,16 @@ func (u *userContextImpl) setHeadMessage(msgID int) {
 }
 
 func (u *userContextImpl) setErrorMessage(msgID int) {
+	u.setExpiringErrorMessage(msgID, time.Time{})
+}
+
+// setExpiringErrorMessage sets the error message and the time of its automatic deletion.
+// The expiry is always written together with the ID, so a new error message never inherits
+// the deadline of the previous one.
+func (u *userContextImpl) setExpiringErrorMessage(msgID int, expiresAt time.Time) {
 	u.mu.Lock()
 	u.user.Messages.ErrorID = msgID
+	u.user.Messages.ErrorExpiresAt = expiresAt
 
 	// Capture values for the DB update
 	userID := u.user.ID
@@ -1093,14 +1225,21 @@ func (u *userContextImpl) setErrorMessage(msgID int) {
 
 	u.db.UpdateAsync(userID, &UserModelDiff{
 		Messages: &UserMessagesDiff{
-			ErrorID: &errorID,
+			ErrorID:        &errorID,
+			ErrorExpiresAt: &expiresAt,
 		},
 	})
 }
 
 func (u *userContextImpl) setNotificationMessage(msgID int) {
+	u.setExpiringNotificationMessage(msgID, time.Time{})
+}
+
+// setExpiringNotificationMessage sets the notification message and the time of its automatic deletion.
+func (u *userContextImpl) setExpiringNotificationMessage(msgID int, expiresAt time.Time) {
 	u.mu.Lock()
 	u.user.Messages.NotificationID = msgID
+	u.user.Messages.NotificationExpiresAt = expiresAt
 
 	// Capture values for the DB update
 	userID := u.user.ID
@@ -1109,11 +1248,54 @@ func (u *userContextImpl) setNotificationMessage(msgID int) {
 
 	u.db.UpdateAsync(userID, &UserModelDiff{
 		Messages: &UserMessagesDiff{
-			NotificationID: &notificationID,
+			NotificationID:        &notificationID,
+			NotificationExpiresAt: &expiresAt,
 		},
 	})
 }
 
+// forgetExpiredMessage clears the notification or error message if it is still msgID and
+// still expires at expiresAt. It returns false if the message was deleted, replaced or got
+// another deadline after the expiry timer had been armed: then the timer is stale and
+// the message must not be touched.
+func (u *userContextImpl) forgetExpiredMessage(kind expiringMessageKind, msgID int, expiresAt time.Time) bool {
+	u.mu.Lock()
+
+	var diff UserMessagesDiff
+	switch kind {
+	case expiringNotification:
+		if u.user.Messages.NotificationID != msgID || !u.user.Messages.NotificationExpiresAt.Equal(expiresAt) {
+			u.mu.Unlock()
+			return false
+		}
+		u.user.Messages.NotificationID = 0
+		u.user.Messages.NotificationExpiresAt = time.Time{}
+		diff.NotificationID = lang.Ptr(0)
+		diff.NotificationExpiresAt = &time.Time{}
+
+	case expiringError:
+		if u.user.Messages.ErrorID != msgID || !u.user.Messages.ErrorExpiresAt.Equal(expiresAt) {
+			u.mu.Unlock()
+			return false
+		}
+		u.user.Messages.ErrorID = 0
+		u.user.Messages.ErrorExpiresAt = time.Time{}
+		diff.ErrorID = lang.Ptr(0)
+		diff.ErrorExpiresAt = &time.Time{}
+
+	default:
+		u.mu.Unlock()
+		return false
+	}
+
+	// Capture values for the DB update
+	userID := u.user.ID
+	u.mu.Unlock()
+
+	u.db.UpdateAsync(userID, &UserModelDiff{Messages: &diff})
+	return true
+}
+
 func (u *userContextImpl) forgetHistoryMessage(msgIDs ...int) (found bool) {
 	u.mu.Lock()
 
@@ -1690,6 +1872,45 @@ func (m *userManagerImpl) getUser(userID int64) *userContextImpl {
 	return user
 }
 
+// findUser returns the user from cache or storage. Unlike getUser, it does not create unknown users
+// and does not enable disabled ones, so it is used for work that is not triggered by the user,
+// e.g. scheduled jobs. Disabled users are not added to cache: the next update from the user enables it.
+func (m *userManagerImpl) findUser(userID int64) (*userContextImpl, bool, error) {
+	if user, found := m.users.get(userID); found {
+		user.setUserID(userID)
+		return user, true, nil
+	}
+
+	fullID := NewPlainUserID(userID)
+	if m.priv.IsStrict() {
+		var err error
+		fullID, err = NewPrivateUserID(userID, m.keysProvider.GetEncryptionKey(), m.keysProvider.GetHMACKey())
+		if err != nil {
+			return nil, false, erro.Wrap(err, "failed to create user ID")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	model, found, err := m.db.Find(ctx, fullID)
+	if err != nil {
+		return nil, false, erro.Wrap(err, "failed to find user in database", "user_id", fullID.String())
+	}
+	if !found {
+		return nil, false, nil
+	}
+
+	user := m.newUserContext(model, m.priv)
+	user.setUserID(userID)
+	if !model.IsDisabled {
+		if ok := m.users.set(userID, user); !ok {
+			m.log.Warn("failed to add user to cache", "user_id", fullID.String())
+		}
+	}
+	return user, true, nil
+}
+
 func (m *userManagerImpl) createFallbackUser(tUser *tele.User, encryptionKey, hmacKey *EncryptionKey) *userContextImpl {
 	userID, err := NewPrivateUserID(tUser.ID, encryptionKey, hmacKey)
 	if err != nil {
@@ -1786,6 +2007,8 @@ func (m *userManagerImpl) deleteUser(userID int64, fullID FullUserID) {
 	m.users.delete(userID)
 }
 
+var errUsersListingNotSupported = erro.New("users storage does not implement UsersLister")
+
 // orderedStorage wraps UsersStorage to guarantee per-user FIFO ordering of UpdateAsync calls
 // using a gorder write queue. Insert and Find are passed through directly.
 type orderedStorage struct {
@@ -1805,6 +2028,15 @@ func (s *orderedStorage) Find(ctx context.Context, id FullUserID) (UserModel, bo
 	return s.db.Find(ctx, id)
 }
 
+// FindAll returns all users if the underlying storage implements [UsersLister].
+func (s *orderedStorage) FindAll(ctx context.Context) ([]UserModel, error) {
+	lister, ok := s.db.(UsersLister)
+	if !ok {
+		return nil, errUsersListingNotSupported
+	}
+	return lister.FindAll(ctx)
+}
+
 func (s *orderedStorage) UpdateAsync(id FullUserID, userModel *UserModelDiff) {
 	s.queue.Push(id.String(), "update", func(_ context.Context) error {
 		s.db.UpdateAsync(id, userModel)
@@ -1905,92 +2137,7 @@ func (m *inMemoryUserStorage) UpdateAsync(id FullUserID, diff *UserModelDiff) {
 		return
 	}
 
-	// Ensure user model is properly initialized
-	(&user).prepareAfterDB()
-
-	if diff.Info != nil {
-		if diff.Info.FirstName != nil {
-			user.Info.FirstName = *diff.Info.FirstName
-		}
-		if diff.Info.LastName != nil {
-			user.Info.LastName = *diff.Info.LastName
-		}
-		if diff.Info.Username != nil {
-			user.Info.Username = *diff.Info.Username
-		}
-		if diff.Info.IsPremium != nil {
-			user.Info.IsPremium = diff.Info.IsPremium
-		}
-	}
-	if diff.Messages != nil {
-		if diff.Messages.MainID != nil {
-			user.Messages.MainID = *diff.Messages.MainID
-		}
-		if diff.Messages.HeadID != nil {
-			user.Messages.HeadID = *diff.Messages.HeadID
-		}
-		if diff.Messages.NotificationID != nil {
-			user.Messages.NotificationID = *diff.Messages.NotificationID
-		}
-		if diff.Messages.ErrorID != nil {
-			user.Messages.ErrorID = *diff.Messages.ErrorID
-		}
-		if diff.Messages.HistoryIDs != nil {
-			user.Messages.HistoryIDs = diff.Messages.HistoryIDs
-		}
-		if diff.Messages.LastActions != nil {
-			user.Messages.LastActions = diff.Messages.LastActions
-		}
-	}
-	if diff.State != nil {
-		if diff.State.Main != nil {
-			user.State.Main = *diff.State.Main
-		}
-		if len(diff.State.MessageStates) > 0 {
-			user.State.MessageStates = diff.State.MessageStates
-		}
-		if diff.State.MessagesAwaitingText != nil {
-			user.State.MessagesAwaitingText = diff.State.MessagesAwaitingText
-			// Rebuild messagesStackInd when MessagesAwaitingText is updated
-			user.State.messagesStackInd = make(map[int]int, len(user.State.MessagesAwaitingText))
-			for i, v := range user.State.MessagesAwaitingText {
-				user.State.messagesStackInd[v] = i
-			}
-		}
-	}
-
-	if diff.Stats != nil {
-		if diff.Stats.LastSeenTime != nil {
-			user.Stats.LastSeenTime = *diff.Stats.LastSeenTime
-		}
-		if diff.Stats.DisabledTime != nil {
-			user.Stats.DisabledTime = *diff.Stats.DisabledTime
-		}
-		if diff.Stats.NumberOfStateChanges != nil {
-			user.Stats.NumberOfStateChangesTotal = *diff.Stats.NumberOfStateChanges
-		}
-	}
-	if diff.IsDisabled != nil {
-		user.IsDisabled = *diff.IsDisabled
-	}
-
-	if diff.IsBot != nil {
-		user.IsBot = *diff.IsBot
-	}
-
-	if diff.LanguageCode != nil {
-		user.LanguageCode = *diff.LanguageCode
-	}
-
-	if diff.ForceLanguageCode != nil {
-		user.ForceLanguageCode = *diff.ForceLanguageCode
-	}
-
-	if diff.Values != nil {
-		// Make a copy to avoid sharing the same map reference
-		user.Values = make(map[string]any, len(diff.Values))
-		maps.Copy(user.Values, diff.Values)
-	}
+	user.ApplyDiff(diff)
 
 	m.cache.Set(lang.Deref(id.IDPlain), user)
 }
@@ -10,6 +10,7 @@ import (
 	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
+	"io"
 	"math/big"
 	"net"
 	"net/http"
@@ -33,6 +34,10 @@ type webhookPoller struct {
 	cfg     WebhookConfig
 	log     Logger
 	metrics *metrics
+	inbox   *webhookInbox
+
+	// allowedIPs filters requests to ServeHTTP, the built-in server filters them itself.
+	allowedIPs []*net.IPNet
 
 	// botMu guards bot and updates: Poll (telebot goroutine) writes them while
 	// shutdown/GetWebhookInfo may read them from other goroutines.
@@ -57,6 +62,23 @@ func newWebhookPoller(config WebhookConfig, metr *metrics, logger Logger) (*webh
 		return nil, erro.Wrap(err, "prepare certificate")
 	}
 
+	// Let request metrics exclude the actual (possibly custom) metrics endpoint.
+	metr.setWebhookMetricsPath(config.MetricsPath)
+
+	if config.ExternalServer {
+		allowedIPs, err := parseIPNets(config.Security.AllowedIPs)
+		if err != nil {
+			return nil, erro.Wrap(err, "parse allowed IPs")
+		}
+		return &webhookPoller{
+			cfg:        config,
+			log:        logger,
+			metrics:    metr,
+			allowedIPs: allowedIPs,
+			stopCh:     make(chan struct{}),
+		}, nil
+	}
+
 	servexOpts := []servex.Option{
 		servex.WithNoRequestLog(),
 		servex.WithReadTimeout(config.ReadTimeout),
@@ -102,9 +124,6 @@ func newWebhookPoller(config WebhookConfig, metr *metrics, logger Logger) (*webh
 		stopCh:  make(chan struct{}),
 	}
 
-	// Let request metrics exclude the actual (possibly custom) metrics endpoint.
-	metr.setWebhookMetricsPath(config.MetricsPath)
-
 	wp.srv.POST(config.urlParsed.Path, wp.handleWebhook)
 	if config.EnableMetrics {
 		wp.srv.GET(config.MetricsPath, promhttp.HandlerFor(metr.Registry, promhttp.HandlerOpts{
@@ -122,6 +141,19 @@ func (wp *webhookPoller) Poll(bot *tele.Bot, updates chan tele.Update, stop chan
 	wp.updates = updates
 	wp.botMu.Unlock()
 
+	if wp.inbox != nil {
+		if err := wp.inbox.restore(context.Background()); err != nil {
+			wp.log.Error("failed to restore webhook inbox", "error", err.Error())
+			wp.metrics.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+		}
+		lang.Go(wp.log, func() { wp.inbox.run(updates, stop) })
+	}
+
+	if wp.srv == nil {
+		wp.serve(stop)
+		return
+	}
+
 	var start func(string) error
 	if wp.cfg.Security.StartHTTPS {
 		start = wp.srv.StartHTTPS
@@ -152,6 +184,25 @@ func (wp *webhookPoller) Poll(bot *tele.Bot, updates chan tele.Update, stop chan
 	}
 }
 
+// serve sets the webhook of a poller without own server, updates come from a shared listener of [Manager]
+// or from an external server through [Bot.WebhookHandler].
+func (wp *webhookPoller) serve(stop chan struct{}) {
+	if err := wp.setWebhook(); err != nil {
+		wp.log.Error("failed to set webhook", "error", err.Error())
+		close(wp.stopCh)
+		return
+	}
+
+	wp.log.Info("webhook poller started", "url", wp.cfg.URL)
+
+	select {
+	case <-stop:
+		wp.log.Info("webhook poller stopping")
+	case <-wp.stopCh:
+		wp.log.Info("webhook poller stopped")
+	}
+}
+
 // setWebhook configures the webhook on Telegram's side.
 func (wp *webhookPoller) setWebhook() error {
 	webhookURL := wp.cfg.URL
@@ -204,12 +255,42 @@ func (wp *webhookPoller) setWebhook() error {
 	return nil
 }
 
+// ServeHTTP implements http.Handler for servers that are not owned by the poller.
+// It filters requests by IP, counts them in metrics and passes updates to the bot.
+func (wp *webhookPoller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !ipAllowed(r, wp.allowedIPs) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	wp.metrics.HandleRequest(r)
+	wp.handleWebhook(rec, r)
+	wp.metrics.HandleResponse(r, rec, rec.status, time.Since(start))
+}
+
+// statusRecorder remembers the status code of a response for metrics.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
 // handleWebhook handles incoming webhook requests.
+// It does not depend on the server of the poller, so it is also used by [webhookPoller.ServeHTTP].
 func (wp *webhookPoller) handleWebhook(w http.ResponseWriter, r *http.Request) {
-	ctx := wp.srv.NewContext(w, r)
-
 	if err := wp.validateRequest(r); err != nil {
-		ctx.BadRequest(err, "request validation failed")
+		wp.log.Debug("webhook request validation failed", "error", err.Error())
+		http.Error(w, "request validation failed", http.StatusBadRequest)
 		return
 	}
 
@@ -217,21 +298,49 @@ func (wp *webhookPoller) handleWebhook(w http.ResponseWriter, r *http.Request) {
 	// Telegram updates are typically a few KB at most.
 	r.Body = http.MaxBytesReader(w, r.Body, 128*1024)
 
-	update, err := servex.ReadJSON[tele.Update](r)
+	data, err := io.ReadAll(r.Body)
 	if err != nil {
-		ctx.BadRequest(err, "failed to read update")
+		wp.log.Debug("failed to read update", "error", err.Error())
+		http.Error(w, "failed to read update", http.StatusBadRequest)
+		return
+	}
+	var update tele.Update
+	if err := json.Unmarshal(data, &update); err != nil {
+		wp.log.Debug("failed to parse update", "error", err.Error())
+		http.Error(w, "failed to read update", http.StatusBadRequest)
+		return
+	}
+
+	wp.botMu.RLock()
+	updates := wp.updates
+	wp.botMu.RUnlock()
+	if updates == nil {
+		http.Error(w, "bot is not started", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Acknowledge the update only after it is saved, the inbox passes it to the bot.
+	// Telegram retries the update on error and it is ignored if it is already saved.
+	if wp.inbox != nil {
+		if _, err := wp.inbox.put(r.Context(), update, data); err != nil {
+			wp.log.Error("failed to save update to inbox", "update_id", update.ID, "error", err.Error())
+			wp.metrics.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
+			http.Error(w, "failed to save update", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
 	// Send update to channel (non-blocking)
 	select {
-	case wp.updates <- update:
-		ctx.Response(http.StatusOK)
+	case updates <- update:
+		w.WriteHeader(http.StatusOK)
 
 	default:
 		wp.log.Warn("update channel full, dropping update")
 		wp.metrics.incError(MetricsErrorInternal, MetricsErrorSeverityHigh)
-		ctx.ServiceUnavailable(nil, "update channel full, dropping update")
+		http.Error(w, "update channel full, dropping update", http.StatusServiceUnavailable)
 	}
 }
 
@@ -267,9 +376,11 @@ func (wp *webhookPoller) shutdown(ctx context.Context) error {
 			errList.Add(err)
 		}
 
-		// Stop HTTP server
-		if err := wp.srv.Shutdown(ctx); err != nil {
-			errList.Add(err)
+		// Stop HTTP server, a poller of a bot from [Manager] or with an external server has no own server
+		if wp.srv != nil {
+			if err := wp.srv.Shutdown(ctx); err != nil {
+				errList.Add(err)
+			}
 		}
 
 		wp.log.Debug("webhook poller shutdown complete")
@@ -17,6 +17,7 @@ import (
 
 	tele "github.com/maxbolgarin/telebot/v4"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -550,3 +551,47 @@ func TestWebhookMaxBytesReaderApplied(t *testing.T) {
 	}
 	assert.Less(t, totalRead, 130*1024, "should not be able to read full oversized body")
 }
+
+// TestWebhookPollerServeHTTP tests the handler of a poller with an external server
+func TestWebhookPollerServeHTTP(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	wp, err := newWebhookPoller(WebhookConfig{
+		ExternalServer: true,
+		Security: WebhookSecurityConfig{
+			SecretToken: "secret",
+			AllowedIPs:  []string{"192.0.2.0/24"},
+		},
+	}, newMetrics(MetricsConfig{Registry: registry}), &testLogger{})
+	require.NoError(t, err)
+	assert.Nil(t, wp.srv)
+
+	updates := make(chan tele.Update, 10)
+	wp.updates = updates
+
+	serve := func(req *http.Request) int {
+		w := httptest.NewRecorder()
+		wp.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(webhookRequest("/bot/webhook", "secret")))
+	assert.Equal(t, 7, (<-updates).ID)
+
+	assert.Equal(t, http.StatusBadRequest, serve(webhookRequest("/bot/webhook", "wrong")))
+
+	req := webhookRequest("/bot/webhook", "secret")
+	req.RemoteAddr = "10.0.0.1:1234"
+	assert.Equal(t, http.StatusForbidden, serve(req))
+
+	req = httptest.NewRequest(http.MethodGet, "/bot/webhook", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(req))
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(wp.metrics.webhookRequestsTotal.WithLabelValues("/bot/webhook")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(wp.metrics.webhookErrorsTotal.WithLabelValues("/bot/webhook", "400")))
+
+	_, err = newWebhookPoller(WebhookConfig{
+		ExternalServer: true,
+		Security:       WebhookSecurityConfig{AllowedIPs: []string{"not an ip"}},
+	}, nil, &testLogger{})
+	assert.Error(t, err)
+}
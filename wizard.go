@@ -0,0 +1,245 @@
+package bote
+
+import (
+	"encoding/json"
+
+	"github.com/maxbolgarin/erro"
+)
+
+// WizardStep is a single question of a [Wizard]. The user answers it with a text message.
+type WizardStep struct {
+	// Name is a key of the answer in the wizard result. It is required and must be unique in the wizard.
+	// The result is built by encoding answers as a JSON object with these keys and decoding it
+	// into the result type, so Name should match a json tag of the result struct.
+	Name string
+
+	// Prompt returns a text of the question. It is required.
+	Prompt func(ctx Context) string
+
+	// Validate checks the raw answer. The text of a returned error is sent to the user with
+	// [Context.SendError] and the question is asked again. It is optional.
+	Validate func(text string) error
+
+	// Parse converts the raw answer to the value stored in the result. A returned error is handled
+	// like a validation error. It is optional, the raw text is used by default.
+	Parse func(text string) (any, error)
+
+	// Skip reports whether the step should be skipped based on the answers to previous steps.
+	// It is optional.
+	Skip func(answers map[string]any) bool
+}
+
+// Wizard is a multi-step text form. Every step is a text state registered with [Bot.RegisterState],
+// so the current step is stored in the user state and answers are stored with [User.SetValue].
+// Both are persisted through [UsersStorage]: after a restart the user continues from the same step.
+type Wizard[T any] struct {
+	bt         *Bot
+	name       string
+	steps      []WizardStep
+	states     []UserState
+	onComplete func(ctx Context, result T) error
+	onCancel   HandlerFunc
+}
+
+// NewWizard creates a wizard and registers its steps in the bot. You should create wizards before
+// calling [Bot.Start]. name must be unique among wizards of the bot.
+// onComplete is called with the typed result after the last step; it should send or edit the main
+// message with a new state, otherwise the user stays in the state of the last step.
+func NewWizard[T any](b *Bot, name string, onComplete func(ctx Context, result T) error, steps ...WizardStep) *Wizard[T] {
+	w := &Wizard[T]{
+		bt:         b,
+		name:       name,
+		steps:      steps,
+		states:     make([]UserState, len(steps)),
+		onComplete: onComplete,
+	}
+	for i, step := range steps {
+		if step.Name == "" || step.Prompt == nil {
+			b.bot.log.Error("wizard step must have name and prompt", "wizard", name, "step", i)
+			b.bot.metr.incError(MetricsErrorBadUsage, MetricsErrorSeverityHigh)
+		}
+		w.states[i] = UserState("wizard:" + name + ":" + step.Name)
+		b.RegisterState(w.states[i], StateNode{
+			Render: func(ctx Context) error { return w.render(ctx, i) },
+			OnText: func(ctx Context) error { return w.handleText(ctx, i) },
+		})
+	}
+	return w
+}
+
+// OnCancel sets a handler that is called when the user presses the cancel button.
+// By default the start handler of the bot is called.
+func (w *Wizard[T]) OnCancel(handler HandlerFunc) *Wizard[T] {
+	w.onCancel = handler
+	return w
+}
+
+// State returns a state of the step with the provided name and false if there is no such step.
+func (w *Wizard[T]) State(stepName string) (State, bool) {
+	for i, step := range w.steps {
+		if step.Name == stepName {
+			return w.states[i], true
+		}
+	}
+	return nil, false
+}
+
+// Start clears previous answers and asks the first question. It edits the main message if there is one
+// and sends a new main message otherwise.
+func (w *Wizard[T]) Start(ctx Context) error {
+	w.clear(ctx.User())
+	next := w.nextStep(ctx, 0)
+	if next == len(w.steps) {
+		return w.complete(ctx)
+	}
+	return w.render(ctx, next)
+}
+
+func (w *Wizard[T]) render(ctx Context, i int) error {
+	step := w.steps[i]
+
+	kb := NewKeyboard()
+	backText, cancelText := w.buttons(ctx.User())
+	if backText != "" && w.prevStep(ctx, i-1) >= 0 {
+		kb.Add(ctx.Btn(backText, func(ctx Context) error { return w.back(ctx, i) }))
+	}
+	if cancelText != "" {
+		kb.Add(ctx.Btn(cancelText, w.cancel))
+	}
+
+	if ctx.User().Messages().MainID == 0 {
+		return ctx.SendMain(w.states[i], step.Prompt(ctx), kb.CreateInlineMarkup())
+	}
+	return ctx.EditMain(w.states[i], step.Prompt(ctx), kb.CreateInlineMarkup())
+}
+
+func (w *Wizard[T]) handleText(ctx Context, i int) error {
+	step := w.steps[i]
+	text := ctx.Text()
+
+	if step.Validate != nil {
+		if err := step.Validate(text); err != nil {
+			return ctx.SendError(err.Error())
+		}
+	}
+	if step.Parse != nil {
+		if _, err := step.Parse(text); err != nil {
+			return ctx.SendError(err.Error())
+		}
+	}
+	ctx.User().SetValue(w.valueKey(step.Name), text)
+
+	next := w.nextStep(ctx, i+1)
+	if next == len(w.steps) {
+		return w.complete(ctx)
+	}
+	return w.render(ctx, next)
+}
+
+func (w *Wizard[T]) back(ctx Context, i int) error {
+	for j := i; j < len(w.steps); j++ {
+		ctx.User().DeleteValue(w.valueKey(w.steps[j].Name))
+	}
+	prev := w.prevStep(ctx, i-1)
+	if prev < 0 {
+		return w.render(ctx, i)
+	}
+	return w.render(ctx, prev)
+}
+
+func (w *Wizard[T]) cancel(ctx Context) error {
+	w.clear(ctx.User())
+	if w.onCancel != nil {
+		return w.onCancel(ctx)
+	}
+	if w.bt.startHandler != nil {
+		return w.bt.startHandler(ctx)
+	}
+	return nil
+}
+
+func (w *Wizard[T]) complete(ctx Context) error {
+	answers, err := w.answers(ctx.User())
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(answers)
+	if err != nil {
+		return erro.Wrap(err, "marshal wizard answers", "wizard", w.name)
+	}
+	var result T
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return erro.Wrap(err, "unmarshal wizard result", "wizard", w.name)
+	}
+	w.clear(ctx.User())
+	if w.onComplete == nil {
+		return nil
+	}
+	return w.onComplete(ctx, result)
+}
+
+// nextStep returns the index of the first step starting from i that should not be skipped,
+// or the number of steps if there is no such step.
+func (w *Wizard[T]) nextStep(ctx Context, i int) int {
+	answers, _ := w.answers(ctx.User())
+	for ; i < len(w.steps); i++ {
+		if w.steps[i].Skip == nil || !w.steps[i].Skip(answers) {
+			return i
+		}
+	}
+	return len(w.steps)
+}
+
+// prevStep returns the index of the last step up to i that should not be skipped, or -1.
+func (w *Wizard[T]) prevStep(ctx Context, i int) int {
+	answers, _ := w.answers(ctx.User())
+	for ; i >= 0; i-- {
+		if w.steps[i].Skip == nil || !w.steps[i].Skip(answers) {
+			return i
+		}
+	}
+	return -1
+}
+
+// answers returns parsed answers of all answered steps.
+func (w *Wizard[T]) answers(user User) (map[string]any, error) {
+	out := make(map[string]any, len(w.steps))
+	for _, step := range w.steps {
+		rawValue, ok := user.GetValue(w.valueKey(step.Name))
+		if !ok {
+			continue
+		}
+		text, ok := rawValue.(string)
+		if !ok {
+			continue
+		}
+		if step.Parse == nil {
+			out[step.Name] = text
+			continue
+		}
+		value, err := step.Parse(text)
+		if err != nil {
+			return out, erro.Wrap(err, "parse wizard answer", "wizard", w.name, "step", step.Name)
+		}
+		out[step.Name] = value
+	}
+	return out, nil
+}
+
+func (w *Wizard[T]) clear(user User) {
+	for _, step := range w.steps {
+		user.DeleteValue(w.valueKey(step.Name))
+	}
+}
+
+func (w *Wizard[T]) buttons(user User) (back, cancel string) {
+	msgs, ok := w.bt.msgs.Messages(user.Language()).(WizardMessages)
+	if !ok {
+		msgs = enMessages{}
+	}
+	return msgs.WizardBackBtn(), msgs.WizardCancelBtn()
+}
+
+func (w *Wizard[T]) valueKey(stepName string) string {
+	return "wizard:" + w.name + ":" + stepName
+}
@@ -0,0 +1,166 @@
+package bote
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type signupForm struct {
+	Name    string `json:"name"`
+	Age     int    `json:"age"`
+	Company string `json:"company"`
+}
+
+func wizardPrompt(text string) func(Context) string {
+	return func(Context) string { return text }
+}
+
+func newSignupWizard(t *testing.T, bot *Bot, name string, onComplete func(Context, signupForm) error) *Wizard[signupForm] {
+	t.Helper()
+	return NewWizard(bot, name, onComplete,
+		WizardStep{
+			Name:   "name",
+			Prompt: wizardPrompt("Enter your name"),
+			Validate: func(text string) error {
+				if strings.TrimSpace(text) == "" {
+					return errors.New("name cannot be empty")
+				}
+				return nil
+			},
+		},
+		WizardStep{
+			Name:   "age",
+			Prompt: wizardPrompt("Enter your age"),
+			Parse:  func(text string) (any, error) { return strconv.Atoi(text) },
+		},
+		WizardStep{
+			Name:   "company",
+			Prompt: wizardPrompt("Enter your company"),
+			Skip: func(answers map[string]any) bool {
+				age, _ := answers["age"].(int)
+				return age < 18
+			},
+		},
+	)
+}
+
+// TestWizardRegistersTextStates verifies every step becomes a registered text state with restart recovery.
+func TestWizardRegistersTextStates(t *testing.T) {
+	bot := setupTestBot(t)
+	w := newSignupWizard(t, bot, "signup_states", nil)
+
+	for _, name := range []string{"name", "age", "company"} {
+		st, ok := w.State(name)
+		require.True(t, ok)
+		assert.True(t, textStateManager.has(st), "step %q must be a text state", name)
+		_, ok = bot.stateMap.Lookup(st.String())
+		assert.True(t, ok, "step %q must be restorable after restart", name)
+	}
+	_, ok := w.State("missing")
+	assert.False(t, ok)
+}
+
+// TestWizardValidationKeepsStep verifies an invalid or unparsable answer is not stored.
+func TestWizardValidationKeepsStep(t *testing.T) {
+	bot := setupTestBot(t)
+	w := newSignupWizard(t, bot, "signup_validate", nil)
+
+	ctx := NewContextText(bot, 8001, 1, "  ")
+	require.NoError(t, w.handleText(ctx, 0))
+	_, ok := ctx.User().GetValue(w.valueKey("name"))
+	assert.False(t, ok, "invalid answer must not be stored")
+
+	ctx = NewContextText(bot, 8001, 2, "not a number")
+	require.NoError(t, w.handleText(ctx, 1))
+	_, ok = ctx.User().GetValue(w.valueKey("age"))
+	assert.False(t, ok, "unparsable answer must not be stored")
+}
+
+// TestWizardStoresAnswer verifies a valid answer is stored as a user value (persisted through storage).
+func TestWizardStoresAnswer(t *testing.T) {
+	bot := setupTestBot(t)
+	w := newSignupWizard(t, bot, "signup_store", nil)
+
+	ctx := NewContextText(bot, 8002, 1, "Alice")
+	_ = w.handleText(ctx, 0) // rendering the next step fails offline
+
+	v, ok := ctx.User().GetValue(w.valueKey("name"))
+	require.True(t, ok)
+	assert.Equal(t, "Alice", v)
+}
+
+// TestWizardSkip verifies skip conditions are evaluated against parsed answers in both directions.
+func TestWizardSkip(t *testing.T) {
+	bot := setupTestBot(t)
+	w := newSignupWizard(t, bot, "signup_skip", nil)
+
+	ctx := NewContext(bot, 8003, 1)
+	ctx.User().SetValue(w.valueKey("age"), "16")
+	assert.Equal(t, 3, w.nextStep(ctx, 2), "company must be skipped for minors")
+	assert.Equal(t, 1, w.prevStep(ctx, 2))
+
+	ctx.User().SetValue(w.valueKey("age"), "30")
+	assert.Equal(t, 2, w.nextStep(ctx, 2))
+}
+
+// TestWizardCompleteTypedResult verifies the completion callback gets a typed result and answers are cleared.
+func TestWizardCompleteTypedResult(t *testing.T) {
+	bot := setupTestBot(t)
+
+	var got signupForm
+	called := 0
+	w := newSignupWizard(t, bot, "signup_complete", func(_ Context, form signupForm) error {
+		called++
+		got = form
+		return nil
+	})
+
+	ctx := NewContextText(bot, 8004, 1, "16")
+	ctx.User().SetValue(w.valueKey("name"), "Bob")
+	require.NoError(t, w.handleText(ctx, 1), "last non-skipped step completes the wizard")
+
+	assert.Equal(t, 1, called)
+	assert.Equal(t, signupForm{Name: "Bob", Age: 16}, got)
+	_, ok := ctx.User().GetValue(w.valueKey("name"))
+	assert.False(t, ok, "answers must be cleared after completion")
+}
+
+// TestWizardCancel verifies cancel clears answers and runs the cancel handler.
+func TestWizardCancel(t *testing.T) {
+	bot := setupTestBot(t)
+
+	canceled := false
+	w := newSignupWizard(t, bot, "signup_cancel", nil).OnCancel(func(Context) error {
+		canceled = true
+		return nil
+	})
+
+	ctx := NewContext(bot, 8005, 1)
+	ctx.User().SetValue(w.valueKey("name"), "Carol")
+	require.NoError(t, w.cancel(ctx))
+
+	assert.True(t, canceled)
+	_, ok := ctx.User().GetValue(w.valueKey("name"))
+	assert.False(t, ok)
+}
+
+// TestWizardButtonsFallback verifies custom Messages without WizardMessages get English buttons.
+func TestWizardButtonsFallback(t *testing.T) {
+	bot := setupTestBot(t)
+	w := newSignupWizard(t, bot, "signup_buttons", nil)
+
+	ctx := NewContext(bot, 8006, 1)
+	back, cancel := w.buttons(ctx.User())
+	assert.Equal(t, "« Back", back)
+	assert.Equal(t, "Cancel", cancel)
+
+	bot.SetMessageProvider(testProvider{})
+	back, cancel = w.buttons(ctx.User())
+	assert.Equal(t, "« Back", back)
+	assert.Equal(t, "Cancel", cancel)
+}